@@ -0,0 +1,101 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Command monasca-exporter exposes Monasca statistics and measurements on a
+// Prometheus /metrics endpoint.
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/exporter"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	configPath := flag.String("config", "monasca-exporter.yaml", "Path of the exporter configuration")
+	listen := flag.String("listen", "", "Address to serve /metrics on, overrides the configuration")
+	flag.Parse()
+
+	file, err := os.Open(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config, err := exporter.LoadConfig(file)
+	file.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client := monascaclient.New()
+	if config.MonascaURL != "" {
+		client.SetBaseURL(config.MonascaURL)
+	}
+	if config.Timeout > 0 {
+		client.SetTimeout(config.Timeout)
+	}
+	client.SetInsecure(config.Insecure)
+	if config.Keystone {
+		err = client.SetKeystoneConfig(nil)
+		if err == nil {
+			err = client.SetKeystoneToken()
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	jobs, err := config.BuildJobs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	metricsExporter, err := exporter.New(client, jobs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if config.Prefix != nil {
+		metricsExporter.SetPrefix(*config.Prefix)
+	}
+
+	address := config.Listen
+	if *listen != "" {
+		address = *listen
+	}
+	if address == "" {
+		address = ":9507"
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go metricsExporter.Run(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsExporter)
+	server := &http.Server{Addr: address, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	log.Printf("Serving Monasca metrics on %s/metrics", address)
+	err = server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
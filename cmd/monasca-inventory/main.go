@@ -0,0 +1,79 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Command monasca-inventory reports the series count and dimension
+// cardinality of the metrics of a tenant, and the metrics that are no longer
+// updated.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/inventory"
+	"log"
+	"os"
+)
+
+func main() {
+	monascaURL := flag.String("url", "", "Monasca API URL, http://localhost:8070 if empty")
+	tenantID := flag.String("tenant", "", "Tenant to analyze instead of the authenticated one")
+	keystone := flag.Bool("keystone", false, "Authenticate with keystone using the OS_* environment variables")
+	insecure := flag.Bool("insecure", false, "Skip TLS certificate verification")
+	staleAfter := flag.Duration("stale-after", 0, "Age after which series count as stale, 24h if zero")
+	top := flag.Int("top", 0, "Number of high-cardinality offenders to report, 10 if zero")
+	asJSON := flag.Bool("json", false, "Write the report as JSON")
+	flag.Parse()
+
+	client := monascaclient.New()
+	if *monascaURL != "" {
+		client.SetBaseURL(*monascaURL)
+	}
+	client.SetInsecure(*insecure)
+	if *keystone {
+		err := client.SetKeystoneConfig(nil)
+		if err == nil {
+			err = client.SetKeystoneToken()
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	analyzer := inventory.New(client)
+	if *tenantID != "" {
+		analyzer.SetTenantID(tenantID)
+	}
+	if *staleAfter > 0 {
+		analyzer.SetStaleAfter(*staleAfter)
+	}
+	if *top > 0 {
+		analyzer.SetTopN(*top)
+	}
+	report, err := analyzer.Analyze()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	defaultWatchInterval   = 30 * time.Second
+	defaultWatchMaxBackoff = 5 * time.Minute
+)
+
+type AlarmEventType string
+
+const (
+	AlarmCreated          AlarmEventType = "created"
+	AlarmStateChanged     AlarmEventType = "state_changed"
+	AlarmLifecycleChanged AlarmEventType = "lifecycle_changed"
+	AlarmDeleted          AlarmEventType = "deleted"
+)
+
+// AlarmEvent describes a change noticed between two polls. Previous is nil for
+// created alarms; for deleted alarms Alarm is the last known version.
+type AlarmEvent struct {
+	Type     AlarmEventType
+	Alarm    models.Alarm
+	Previous *models.Alarm
+	Time     time.Time
+}
+
+// AlarmWatcherCheckpoint is the serialisable state of a watcher, it can be
+// stored and handed to Resume to continue without replaying known alarms.
+type AlarmWatcherCheckpoint struct {
+	LastStateUpdate time.Time               `json:"last_state_update"`
+	Alarms          map[string]models.Alarm `json:"alarms"`
+}
+
+// AlarmWatcher polls GetAlarms and emits events for alarms that appeared,
+// changed state or lifecycle state, or disappeared. The first poll only
+// records the existing alarms, unless SetReplayExisting asks for a created
+// event for each of them.
+//
+// In incremental mode only alarms whose state changed since the last poll are
+// requested using state_updated_start_time. This is cheaper, but deletions and
+// lifecycle changes are not detected.
+type AlarmWatcher struct {
+	client        *Client
+	query         *models.AlarmQuery
+	interval      time.Duration
+	maxBackoff    time.Duration
+	incremental   bool
+	replay        bool
+	handlers      []func(AlarmEvent)
+	errorHandlers []func(error)
+	events        chan AlarmEvent
+
+	lock            sync.Mutex
+	initialized     bool
+	known           map[string]models.Alarm
+	lastStateUpdate time.Time
+}
+
+func NewAlarmWatcher(alarmQuery *models.AlarmQuery) *AlarmWatcher {
+	return monClient.NewAlarmWatcher(alarmQuery)
+}
+
+func (c *Client) NewAlarmWatcher(alarmQuery *models.AlarmQuery) *AlarmWatcher {
+	return &AlarmWatcher{
+		client:     c,
+		query:      alarmQuery,
+		interval:   defaultWatchInterval,
+		maxBackoff: defaultWatchMaxBackoff,
+		known:      map[string]models.Alarm{},
+	}
+}
+
+func (w *AlarmWatcher) SetInterval(interval time.Duration) {
+	w.interval = interval
+}
+
+// SetMaxBackoff caps the delay between polls after consecutive API errors.
+func (w *AlarmWatcher) SetMaxBackoff(maxBackoff time.Duration) {
+	w.maxBackoff = maxBackoff
+}
+
+func (w *AlarmWatcher) SetIncremental(incremental bool) {
+	w.incremental = incremental
+}
+
+// SetReplayExisting makes the first poll emit AlarmCreated for every alarm
+// that already exists. It has no effect after Resume.
+func (w *AlarmWatcher) SetReplayExisting(replay bool) {
+	w.replay = replay
+}
+
+// OnEvent registers a handler called for every event, in the polling
+// goroutine.
+func (w *AlarmWatcher) OnEvent(handler func(AlarmEvent)) {
+	w.handlers = append(w.handlers, handler)
+}
+
+// OnError registers a handler called when a poll fails.
+func (w *AlarmWatcher) OnError(handler func(error)) {
+	w.errorHandlers = append(w.errorHandlers, handler)
+}
+
+// Events returns a channel receiving every event. It must be called before
+// Run and must be drained, otherwise polling blocks.
+func (w *AlarmWatcher) Events() <-chan AlarmEvent {
+	if w.events == nil {
+		w.events = make(chan AlarmEvent, 64)
+	}
+	return w.events
+}
+
+func (w *AlarmWatcher) Checkpoint() AlarmWatcherCheckpoint {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	alarms := make(map[string]models.Alarm, len(w.known))
+	for id, alarm := range w.known {
+		alarms[id] = alarm
+	}
+	return AlarmWatcherCheckpoint{LastStateUpdate: w.lastStateUpdate, Alarms: alarms}
+}
+
+// Resume restores a checkpoint so only changes since it are reported.
+func (w *AlarmWatcher) Resume(checkpoint AlarmWatcherCheckpoint) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.known = map[string]models.Alarm{}
+	for id, alarm := range checkpoint.Alarms {
+		w.known[id] = alarm
+	}
+	w.lastStateUpdate = checkpoint.LastStateUpdate
+	w.initialized = true
+}
+
+// Run polls until ctx is done. Events are delivered to the handlers and the
+// Events channel; poll errors are passed to the error handlers and delay the
+// next poll exponentially up to the maximum backoff.
+func (w *AlarmWatcher) Run(ctx context.Context) error {
+	failures := 0
+	for {
+		events, err := w.poll(ctx)
+		delay := w.interval
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			for _, handler := range w.errorHandlers {
+				handler(err)
+			}
+			failures++
+			delay = backoffDelay(w.interval, w.maxBackoff, failures)
+		} else {
+			failures = 0
+			for _, event := range events {
+				if err := w.dispatch(ctx, event); err != nil {
+					return err
+				}
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Poll runs a single poll and returns the events without dispatching them.
+func (w *AlarmWatcher) Poll(ctx context.Context) ([]AlarmEvent, error) {
+	return w.poll(ctx)
+}
+
+func backoffDelay(interval time.Duration, maxBackoff time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+func (w *AlarmWatcher) dispatch(ctx context.Context, event AlarmEvent) error {
+	for _, handler := range w.handlers {
+		handler(event)
+	}
+	if w.events == nil {
+		return nil
+	}
+	select {
+	case w.events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *AlarmWatcher) poll(ctx context.Context) ([]AlarmEvent, error) {
+	w.lock.Lock()
+	incremental := w.incremental && w.initialized
+	query := new(models.AlarmQuery)
+	if w.query != nil {
+		*query = *w.query
+	}
+	if incremental && !w.lastStateUpdate.IsZero() {
+		startTime := w.lastStateUpdate
+		query.StateUpdatedStartTime = &startTime
+	}
+	w.lock.Unlock()
+
+	alarms, err := w.client.WithContext(ctx).getAllAlarms(query)
+	if err != nil {
+		return nil, err
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	now := time.Now()
+	events := []AlarmEvent{}
+	seed := !w.initialized && !w.replay
+	seen := map[string]bool{}
+	for _, alarm := range alarms {
+		seen[alarm.ID] = true
+		if alarm.StateUpdatedTimestamp.After(w.lastStateUpdate) {
+			w.lastStateUpdate = alarm.StateUpdatedTimestamp
+		}
+		previous, found := w.known[alarm.ID]
+		w.known[alarm.ID] = alarm
+		if seed {
+			continue
+		}
+		if !found {
+			events = append(events, AlarmEvent{Type: AlarmCreated, Alarm: alarm, Time: now})
+			continue
+		}
+		if previous.State != alarm.State {
+			events = append(events, AlarmEvent{Type: AlarmStateChanged, Alarm: alarm, Previous: &previous, Time: now})
+		}
+		if previous.LifecycleState != alarm.LifecycleState {
+			events = append(events, AlarmEvent{Type: AlarmLifecycleChanged, Alarm: alarm, Previous: &previous, Time: now})
+		}
+	}
+
+	if !incremental {
+		deleted := []string{}
+		for id := range w.known {
+			if !seen[id] {
+				deleted = append(deleted, id)
+			}
+		}
+		sort.Strings(deleted)
+		for _, id := range deleted {
+			events = append(events, AlarmEvent{Type: AlarmDeleted, Alarm: w.known[id], Time: now})
+			delete(w.known, id)
+		}
+	}
+	w.initialized = true
+	return events, nil
+}
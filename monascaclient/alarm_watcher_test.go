@@ -0,0 +1,121 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAlarmWatcherEvents(t *testing.T) {
+	responses := []string{
+		`{"links": [], "elements": [{"id": "a1", "state": "OK", "lifecycle_state": "OPEN"}, {"id": "a2", "state": "OK"}]}`,
+		`{"links": [], "elements": [{"id": "a1", "state": "ALARM", "lifecycle_state": "ACKNOWLEDGED"}, {"id": "a3", "state": "OK"}]}`,
+	}
+	poll := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responses[poll]))
+		poll++
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	watcher := client.NewAlarmWatcher(nil)
+
+	events, err := watcher.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("Error %s polling", err)
+	}
+	if len(events) != 0 || len(watcher.Checkpoint().Alarms) != 2 {
+		t.Errorf("Expected the first poll to record the alarms without events but was %+v", events)
+	}
+
+	events, err = watcher.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("Error %s polling", err)
+	}
+	expected := []AlarmEventType{AlarmStateChanged, AlarmLifecycleChanged, AlarmCreated, AlarmDeleted}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events but was %+v", len(expected), events)
+	}
+	for i, eventType := range expected {
+		if events[i].Type != eventType {
+			t.Errorf("Expected event %s but was %s", eventType, events[i].Type)
+		}
+	}
+	if events[0].Previous == nil || events[0].Previous.State != "OK" || events[0].Alarm.State != "ALARM" {
+		t.Errorf("Expected state change from OK to ALARM but was %+v", events[0])
+	}
+	if events[2].Alarm.ID != "a3" || events[3].Alarm.ID != "a2" {
+		t.Errorf("Expected a3 to be created and a2 deleted but was %s and %s", events[2].Alarm.ID, events[3].Alarm.ID)
+	}
+
+	poll = 0
+	replaying := client.NewAlarmWatcher(nil)
+	replaying.SetReplayExisting(true)
+	events, err = replaying.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("Error %s polling", err)
+	}
+	if len(events) != 2 || events[0].Type != AlarmCreated || events[1].Type != AlarmCreated {
+		t.Errorf("Expected two created events when replaying but was %+v", events)
+	}
+}
+
+func TestAlarmWatcherResumeAndIncremental(t *testing.T) {
+	lastUpdate := time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("state_updated_start_time")
+		w.Write([]byte(`{"links": [], "elements": [{"id": "a1", "state": "ALARM", "state_updated_timestamp": "2017-02-27T07:00:00Z"}]}`))
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	watcher := client.NewAlarmWatcher(nil)
+	watcher.SetIncremental(true)
+	checkpoint := AlarmWatcherCheckpoint{LastStateUpdate: lastUpdate}
+	checkpoint.Alarms = map[string]models.Alarm{"a1": {State: "OK"}, "a2": {State: "OK"}}
+	watcher.Resume(checkpoint)
+
+	events, err := watcher.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("Error %s polling", err)
+	}
+	if query != "2017-02-27T06:00:00Z" {
+		t.Errorf("Expected incremental poll since checkpoint but was '%s'", query)
+	}
+	if len(events) != 1 || events[0].Type != AlarmStateChanged {
+		t.Errorf("Expected a single state change but was %+v", events)
+	}
+	if !watcher.Checkpoint().LastStateUpdate.Equal(lastUpdate.Add(time.Hour)) {
+		t.Errorf("Expected checkpoint to advance but was %v", watcher.Checkpoint().LastStateUpdate)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	if delay := backoffDelay(time.Second, time.Minute, 3); delay != 8*time.Second {
+		t.Errorf("Expected 8s but was %v", delay)
+	}
+	if delay := backoffDelay(time.Second, time.Minute, 30); delay != time.Minute {
+		t.Errorf("Expected backoff to be capped but was %v", delay)
+	}
+}
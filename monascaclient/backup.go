@@ -0,0 +1,305 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// ArchiveVersion is the version written by Export. Import accepts archives up
+// to and including this version.
+const ArchiveVersion = 1
+
+// Archive is a snapshot of the configuration of a tenant. IDs are those of
+// the exporting installation and are remapped on import.
+type Archive struct {
+	Version             int                          `json:"version" yaml:"version"`
+	CreatedAt           time.Time                    `json:"created_at" yaml:"created_at"`
+	NotificationMethods []ArchivedNotificationMethod `json:"notification_methods" yaml:"notification_methods"`
+	AlarmDefinitions    []ArchivedAlarmDefinition    `json:"alarm_definitions" yaml:"alarm_definitions"`
+}
+
+type ArchivedNotificationMethod struct {
+	ID      string `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	Type    string `json:"type" yaml:"type"`
+	Address string `json:"address" yaml:"address"`
+	Period  int    `json:"period" yaml:"period"`
+}
+
+type ArchivedAlarmDefinition struct {
+	ID                  string   `json:"id" yaml:"id"`
+	Name                string   `json:"name" yaml:"name"`
+	Description         string   `json:"description" yaml:"description"`
+	Expression          string   `json:"expression" yaml:"expression"`
+	Severity            string   `json:"severity" yaml:"severity"`
+	MatchBy             []string `json:"match_by" yaml:"match_by"`
+	AlarmActions        []string `json:"alarm_actions" yaml:"alarm_actions"`
+	OkActions           []string `json:"ok_actions" yaml:"ok_actions"`
+	UndeterminedActions []string `json:"undetermined_actions" yaml:"undetermined_actions"`
+	ActionsEnabled      bool     `json:"actions_enabled" yaml:"actions_enabled"`
+}
+
+// ImportResult maps the IDs found in the archive to the IDs of the restored
+// resources. Replaced lists the alarm definitions that were deleted and created
+// again because their match_by changed; their existing alarms are gone.
+type ImportResult struct {
+	NotificationMethodIDs map[string]string
+	AlarmDefinitionIDs    map[string]string
+	Replaced              []string
+}
+
+func Export(ctx context.Context, writer io.Writer) error {
+	return monClient.Export(ctx, writer)
+}
+
+func ExportYAML(ctx context.Context, writer io.Writer) error {
+	return monClient.ExportYAML(ctx, writer)
+}
+
+func Import(ctx context.Context, reader io.Reader) (*ImportResult, error) {
+	return monClient.Import(ctx, reader)
+}
+
+// ExportArchive reads every notification method and alarm definition of the
+// tenant.
+func (c *Client) ExportArchive(ctx context.Context) (*Archive, error) {
+	client := c.WithContext(ctx)
+	notifications, err := client.getAllNotificationMethods(nil)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := client.getAllAlarmDefinitions(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &Archive{
+		Version:             ArchiveVersion,
+		CreatedAt:           time.Now().UTC(),
+		NotificationMethods: []ArchivedNotificationMethod{},
+		AlarmDefinitions:    []ArchivedAlarmDefinition{},
+	}
+	for _, notification := range notifications {
+		archive.NotificationMethods = append(archive.NotificationMethods, ArchivedNotificationMethod{
+			ID:      notification.ID,
+			Name:    notification.Name,
+			Type:    notification.Type,
+			Address: notification.Address,
+			Period:  notification.Period,
+		})
+	}
+	for _, definition := range definitions {
+		archive.AlarmDefinitions = append(archive.AlarmDefinitions, ArchivedAlarmDefinition{
+			ID:                  definition.ID,
+			Name:                definition.Name,
+			Description:         definition.Description,
+			Expression:          definition.Expression,
+			Severity:            definition.Severity,
+			MatchBy:             definition.MatchBy,
+			AlarmActions:        definition.AlarmActions,
+			OkActions:           definition.OkActions,
+			UndeterminedActions: definition.UndeterminedActions,
+			ActionsEnabled:      definition.ActionsEnabled,
+		})
+	}
+	return archive, nil
+}
+
+// Export writes the tenant configuration as an indented JSON archive.
+func (c *Client) Export(ctx context.Context, writer io.Writer) error {
+	archive, err := c.ExportArchive(ctx)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(archive)
+}
+
+// ExportYAML writes the tenant configuration as a YAML archive.
+func (c *Client) ExportYAML(ctx context.Context, writer io.Writer) error {
+	archive, err := c.ExportArchive(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(archive)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// ReadArchive decodes an archive written by Export or ExportYAML.
+func ReadArchive(reader io.Reader) (*Archive, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	archive := new(Archive)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, archive)
+	} else {
+		err = yaml.Unmarshal(data, archive)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse archive: %v", err)
+	}
+	if archive.Version < 1 || archive.Version > ArchiveVersion {
+		return nil, fmt.Errorf("Unsupported archive version %d", archive.Version)
+	}
+	return archive, nil
+}
+
+// Import restores an archive. Resources are matched by name: existing ones are
+// updated in place, missing ones are created. Monasca cannot change match_by,
+// so alarm definitions whose match_by differs are deleted and created again.
+// Notification IDs referenced by alarm actions are remapped to the IDs of the
+// target installation.
+func (c *Client) Import(ctx context.Context, reader io.Reader) (*ImportResult, error) {
+	archive, err := ReadArchive(reader)
+	if err != nil {
+		return nil, err
+	}
+	return c.ImportArchive(ctx, archive)
+}
+
+func (c *Client) ImportArchive(ctx context.Context, archive *Archive) (*ImportResult, error) {
+	client := c.WithContext(ctx)
+	notifications, err := client.getAllNotificationMethods(nil)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := client.getAllAlarmDefinitions(nil)
+	if err != nil {
+		return nil, err
+	}
+	currentNotifications := map[string]string{}
+	for _, notification := range notifications {
+		currentNotifications[notification.Name] = notification.ID
+	}
+	currentDefinitions := map[string]models.AlarmDefinitionElement{}
+	for _, definition := range definitions {
+		currentDefinitions[definition.Name] = definition
+	}
+
+	result := &ImportResult{
+		NotificationMethodIDs: map[string]string{},
+		AlarmDefinitionIDs:    map[string]string{},
+	}
+
+	for i := range archive.NotificationMethods {
+		archived := &archive.NotificationMethods[i]
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		body := &models.NotificationRequestBody{
+			Name:    &archived.Name,
+			Type:    &archived.Type,
+			Address: &archived.Address,
+			Period:  &archived.Period,
+		}
+		var notification *models.NotificationElement
+		if id, found := currentNotifications[archived.Name]; found {
+			notification, err = client.UpdateNotificationMethod(id, body)
+		} else {
+			notification, err = client.CreateNotificationMethod(body)
+		}
+		if err != nil {
+			return result, fmt.Errorf("Failed to restore notification method %q: %v", archived.Name, err)
+		}
+		result.NotificationMethodIDs[archived.ID] = notification.ID
+	}
+
+	for i := range archive.AlarmDefinitions {
+		archived := &archive.AlarmDefinitions[i]
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		body, err := archivedAlarmDefinitionBody(archived, result.NotificationMethodIDs)
+		if err != nil {
+			return result, err
+		}
+		var definition *models.AlarmDefinitionElement
+		current, found := currentDefinitions[archived.Name]
+		if found && formatList(current.MatchBy) != formatList(archived.MatchBy) {
+			if err = client.DeleteAlarmDefinition(current.ID); err != nil {
+				return result, fmt.Errorf("Failed to replace alarm definition %q: %v", archived.Name, err)
+			}
+			result.Replaced = append(result.Replaced, archived.Name)
+			found = false
+		}
+		if found {
+			definition, err = client.UpdateAlarmDefinition(current.ID, body)
+		} else {
+			definition, err = client.CreateAlarmDefinition(body)
+		}
+		if err != nil {
+			return result, fmt.Errorf("Failed to restore alarm definition %q: %v", archived.Name, err)
+		}
+		result.AlarmDefinitionIDs[archived.ID] = definition.ID
+	}
+	return result, nil
+}
+
+func archivedAlarmDefinitionBody(archived *ArchivedAlarmDefinition, notificationIDs map[string]string) (*models.AlarmDefinitionRequestBody, error) {
+	remap := func(ids []string) (*[]string, error) {
+		remapped := make([]string, 0, len(ids))
+		for _, id := range ids {
+			newID, found := notificationIDs[id]
+			if !found {
+				return nil, fmt.Errorf("Alarm definition %q references notification method %s which is not in the archive", archived.Name, id)
+			}
+			remapped = append(remapped, newID)
+		}
+		return &remapped, nil
+	}
+
+	body := &models.AlarmDefinitionRequestBody{
+		Name:           &archived.Name,
+		Description:    &archived.Description,
+		Expression:     &archived.Expression,
+		ActionsEnabled: &archived.ActionsEnabled,
+	}
+	if archived.Severity != "" {
+		body.Severity = &archived.Severity
+	}
+	matchBy := append([]string{}, archived.MatchBy...)
+	body.MatchBy = &matchBy
+
+	var err error
+	body.AlarmActions, err = remap(archived.AlarmActions)
+	if err != nil {
+		return nil, err
+	}
+	body.OkActions, err = remap(archived.OkActions)
+	if err != nil {
+		return nil, err
+	}
+	body.UndeterminedActions, err = remap(archived.UndeterminedActions)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportImportRemapsNotificationIDs(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "notification-methods") {
+			w.Write([]byte(`{"links": [], "elements": [{"id": "old-ops", "name": "ops", "type": "EMAIL", "address": "ops@example.com", "period": 0}]}`))
+			return
+		}
+		w.Write([]byte(`{"links": [], "elements": [{"id": "old-cpu", "name": "cpu", "expression": "avg(cpu.idle_perc) < 10", "severity": "HIGH", "alarm_actions": ["old-ops"], "ok_actions": ["old-ops"], "actions_enabled": true}]}`))
+	}))
+	defer source.Close()
+
+	var restored []models.AlarmDefinitionRequestBody
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method == "GET":
+			w.Write([]byte(`{"links": [], "elements": []}`))
+		case strings.HasSuffix(r.URL.Path, "notification-methods"):
+			w.WriteHeader(201)
+			w.Write([]byte(`{"id": "new-ops"}`))
+		default:
+			var definition models.AlarmDefinitionRequestBody
+			json.Unmarshal(body, &definition)
+			restored = append(restored, definition)
+			w.WriteHeader(201)
+			w.Write([]byte(`{"id": "new-cpu"}`))
+		}
+	}))
+	defer target.Close()
+
+	exporter := New()
+	exporter.SetBaseURL(source.URL)
+	var archive bytes.Buffer
+	err := exporter.ExportYAML(context.Background(), &archive)
+	if err != nil {
+		t.Fatalf("Error %s exporting", err)
+	}
+
+	importer := New()
+	importer.SetBaseURL(target.URL)
+	result, err := importer.Import(context.Background(), &archive)
+	if err != nil {
+		t.Fatalf("Error %s importing", err)
+	}
+
+	if result.NotificationMethodIDs["old-ops"] != "new-ops" || result.AlarmDefinitionIDs["old-cpu"] != "new-cpu" {
+		t.Errorf("Unexpected ID mapping %v", result)
+	}
+	if len(restored) != 1 {
+		t.Fatalf("Expected one restored alarm definition but was %d", len(restored))
+	}
+	if (*restored[0].AlarmActions)[0] != "new-ops" || (*restored[0].OkActions)[0] != "new-ops" {
+		t.Errorf("Expected actions to be remapped but was %v %v", *restored[0].AlarmActions, *restored[0].OkActions)
+	}
+}
+
+func TestImportReplacesDefinitionOnMatchByChange(t *testing.T) {
+	requests := []string{}
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "GET":
+			if strings.HasSuffix(r.URL.Path, "alarm-definitions") {
+				w.Write([]byte(`{"links": [], "elements": [
+					{"id": "d1", "name": "cpu", "expression": "avg(cpu.idle_perc) < 10", "match_by": ["hostname"]},
+					{"id": "d2", "name": "disk", "expression": "max(disk.space_used_perc) > 90", "match_by": ["device", "hostname"]}]}`))
+				return
+			}
+			w.Write([]byte(`{"links": [], "elements": []}`))
+		case "DELETE":
+			w.WriteHeader(204)
+		case "POST":
+			w.WriteHeader(201)
+			w.Write([]byte(`{"id": "d3"}`))
+		default:
+			w.Write([]byte(`{"id": "d2"}`))
+		}
+	}))
+	defer target.Close()
+
+	importer := New()
+	importer.SetBaseURL(target.URL)
+	result, err := importer.ImportArchive(context.Background(), &Archive{
+		Version: ArchiveVersion,
+		AlarmDefinitions: []ArchivedAlarmDefinition{
+			{ID: "a1", Name: "cpu", Expression: "avg(cpu.idle_perc) < 10", MatchBy: []string{"hostname", "device"}},
+			{ID: "a2", Name: "disk", Expression: "max(disk.space_used_perc) > 90", MatchBy: []string{"hostname", "device"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error %s importing", err)
+	}
+
+	expected := "DELETE /v2.0/alarm-definitions/d1,POST /v2.0/alarm-definitions,PUT /v2.0/alarm-definitions/d2"
+	if strings.Join(requests[2:], ",") != expected {
+		t.Errorf("Expected %s but was %v", expected, requests)
+	}
+	if len(result.Replaced) != 1 || result.Replaced[0] != "cpu" || result.AlarmDefinitionIDs["a1"] != "d3" {
+		t.Errorf("Expected cpu to be replaced by d3 but was %v", result)
+	}
+}
+
+func TestReadArchiveRejectsUnknownVersion(t *testing.T) {
+	_, err := ReadArchive(strings.NewReader(`{"version": 99}`))
+	if err == nil {
+		t.Errorf("Expected error for unsupported archive version")
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerSettings configures the circuit breaker. While closed, calls are
+// counted over Window and the breaker opens once at least MinRequests calls
+// were made and FailureRatio of them failed, by a transport error or a 5xx
+// status. Calls fail fast with a *BreakerOpenError while open. After
+// OpenTimeout up to HalfOpenProbes calls are let through: the breaker closes
+// once that many have succeeded and reopens on the first failure.
+type BreakerSettings struct {
+	FailureRatio   float64
+	MinRequests    int
+	Window         time.Duration
+	OpenTimeout    time.Duration
+	HalfOpenProbes int
+	// OnStateChange, if set, is called after every transition.
+	OnStateChange func(from BreakerState, to BreakerState)
+}
+
+// DefaultBreakerSettings opens the breaker when half of at least 10 calls in a
+// minute fail, and probes again after 30 seconds.
+func DefaultBreakerSettings() BreakerSettings {
+	return BreakerSettings{
+		FailureRatio:   0.5,
+		MinRequests:    10,
+		Window:         time.Minute,
+		OpenTimeout:    30 * time.Second,
+		HalfOpenProbes: 1,
+	}
+}
+
+// BreakerOpenError is returned for calls rejected by an open circuit breaker.
+// RetryAfter is when the breaker will next let a probe through.
+type BreakerOpenError struct {
+	RetryAfter time.Time
+}
+
+func (e *BreakerOpenError) Error() string {
+	return fmt.Sprintf("Circuit breaker is open until %s", e.RetryAfter.Format(time.RFC3339))
+}
+
+func SetCircuitBreaker(settings *BreakerSettings) {
+	monClient.SetCircuitBreaker(settings)
+}
+
+func CircuitBreakerState() BreakerState {
+	return monClient.CircuitBreakerState()
+}
+
+// SetCircuitBreaker enables the circuit breaker in the closed state, nil
+// disables it. The breaker is shared with WithContext views of the client.
+func (c *Client) SetCircuitBreaker(settings *BreakerSettings) {
+	if c.breaker == nil {
+		c.breaker = &circuitBreaker{}
+	}
+	c.breaker.configure(settings)
+}
+
+// CircuitBreakerState returns the state of the circuit breaker, which is
+// always closed when it is disabled.
+func (c *Client) CircuitBreakerState() BreakerState {
+	if c.breaker == nil {
+		return BreakerClosed
+	}
+	return c.breaker.currentState()
+}
+
+type circuitBreaker struct {
+	mutex       sync.Mutex
+	settings    *BreakerSettings
+	state       BreakerState
+	generation  uint64
+	windowStart time.Time
+	openedAt    time.Time
+	requests    int
+	failures    int
+	probes      int
+	successes   int
+	now         func() time.Time
+}
+
+func (b *circuitBreaker) configure(settings *BreakerSettings) {
+	b.mutex.Lock()
+	if settings != nil {
+		copied := *settings
+		if copied.HalfOpenProbes < 1 {
+			copied.HalfOpenProbes = 1
+		}
+		settings = &copied
+	}
+	b.settings = settings
+	if b.now == nil {
+		b.now = time.Now
+	}
+	from := b.state
+	b.setState(BreakerClosed, b.now())
+	b.mutex.Unlock()
+	b.notify(settings, from, BreakerClosed)
+}
+
+func (b *circuitBreaker) currentState() BreakerState {
+	b.mutex.Lock()
+	settings := b.settings
+	if settings == nil {
+		b.mutex.Unlock()
+		return BreakerClosed
+	}
+	from := b.state
+	b.expire(b.now())
+	to := b.state
+	b.mutex.Unlock()
+	b.notify(settings, from, to)
+	return to
+}
+
+// allow admits a call or rejects it with a *BreakerOpenError. The returned
+// function records the outcome of an admitted call.
+func (b *circuitBreaker) allow() (func(failed bool), error) {
+	if b == nil {
+		return func(bool) {}, nil
+	}
+	b.mutex.Lock()
+	settings := b.settings
+	if settings == nil {
+		b.mutex.Unlock()
+		return func(bool) {}, nil
+	}
+	from := b.state
+	b.expire(b.now())
+	to := b.state
+	var err error
+	switch {
+	case b.state == BreakerOpen:
+		err = &BreakerOpenError{RetryAfter: b.openedAt.Add(settings.OpenTimeout)}
+	case b.state == BreakerHalfOpen && b.probes >= settings.HalfOpenProbes:
+		err = &BreakerOpenError{RetryAfter: b.now()}
+	case b.state == BreakerHalfOpen:
+		b.probes++
+	default:
+		b.requests++
+	}
+	generation := b.generation
+	b.mutex.Unlock()
+	b.notify(settings, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return func(failed bool) { b.record(generation, failed) }, nil
+}
+
+func (b *circuitBreaker) record(generation uint64, failed bool) {
+	b.mutex.Lock()
+	settings := b.settings
+	if settings == nil || generation != b.generation {
+		b.mutex.Unlock()
+		return
+	}
+	from := b.state
+	now := b.now()
+	switch b.state {
+	case BreakerClosed:
+		if failed {
+			b.failures++
+		}
+		ratio := float64(b.failures) / float64(b.requests)
+		if failed && b.requests >= settings.MinRequests && ratio >= settings.FailureRatio {
+			b.setState(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			b.setState(BreakerOpen, now)
+			break
+		}
+		b.successes++
+		if b.successes >= settings.HalfOpenProbes {
+			b.setState(BreakerClosed, now)
+		}
+	}
+	to := b.state
+	b.mutex.Unlock()
+	b.notify(settings, from, to)
+}
+
+// expire moves an open breaker to half-open after the open timeout and
+// starts a new counting window when the current one has passed.
+func (b *circuitBreaker) expire(now time.Time) {
+	switch b.state {
+	case BreakerOpen:
+		if !now.Before(b.openedAt.Add(b.settings.OpenTimeout)) {
+			b.setState(BreakerHalfOpen, now)
+		}
+	case BreakerClosed:
+		if b.settings.Window > 0 && !now.Before(b.windowStart.Add(b.settings.Window)) {
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+	}
+}
+
+// setState enters a state and starts a new generation, so that the outcomes
+// of calls admitted before are ignored.
+func (b *circuitBreaker) setState(state BreakerState, now time.Time) {
+	b.state = state
+	b.generation++
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+}
+
+func (b *circuitBreaker) notify(settings *BreakerSettings, from BreakerState, to BreakerState) {
+	if from != to && settings != nil && settings.OnStateChange != nil {
+		settings.OnStateChange(from, to)
+	}
+}
+
+// breakerFailure decides whether the outcome of a call counts against the
+// API. Calls cancelled by the caller do not.
+func breakerFailure(ctx context.Context, statusCode int, err error) bool {
+	if err != nil {
+		return ctx.Err() != context.Canceled
+	}
+	return statusCode >= 500
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	now := time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+	var transitions []string
+	breaker := &circuitBreaker{now: func() time.Time { return now }}
+	breaker.configure(&BreakerSettings{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Window:       time.Minute,
+		OpenTimeout:  10 * time.Second,
+		OnStateChange: func(from BreakerState, to BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+
+	for _, failed := range []bool{false, true, false, true} {
+		done, err := breaker.allow()
+		if err != nil {
+			t.Fatalf("Error %s while closed", err)
+		}
+		done(failed)
+	}
+	if _, err := breaker.allow(); err == nil {
+		t.Fatalf("Expected breaker to be open")
+	} else if openErr, ok := err.(*BreakerOpenError); !ok || !openErr.RetryAfter.Equal(now.Add(10*time.Second)) {
+		t.Errorf("Expected BreakerOpenError but was %v", err)
+	}
+
+	now = now.Add(10 * time.Second)
+	probe, err := breaker.allow()
+	if err != nil {
+		t.Fatalf("Error %s probing", err)
+	}
+	if _, err = breaker.allow(); err == nil {
+		t.Errorf("Expected a single concurrent probe")
+	}
+	probe(true)
+	if state := breaker.currentState(); state != BreakerOpen {
+		t.Errorf("Expected failed probe to reopen but was %s", state)
+	}
+
+	now = now.Add(10 * time.Second)
+	probe, _ = breaker.allow()
+	probe(false)
+	expected := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected transitions %v but was %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("Expected transition %s but was %s", expected[i], transitions[i])
+		}
+	}
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	now := time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+	breaker := &circuitBreaker{now: func() time.Time { return now }}
+	breaker.configure(&BreakerSettings{FailureRatio: 0.5, MinRequests: 2, Window: time.Minute})
+
+	done, _ := breaker.allow()
+	done(true)
+	now = now.Add(time.Minute)
+	done, _ = breaker.allow()
+	done(true)
+	if state := breaker.currentState(); state != BreakerClosed {
+		t.Errorf("Expected failures in separate windows to keep the breaker closed but was %s", state)
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	settings := DefaultBreakerSettings()
+	settings.MinRequests = 2
+	client.SetCircuitBreaker(&settings)
+	for i := 0; i < 3; i++ {
+		client.GetMetrics(nil)
+	}
+	_, err := client.GetMetrics(nil)
+	if _, ok := err.(*BreakerOpenError); !ok {
+		t.Errorf("Expected BreakerOpenError but was %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls to reach the API but was %d", calls)
+	}
+	if state := client.CircuitBreakerState(); state != BreakerOpen {
+		t.Errorf("Expected breaker to be open but was %s", state)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"container/list"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures the read-through cache of GetMetricNames,
+// GetDimensionNames, GetDimensionValues and GetAlarmDefinition. Responses are
+// served from the cache for TTL after they were fetched, and for StaleTTL
+// after that while they are refreshed in the background. At most MaxEntries
+// responses are kept, the least recently used are evicted first; zero means
+// no limit.
+type CacheOptions struct {
+	TTL        time.Duration
+	StaleTTL   time.Duration
+	MaxEntries int
+}
+
+// cachedOperations maps the cached operations to the group of entries their
+// responses belong to.
+var cachedOperations = map[string]string{
+	OperationGetMetricNames:     "metrics",
+	OperationGetDimensionNames:  "metrics",
+	OperationGetDimensionValues: "metrics",
+	OperationGetAlarmDefinition: "alarm-definitions",
+}
+
+func SetCache(options *CacheOptions) {
+	monClient.SetCache(options)
+}
+
+func InvalidateCache() {
+	monClient.InvalidateCache()
+}
+
+func InvalidateTenantCache(tenantID string) {
+	monClient.InvalidateTenantCache(tenantID)
+}
+
+// SetCache enables the cache, nil disables it. Either way the cache is
+// emptied. It is shared with WithContext views of the client.
+//
+// Entries are keyed by URL and tenant, including that of ForTenant views. A
+// successful CreateMetrics invalidates the metric and dimension name entries
+// of its tenant and any change to an alarm definition invalidates the cached
+// alarm definitions.
+func (c *Client) SetCache(options *CacheOptions) {
+	if c.cache == nil {
+		c.cache = &responseCache{}
+	}
+	c.cache.configure(options)
+}
+
+// InvalidateCache empties the cache.
+func (c *Client) InvalidateCache() {
+	c.cache.invalidate(func(*cacheEntry) bool { return true })
+}
+
+// InvalidateTenantCache drops the entries of a tenant, "" being the tenant
+// the client authenticates as.
+func (c *Client) InvalidateTenantCache(tenantID string) {
+	c.cache.invalidate(func(entry *cacheEntry) bool { return entry.tenantID == tenantID })
+}
+
+// cachedGet returns the body of a successful GET, from the cache if the
+// operation is cached.
+func (c *Client) cachedGet(monascaURL string) ([]byte, error) {
+	if !c.cache.enabled() {
+		return c.callMonascaReturnBody(monascaURL, "GET", nil)
+	}
+	requestURL, err := url.Parse(monascaURL)
+	if err != nil {
+		return nil, err
+	}
+	group, cached := cachedOperations[operationName("GET", requestURL)]
+	if !cached {
+		return c.callMonascaReturnBody(monascaURL, "GET", nil)
+	}
+
+	fetch := func() ([]byte, error) {
+		return c.callMonascaReturnBody(monascaURL, "GET", nil)
+	}
+	refresh := func() ([]byte, error) {
+		// Not bound to the context of the call that found the entry stale
+		detached := *c
+		detached.ctx = nil
+		return detached.callMonascaReturnBody(monascaURL, "GET", nil)
+	}
+	tenantID := requestURL.Query().Get("tenant_id")
+	if c.tenantID != "" {
+		tenantID = c.tenantID
+	}
+	return c.cache.get(tenantID+" "+monascaURL, group, tenantID, fetch, refresh)
+}
+
+// invalidateAfter drops the entries a successful mutating call may have made
+// out of date.
+func (c *Client) invalidateAfter(operation Operation) {
+	if operation.Method == "GET" || !c.cache.enabled() {
+		return
+	}
+	switch {
+	case operation.Name == OperationCreateMetrics:
+		c.cache.invalidate(func(entry *cacheEntry) bool {
+			return entry.group == "metrics" && entry.tenantID == operation.TenantID
+		})
+	case strings.HasSuffix(operation.Name, "AlarmDefinition"):
+		c.cache.invalidate(func(entry *cacheEntry) bool { return entry.group == "alarm-definitions" })
+	}
+}
+
+type cacheEntry struct {
+	key        string
+	group      string
+	tenantID   string
+	body       []byte
+	fetched    time.Time
+	refreshing bool
+	element    *list.Element
+}
+
+type responseCache struct {
+	mutex   sync.Mutex
+	options *CacheOptions
+	entries map[string]*cacheEntry
+	// recent orders the entries from most to least recently used
+	recent *list.List
+	// generation changes with every invalidation, so that responses fetched
+	// before are not stored
+	generation uint64
+	now        func() time.Time
+}
+
+func (r *responseCache) configure(options *CacheOptions) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if options != nil {
+		copied := *options
+		options = &copied
+	}
+	r.options = options
+	r.generation++
+	r.entries = map[string]*cacheEntry{}
+	r.recent = list.New()
+	if r.now == nil {
+		r.now = time.Now
+	}
+}
+
+func (r *responseCache) enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.options != nil
+}
+
+func (r *responseCache) get(key string, group string, tenantID string, fetch func() ([]byte, error),
+	refresh func() ([]byte, error)) ([]byte, error) {
+	r.mutex.Lock()
+	generation := r.generation
+	entry, found := r.entries[key]
+	if found && r.options != nil {
+		age := r.now().Sub(entry.fetched)
+		if age < r.options.TTL+r.options.StaleTTL {
+			r.recent.MoveToFront(entry.element)
+			body := entry.body
+			if age >= r.options.TTL && !entry.refreshing {
+				entry.refreshing = true
+				go r.refresh(entry, generation, refresh)
+			}
+			r.mutex.Unlock()
+			return body, nil
+		}
+	}
+	r.mutex.Unlock()
+
+	body, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	r.store(key, group, tenantID, body, generation)
+	return body, nil
+}
+
+func (r *responseCache) refresh(entry *cacheEntry, generation uint64, refresh func() ([]byte, error)) {
+	body, err := refresh()
+	r.mutex.Lock()
+	entry.refreshing = false
+	r.mutex.Unlock()
+	if err == nil {
+		r.store(entry.key, entry.group, entry.tenantID, body, generation)
+	}
+}
+
+func (r *responseCache) store(key string, group string, tenantID string, body []byte, generation uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.options == nil || generation != r.generation {
+		return
+	}
+	if entry, found := r.entries[key]; found {
+		r.recent.Remove(entry.element)
+	}
+	entry := &cacheEntry{key: key, group: group, tenantID: tenantID, body: body, fetched: r.now()}
+	entry.element = r.recent.PushFront(entry)
+	r.entries[key] = entry
+	for r.options.MaxEntries > 0 && len(r.entries) > r.options.MaxEntries {
+		oldest := r.recent.Back()
+		r.recent.Remove(oldest)
+		delete(r.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (r *responseCache) invalidate(matches func(*cacheEntry) bool) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.generation++
+	for key, entry := range r.entries {
+		if matches(entry) {
+			r.recent.Remove(entry.element)
+			delete(r.entries, key)
+		}
+	}
+}
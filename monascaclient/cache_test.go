@@ -0,0 +1,142 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type namesServer struct {
+	mutex sync.Mutex
+	calls int
+	names string
+}
+
+func (s *namesServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.calls++
+	if r.Method == "POST" {
+		w.WriteHeader(204)
+		return
+	}
+	w.Write([]byte(`{"links": [], "elements": [{"name": "` + s.names + `"}]}`))
+}
+
+func (s *namesServer) callCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.calls
+}
+
+func TestCacheTTLAndTenants(t *testing.T) {
+	handler := &namesServer{names: "cpu"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	now := time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.cache.now = func() time.Time { return now }
+	client.SetCache(&CacheOptions{TTL: time.Minute})
+
+	tenantID := "other"
+	for i := 0; i < 3; i++ {
+		client.GetMetricNames(nil)
+	}
+	client.GetMetricNames(&models.MetricNameQuery{TenantID: &tenantID})
+	if calls := handler.callCount(); calls != 2 {
+		t.Errorf("Expected one call per tenant but was %d", calls)
+	}
+
+	client.GetMeasurements(&models.MeasurementQuery{})
+	client.GetMeasurements(&models.MeasurementQuery{})
+	if calls := handler.callCount(); calls != 4 {
+		t.Errorf("Expected measurements not to be cached but was %d calls", calls)
+	}
+
+	now = now.Add(time.Minute)
+	client.GetMetricNames(nil)
+	if calls := handler.callCount(); calls != 5 {
+		t.Errorf("Expected expired entry to be fetched but was %d calls", calls)
+	}
+
+	client.InvalidateTenantCache(tenantID)
+	client.GetMetricNames(&models.MetricNameQuery{TenantID: &tenantID})
+	if calls := handler.callCount(); calls != 6 {
+		t.Errorf("Expected invalidated entry to be fetched but was %d calls", calls)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	handler := &namesServer{names: "cpu"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	now := time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.cache.now = func() time.Time { return now }
+	client.SetCache(&CacheOptions{TTL: time.Minute, StaleTTL: time.Hour})
+	client.GetMetricNames(nil)
+
+	handler.mutex.Lock()
+	handler.names = "mem"
+	handler.mutex.Unlock()
+	now = now.Add(2 * time.Minute)
+	names, _ := client.GetMetricNames(nil)
+	if len(names) != 1 || names[0] != "cpu" {
+		t.Errorf("Expected stale names but was %v", names)
+	}
+	for i := 0; i < 100 && handler.callCount() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 100; i++ {
+		if names, _ = client.GetMetricNames(nil); names[0] == "mem" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if names[0] != "mem" || handler.callCount() != 2 {
+		t.Errorf("Expected a single background refresh but was %v after %d calls", names, handler.callCount())
+	}
+}
+
+func TestCacheInvalidationAndEviction(t *testing.T) {
+	handler := &namesServer{names: "cpu"}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetCache(&CacheOptions{TTL: time.Hour, MaxEntries: 1})
+	client.GetMetricNames(nil)
+	client.CreateMetric(nil, &models.MetricRequestBody{})
+	client.GetMetricNames(nil)
+	if calls := handler.callCount(); calls != 3 {
+		t.Errorf("Expected CreateMetric to invalidate metric names but was %d calls", calls)
+	}
+
+	client.GetDimensionNames(&models.DimensionNameQuery{})
+	client.GetMetricNames(nil)
+	if calls := handler.callCount(); calls != 5 {
+		t.Errorf("Expected least recently used entry to be evicted but was %d calls", calls)
+	}
+}
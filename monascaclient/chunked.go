@@ -0,0 +1,290 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultChunkParallelism = 4
+
+// ChunkOptions splits a long query into queries of at most ChunkSize, of which
+// Parallelism run at a time, 4 if zero.
+type ChunkOptions struct {
+	ChunkSize   time.Duration
+	Parallelism int
+}
+
+// chunkSeries is a series of measurements or statistics from one chunk.
+type chunkSeries struct {
+	id         string
+	name       string
+	dimensions map[string]string
+	columns    []string
+	rows       [][]interface{}
+}
+
+func GetMeasurementsChunked(measurementQuery *models.MeasurementQuery, options ChunkOptions) (*models.MeasurementsResponse, error) {
+	return monClient.GetMeasurementsChunked(measurementQuery, options)
+}
+
+func GetStatisticsChunked(statisticsQuery *models.StatisticQuery, options ChunkOptions) (*models.StatisticsResponse, error) {
+	return monClient.GetStatisticsChunked(statisticsQuery, options)
+}
+
+// GetMeasurementsChunked runs a measurement query as concurrent queries over
+// consecutive parts of its time range, following the pages of each, and
+// stitches the results into one series per name and dimensions. StartTime is
+// required, a missing EndTime means now.
+func (c *Client) GetMeasurementsChunked(measurementQuery *models.MeasurementQuery, options ChunkOptions) (*models.MeasurementsResponse, error) {
+	if measurementQuery == nil || measurementQuery.StartTime == nil {
+		return nil, fmt.Errorf("Chunked queries require a start time")
+	}
+	chunks, err := timeChunks(*measurementQuery.StartTime, measurementQuery.EndTime, options.ChunkSize, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := c.fanOut(len(chunks), options.Parallelism, func(view *Client, index int) ([]chunkSeries, error) {
+		query := *measurementQuery
+		query.StartTime = &chunks[index][0]
+		query.EndTime = &chunks[index][1]
+		query.Offset = nil
+		var series []chunkSeries
+		err := view.ForEachMeasurementPage(&query, func(response *models.MeasurementsResponse) error {
+			for _, element := range response.Elements {
+				series = append(series, chunkSeries{element.ID, element.Name, element.Dimensions, element.Columns, element.Measurements})
+			}
+			return nil
+		})
+		return series, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.MeasurementsResponse{Links: []models.Link{}, Elements: []models.MeasurementElement{}}
+	for _, s := range stitchSeries(results) {
+		response.Elements = append(response.Elements, models.MeasurementElement{
+			ID: s.id, Name: s.name, Dimensions: s.dimensions, Columns: s.columns, Measurements: s.rows,
+		})
+	}
+	return response, nil
+}
+
+// GetStatisticsChunked runs a statistics query like GetMeasurementsChunked.
+// The chunk size is rounded up to a multiple of Period, so that every chunk
+// starts on the boundary of a statistics period.
+func (c *Client) GetStatisticsChunked(statisticsQuery *models.StatisticQuery, options ChunkOptions) (*models.StatisticsResponse, error) {
+	if statisticsQuery == nil || statisticsQuery.StartTime == nil {
+		return nil, fmt.Errorf("Chunked queries require a start time")
+	}
+	period := 300 * time.Second
+	if statisticsQuery.Period != nil {
+		period = time.Duration(*statisticsQuery.Period) * time.Second
+	}
+	chunks, err := timeChunks(*statisticsQuery.StartTime, statisticsQuery.EndTime, options.ChunkSize, period)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := c.fanOut(len(chunks), options.Parallelism, func(view *Client, index int) ([]chunkSeries, error) {
+		query := *statisticsQuery
+		query.StartTime = &chunks[index][0]
+		query.EndTime = &chunks[index][1]
+		query.Offset = nil
+		var series []chunkSeries
+		err := view.ForEachStatisticPage(&query, func(response *models.StatisticsResponse) error {
+			for _, element := range response.Elements {
+				series = append(series, chunkSeries{element.ID, element.Name, element.Dimensions, element.Columns, element.Statistics})
+			}
+			return nil
+		})
+		return series, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.StatisticsResponse{Links: []models.Link{}, Elements: []models.StatisticElement{}}
+	for _, s := range stitchSeries(results) {
+		response.Elements = append(response.Elements, models.StatisticElement{
+			ID: s.id, Name: s.name, Dimensions: s.dimensions, Columns: s.columns, Statistics: s.rows,
+		})
+	}
+	return response, nil
+}
+
+// timeChunks splits [start, end] into consecutive ranges of chunkSize,
+// rounded up to whole seconds and to a multiple of period if given. Adjacent
+// ranges share their boundary.
+func timeChunks(start time.Time, end *time.Time, chunkSize time.Duration, period time.Duration) ([][2]time.Time, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("Chunk size must be positive")
+	}
+	until := time.Now()
+	if end != nil {
+		until = *end
+	}
+	if !until.After(start) {
+		return nil, fmt.Errorf("End time %v is not after start time %v", until, start)
+	}
+	step := time.Second
+	if period > step {
+		step = period
+	}
+	if remainder := chunkSize % step; remainder != 0 {
+		chunkSize += step - remainder
+	}
+
+	var chunks [][2]time.Time
+	for from := start; from.Before(until); from = from.Add(chunkSize) {
+		to := from.Add(chunkSize)
+		if to.After(until) {
+			to = until
+		}
+		chunks = append(chunks, [2]time.Time{from, to})
+	}
+	return chunks, nil
+}
+
+// fanOut calls query for every chunk with at most parallelism calls at a
+// time, cancelling the others once one fails.
+func (c *Client) fanOut(chunks int, parallelism int, query func(view *Client, index int) ([]chunkSeries, error)) ([][]chunkSeries, error) {
+	if parallelism <= 0 {
+		parallelism = defaultChunkParallelism
+	}
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	view := c.WithContext(ctx)
+
+	results := make([][]chunkSeries, chunks)
+	var firstErr error
+	var errOnce sync.Once
+	var wait sync.WaitGroup
+	slots := make(chan struct{}, parallelism)
+	for index := 0; index < chunks; index++ {
+		wait.Add(1)
+		go func(index int) {
+			defer wait.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+			series, err := query(view, index)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[index] = series
+		}(index)
+	}
+	wait.Wait()
+	if firstErr == nil && parent.Err() != nil {
+		return nil, parent.Err()
+	}
+	return results, firstErr
+}
+
+// stitchSeries joins the series of consecutive chunks by name and dimensions.
+// A row of a later chunk replaces the rows of earlier ones from its timestamp
+// on, which removes the duplicate at a shared boundary and, for statistics,
+// the partial period ending a chunk.
+func stitchSeries(chunks [][]chunkSeries) []chunkSeries {
+	var stitched []*chunkSeries
+	byKey := map[string]*chunkSeries{}
+	for _, chunk := range chunks {
+		for _, series := range chunk {
+			key := seriesKey(series.name, series.dimensions)
+			existing, found := byKey[key]
+			if !found {
+				copied := series
+				copied.rows = append([][]interface{}(nil), series.rows...)
+				byKey[key] = &copied
+				stitched = append(stitched, &copied)
+				continue
+			}
+			if len(series.rows) == 0 {
+				continue
+			}
+			column := timestampColumn(existing.columns)
+			first, ok := rowTime(series.rows[0], column)
+			keep := len(existing.rows)
+			for ok && keep > 0 {
+				last, lastOk := rowTime(existing.rows[keep-1], column)
+				if !lastOk || last.Before(first) {
+					break
+				}
+				keep--
+			}
+			existing.rows = append(existing.rows[:keep], series.rows...)
+		}
+	}
+
+	results := make([]chunkSeries, len(stitched))
+	for i, series := range stitched {
+		results[i] = *series
+	}
+	return results
+}
+
+func seriesKey(name string, dimensions map[string]string) string {
+	keys := make([]string, 0, len(dimensions))
+	for key := range dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := []string{name}
+	for _, key := range keys {
+		parts = append(parts, key+"="+dimensions[key])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func timestampColumn(columns []string) int {
+	for i, column := range columns {
+		if column == "timestamp" {
+			return i
+		}
+	}
+	return 0
+}
+
+func rowTime(row []interface{}, column int) (time.Time, bool) {
+	if column >= len(row) {
+		return time.Time{}, false
+	}
+	value, ok := row[column].(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	return parsed, err == nil
+}
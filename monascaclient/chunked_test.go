@@ -0,0 +1,135 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"encoding/json"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// minuteServer returns a point per minute from start_time to end_time
+// inclusive, the way the API treats both ends.
+func minuteServer(mutex *sync.Mutex, ranges *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := time.Parse(time.RFC3339, r.URL.Query().Get("start_time"))
+		end, _ := time.Parse(time.RFC3339, r.URL.Query().Get("end_time"))
+		mutex.Lock()
+		*ranges = append(*ranges, r.URL.Query().Get("start_time")+"/"+r.URL.Query().Get("end_time"))
+		mutex.Unlock()
+
+		var rows [][]interface{}
+		for at := start; !at.After(end); at = at.Add(time.Minute) {
+			rows = append(rows, []interface{}{at.Format(time.RFC3339), float64(at.Minute())})
+		}
+		element := map[string]interface{}{
+			"id": "0", "name": "cpu", "dimensions": map[string]string{"hostname": "a"},
+			"columns": []string{"timestamp", "value"}, "measurements": rows, "statistics": rows,
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"links": []string{}, "elements": []interface{}{element}})
+	}))
+}
+
+func TestGetMeasurementsChunked(t *testing.T) {
+	var mutex sync.Mutex
+	var ranges []string
+	server := minuteServer(&mutex, &ranges)
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	start := time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	response, err := client.GetMeasurementsChunked(&models.MeasurementQuery{StartTime: &start, EndTime: &end},
+		ChunkOptions{ChunkSize: 25 * time.Minute, Parallelism: 2})
+	if err != nil {
+		t.Fatalf("Error %s getting measurements", err)
+	}
+	if len(ranges) != 3 {
+		t.Errorf("Expected 3 chunks but was %v", ranges)
+	}
+	if len(response.Elements) != 1 {
+		t.Fatalf("Expected a single stitched series but was %d", len(response.Elements))
+	}
+	rows := response.Elements[0].Measurements
+	if len(rows) != 61 {
+		t.Errorf("Expected 61 points without duplicates but was %d", len(rows))
+	}
+	for i := 1; i < len(rows); i++ {
+		if rows[i][0].(string) <= rows[i-1][0].(string) {
+			t.Fatalf("Expected increasing timestamps but was %v after %v", rows[i][0], rows[i-1][0])
+		}
+	}
+}
+
+func TestGetStatisticsChunkedAlignment(t *testing.T) {
+	var mutex sync.Mutex
+	var ranges []string
+	server := minuteServer(&mutex, &ranges)
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	start := time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	period := 600
+	_, err := client.GetStatisticsChunked(&models.StatisticQuery{StartTime: &start, EndTime: &end, Period: &period},
+		ChunkOptions{ChunkSize: 25 * time.Minute})
+	if err != nil {
+		t.Fatalf("Error %s getting statistics", err)
+	}
+	expected := map[string]bool{
+		"2017-02-27T06:00:00Z/2017-02-27T06:30:00Z": true,
+		"2017-02-27T06:30:00Z/2017-02-27T07:00:00Z": true,
+	}
+	if len(ranges) != 2 || !expected[ranges[0]] || !expected[ranges[1]] {
+		t.Errorf("Expected chunks aligned to 10 minute periods but was %v", ranges)
+	}
+}
+
+func TestChunkedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	if _, err := client.GetMeasurementsChunked(&models.MeasurementQuery{}, ChunkOptions{ChunkSize: time.Hour}); err == nil {
+		t.Errorf("Expected error without start time")
+	}
+	start := time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	if _, err := client.GetMeasurementsChunked(&models.MeasurementQuery{StartTime: &start, EndTime: &end},
+		ChunkOptions{ChunkSize: time.Hour}); err == nil {
+		t.Errorf("Expected server error")
+	}
+}
+
+func TestStitchStatisticsBoundary(t *testing.T) {
+	columns := []string{"timestamp", "avg"}
+	chunks := [][]chunkSeries{
+		{{name: "cpu", columns: columns, rows: [][]interface{}{{"2017-02-27T06:00:00Z", 1.0}, {"2017-02-27T06:10:00Z", 9.0}}}},
+		{{name: "cpu", columns: columns, rows: [][]interface{}{{"2017-02-27T06:10:00Z", 2.0}, {"2017-02-27T06:20:00Z", 3.0}}}},
+	}
+	stitched := stitchSeries(chunks)
+	if len(stitched) != 1 || len(stitched[0].rows) != 3 || stitched[0].rows[1][1] != 2.0 {
+		t.Errorf("Expected partial boundary period to be replaced but was %+v", stitched)
+	}
+}
@@ -16,11 +16,13 @@ package monascaclient
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -40,6 +42,11 @@ var (
 		requestTimeout: defaultTimeout,
 		allowInsecure:  defaultInsecure,
 		headers:        http.Header{},
+		limiters:       &limiterSet{},
+		breaker:        &circuitBreaker{},
+		endpoints:      &endpointPool{},
+		compression:    &compressor{},
+		cache:          &responseCache{},
 	}
 )
 
@@ -76,11 +83,22 @@ func SetKeystoneConfig(config *gophercloud.AuthOptions) {
 }
 
 type Client struct {
-	baseURL        string
-	requestTimeout int
-	allowInsecure  bool
-	headers        http.Header
-	keystoneConfig *gophercloud.AuthOptions
+	baseURL         string
+	requestTimeout  int
+	allowInsecure   bool
+	headers         http.Header
+	keystoneConfig  *gophercloud.AuthOptions
+	ctx             context.Context
+	logger          Logger
+	logOptions      LogOptions
+	instrumentation Instrumentation
+	limiters        *limiterSet
+	breaker         *circuitBreaker
+	endpoints       *endpointPool
+	compression     *compressor
+	maxResponseSize int64
+	cache           *responseCache
+	tenantID        string
 }
 
 func New() *Client {
@@ -89,11 +107,21 @@ func New() *Client {
 		requestTimeout: defaultTimeout,
 		allowInsecure:  defaultInsecure,
 		headers:        http.Header{},
+		limiters:       &limiterSet{},
+		breaker:        &circuitBreaker{},
+		endpoints:      &endpointPool{},
+		compression:    &compressor{},
+		cache:          &responseCache{},
 	}
 }
 
+// SetBaseURL sets the single endpoint of the API, replacing any set by
+// SetEndpoints.
 func (c *Client) SetBaseURL(url string) {
 	c.baseURL = url
+	if c.endpoints != nil {
+		c.endpoints.set(nil)
+	}
 }
 
 func (c *Client) SetInsecure(insecure bool) {
@@ -120,7 +148,113 @@ func (c *Client) SetKeystoneConfig(config *gophercloud.AuthOptions) error {
 	return nil
 }
 
-func (c *Client) callMonasca(monascaURL string, method string, requestBody *[]byte) (*http.Response, error) {
+// WithContext returns a shallow copy of the client whose requests are bound to
+// ctx. Headers and the keystone configuration are shared with the original.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// roundTrip makes an API call, reporting it to the instrumentation if set,
+// and returns the status code and body of the final response.
+func (c *Client) roundTrip(monascaURL string, method string, requestBody *[]byte) (int, []byte, error) {
+	return c.roundTripStream(monascaURL, method, requestBody, nil)
+}
+
+// roundTripStream is roundTrip passing the body of a 200 response to stream
+// instead of returning it.
+func (c *Client) roundTripStream(monascaURL string, method string, requestBody *[]byte, stream func(io.Reader) error) (int, []byte, error) {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	requestURL, err := url.Parse(monascaURL)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = c.applyTenant(method, requestURL); err != nil {
+		return 0, nil, err
+	}
+	operation := newOperation(method, requestURL)
+	if c.tenantID != "" {
+		operation.TenantID = c.tenantID
+	}
+	header := http.Header{}
+	var finish func(OperationResult)
+	if c.instrumentation != nil {
+		ctx, finish = c.instrumentation.StartOperation(ctx, operation, header)
+	}
+
+	start := time.Now()
+	result := OperationResult{ResultCount: -1}
+	body, err := c.send(ctx, operation, requestBody, header, stream, &result)
+	if err == nil && result.StatusCode < 300 {
+		c.invalidateAfter(operation)
+	}
+	if finish != nil {
+		result.Err = err
+		result.Latency = time.Since(start)
+		if err == nil && result.StatusCode < 300 {
+			result.ResultCount = resultCount(body)
+		}
+		finish(result)
+	}
+	return result.StatusCode, body, err
+}
+
+// send makes the call once the client's limits and circuit breaker allow
+// it.
+func (c *Client) send(ctx context.Context, operation Operation, requestBody *[]byte, header http.Header,
+	stream func(io.Reader) error, result *OperationResult) ([]byte, error) {
+	release, err := c.limiters.acquire(ctx, operation)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	done, err := c.breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := 1
+	if idempotent(operation.Method) {
+		attempts = c.endpoints.size()
+	}
+	tried := map[*endpoint]bool{}
+	var body []byte
+	for {
+		endpoint := c.endpoints.pick(tried)
+		tried[endpoint] = true
+		result.StatusCode = 0
+		start := time.Now()
+		var resp *http.Response
+		var streamErr error
+		resp, err = c.callMonasca(ctx, endpoint.resolve(operation.URL), operation.Method, requestBody, header, result)
+		if err == nil {
+			result.StatusCode = resp.StatusCode
+			reader := c.limitResponse(resp.Body)
+			if stream != nil && resp.StatusCode == 200 {
+				streamErr = stream(reader)
+			} else {
+				body, err = ioutil.ReadAll(reader)
+			}
+			resp.Body.Close()
+		}
+		failed := breakerFailure(ctx, result.StatusCode, err)
+		c.endpoints.report(endpoint, failed, time.Since(start))
+		if !failed || len(tried) >= attempts || ctx.Err() != nil {
+			done(failed)
+			if streamErr != nil {
+				return nil, streamErr
+			}
+			return body, err
+		}
+	}
+}
+
+func (c *Client) callMonasca(ctx context.Context, monascaURL string, method string, requestBody *[]byte,
+	header http.Header, result *OperationResult) (*http.Response, error) {
 	var req *http.Request
 	var reqErr error
 
@@ -133,34 +267,95 @@ func (c *Client) callMonasca(monascaURL string, method string, requestBody *[]by
 	if reqErr != nil {
 		return nil, reqErr
 	}
+	req = req.WithContext(ctx)
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	c.applyHeaders(req)
-
-	timeout := time.Duration(c.requestTimeout) * time.Second
-	var client *http.Client
-	if !c.allowInsecure {
-		client = &http.Client{Timeout: timeout}
-	} else {
-		transCfg := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // ignore expired SSL certificates
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	// A ForTenant view fetches its project-scoped token before the first call
+	if c.keystoneConfig != nil && c.tenantID != "" && c.headers.Get("X-Auth-Token") == "" {
+		if err := c.setKeystoneToken(); err != nil {
+			return nil, fmt.Errorf("Failed to get token for tenant %s: %v", c.tenantID, err)
 		}
+	}
+	c.applyHeaders(req)
 
-		client = &http.Client{Timeout: timeout, Transport: transCfg}
+	sentBody := requestBody
+	compressed, err := c.compression.compress(requestBody)
+	if err != nil {
+		return nil, err
 	}
-	resp, respErr := client.Do(req)
+	if compressed != nil {
+		sentBody = compressed
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	client := c.httpClient()
+	result.Attempts++
+	resp, respErr := c.do(client, req, sentBody, requestBody)
 
 	// If response is 401, check for expired token and retry
 	if respErr == nil && resp != nil && resp.StatusCode == 401 && c.keystoneConfig != nil {
+		resp.Body.Close()
 		c.setKeystoneToken()
 		c.applyHeaders(req)
-		resp, respErr = client.Do(req)
+		result.Attempts++
+		result.Reauthenticated = true
+		resp, respErr = c.do(client, req, sentBody, requestBody)
+	}
+
+	// If the server does not take compressed bodies, resend uncompressed
+	if respErr == nil && compressed != nil && c.compression.rejected(resp.StatusCode) {
+		rejectedStatus := resp.StatusCode
+		resp.Body.Close()
+		req.Header.Del("Content-Encoding")
+		result.Attempts++
+		resp, respErr = c.do(client, req, requestBody, requestBody)
+		if respErr == nil {
+			c.compression.fallback(rejectedStatus, resp.StatusCode)
+		}
 	}
 
 	return resp, respErr
 }
 
+func (c *Client) httpClient() *http.Client {
+	timeout := time.Duration(c.requestTimeout) * time.Second
+	if !c.allowInsecure {
+		return &http.Client{Timeout: timeout}
+	}
+	transCfg := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // ignore expired SSL certificates
+	}
+	return &http.Client{Timeout: timeout, Transport: transCfg}
+}
+
+// do sends the request with a fresh copy of sentBody, so that it can be
+// retried, and logs the attempt with the uncompressed requestBody.
+func (c *Client) do(client *http.Client, req *http.Request, sentBody *[]byte, requestBody *[]byte) (*http.Response, error) {
+	if sentBody != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(*sentBody))
+		req.ContentLength = int64(len(*sentBody))
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err == nil {
+		err = decompressResponse(resp)
+	}
+	if c.logger == nil {
+		return resp, err
+	}
+
+	var responseBody []byte
+	if err == nil && c.logOptions.Bodies {
+		responseBody, err = c.peekResponse(resp)
+	}
+	c.logRequest(req, requestBody, resp, responseBody, time.Since(start), err)
+	return resp, err
+}
+
 func (c *Client) applyHeaders(req *http.Request) {
 	for header, values := range c.headers {
 		for index := range values {
@@ -174,17 +369,12 @@ func (c *Client) applyHeaders(req *http.Request) {
 }
 
 func (c *Client) callMonascaNoContent(monascaURL string, method string, requestBody *[]byte) error {
-	resp, err := c.callMonasca(monascaURL, method, requestBody)
-	if err != nil || resp == nil {
-		return err
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	statusCode, body, err := c.roundTrip(monascaURL, method, requestBody)
 	if err != nil {
 		return err
 	}
-	if resp.StatusCode != 204 {
-		return fmt.Errorf("Error: %d %s", resp.StatusCode, body)
+	if statusCode != 204 {
+		return fmt.Errorf("Error: %d %s", statusCode, body)
 	}
 	return nil
 }
@@ -199,14 +389,21 @@ func makePath(basePath string, id string) string {
 
 func (c *Client) callMonascaGet(basePath string, id string, queryStruct interface{}, returned interface{}) error {
 
-	urlValues := convertStructToQueryParameters(queryStruct)
+	urlValues, err := convertStructToQueryParameters(queryStruct)
+	if err != nil {
+		return err
+	}
+
+	return c.callMonascaGetValues(makePath(basePath, id), urlValues, returned)
+}
 
-	monascaURL, URLerr := c.createMonascaAPIURL(makePath(basePath, id), urlValues)
+func (c *Client) callMonascaGetValues(path string, urlValues url.Values, returned interface{}) error {
+	monascaURL, URLerr := c.createMonascaAPIURL(path, urlValues)
 	if URLerr != nil {
 		return URLerr
 	}
 
-	body, monascaErr := c.callMonascaReturnBody(monascaURL, "GET", nil)
+	body, monascaErr := c.cachedGet(monascaURL)
 	if monascaErr != nil {
 		return monascaErr
 	}
@@ -251,18 +448,12 @@ func (c *Client) callMonascaDelete(path string, id string) error {
 }
 
 func (c *Client) callMonascaReturnBody(monascaURL string, method string, requestBody *[]byte) ([]byte, error) {
-	resp, err := c.callMonasca(monascaURL, method, requestBody)
-	if err != nil || resp == nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	statusCode, body, err := c.roundTrip(monascaURL, method, requestBody)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != 200 && resp.StatusCode != 201 {
-		return nil, fmt.Errorf("Error: %d %s", resp.StatusCode, body)
+	if statusCode != 200 && statusCode != 201 {
+		return nil, fmt.Errorf("Error: %d %s", statusCode, body)
 	}
 
 	return body, nil
@@ -0,0 +1,145 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type compressionSupport int
+
+const (
+	compressionUnknown compressionSupport = iota
+	compressionSupported
+	compressionUnsupported
+)
+
+func SetRequestCompression(threshold int) {
+	monClient.SetRequestCompression(threshold)
+}
+
+// SetRequestCompression gzips request bodies larger than threshold bytes, a
+// threshold of zero or less disables it. Many Monasca deployments do not
+// accept compressed bodies, so the first compressed request doubles as a
+// capability check: should the server reject it with a 400 or 415 status it
+// is resent uncompressed, and compression is not used again if that succeeds.
+// A 400 for both tells the body was at fault, and later 400s are not resent.
+// Compressed responses are decompressed regardless of this setting.
+func (c *Client) SetRequestCompression(threshold int) {
+	if c.compression == nil {
+		c.compression = &compressor{}
+	}
+	c.compression.mutex.Lock()
+	c.compression.threshold = threshold
+	c.compression.support = compressionUnknown
+	c.compression.mutex.Unlock()
+}
+
+type compressor struct {
+	mutex     sync.Mutex
+	threshold int
+	support   compressionSupport
+}
+
+// compress returns the gzipped body, or nil if it should be sent as is.
+func (c *compressor) compress(requestBody *[]byte) (*[]byte, error) {
+	if c == nil || requestBody == nil {
+		return nil, nil
+	}
+	c.mutex.Lock()
+	enabled := c.threshold > 0 && len(*requestBody) > c.threshold && c.support != compressionUnsupported
+	c.mutex.Unlock()
+	if !enabled {
+		return nil, nil
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(*requestBody); err != nil {
+		return nil, fmt.Errorf("Failed to compress request: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("Failed to compress request: %v", err)
+	}
+	compressed := buffer.Bytes()
+	return &compressed, nil
+}
+
+// rejected decides, from the response to a compressed request, whether it
+// should be resent uncompressed. Once the server has accepted a compressed
+// request only a 415 status is taken as a rejection.
+func (c *compressor) rejected(statusCode int) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch {
+	case statusCode == http.StatusUnsupportedMediaType:
+		return true
+	case statusCode == http.StatusBadRequest:
+		return c.support == compressionUnknown
+	case statusCode < 300:
+		c.support = compressionSupported
+	}
+	return false
+}
+
+// fallback records the outcome of resending a request, rejected with
+// rejectedStatus, uncompressed. Success means the server does not take
+// compressed bodies. A 400 for both means the body itself was at fault, so
+// compression is taken as supported and later 400s are not resent.
+func (c *compressor) fallback(rejectedStatus int, statusCode int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch {
+	case statusCode < 300:
+		c.support = compressionUnsupported
+	case rejectedStatus == http.StatusBadRequest && statusCode == http.StatusBadRequest && c.support == compressionUnknown:
+		c.support = compressionSupported
+	}
+}
+
+// decompressResponse decodes gzipped responses that the transport left
+// encoded, which it does when the caller asked for them itself.
+func decompressResponse(resp *http.Response) error {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return fmt.Errorf("Failed to decompress response: %v", err)
+	}
+	resp.Body = &gzipBody{Reader: reader, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
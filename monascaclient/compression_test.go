@@ -0,0 +1,146 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func largeMetrics() []models.MetricRequestBody {
+	name := "cpu.idle_perc"
+	value := 42.0
+	timestamp := int64(1487822400000)
+	dimensions := map[string]string{"hostname": "compute-node-1", "service": "monitoring"}
+	metrics := make([]models.MetricRequestBody, 50)
+	for i := range metrics {
+		metrics[i] = models.MetricRequestBody{
+			Name:       &name,
+			Value:      &value,
+			Timestamp:  &timestamp,
+			Dimensions: &dimensions,
+		}
+	}
+	return metrics
+}
+
+func TestRequestCompression(t *testing.T) {
+	var received []models.MetricRequestBody
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		json.NewDecoder(reader).Decode(&received)
+		w.WriteHeader(204)
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetRequestCompression(1024)
+	if err := client.CreateMetrics(nil, largeMetrics()); err != nil {
+		t.Fatalf("Error %s creating metrics", err)
+	}
+	if len(encodings) != 1 || encodings[0] != "gzip" || len(received) != 50 {
+		t.Errorf("Expected a single compressed request but was %v with %d metrics", encodings, len(received))
+	}
+
+	metric := largeMetrics()[0]
+	if err := client.CreateMetric(nil, &metric); err == nil {
+		t.Errorf("Expected small request to be sent uncompressed")
+	}
+}
+
+func TestRequestCompressionFallback(t *testing.T) {
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		body, _ := ioutil.ReadAll(r.Body)
+		if !json.Valid(body) {
+			w.WriteHeader(400)
+			return
+		}
+		w.WriteHeader(204)
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetRequestCompression(1024)
+	for i := 0; i < 2; i++ {
+		if err := client.CreateMetrics(nil, largeMetrics()); err != nil {
+			t.Fatalf("Error %s creating metrics", err)
+		}
+	}
+	expected := []string{"gzip", "", ""}
+	if len(encodings) != len(expected) || encodings[0] != "gzip" || encodings[1] != "" || encodings[2] != "" {
+		t.Errorf("Expected encodings %q but was %q", expected, encodings)
+	}
+}
+
+func TestRequestCompressionBadRequestChecksOnce(t *testing.T) {
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		w.WriteHeader(400)
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetRequestCompression(1024)
+	for i := 0; i < 2; i++ {
+		if err := client.CreateMetrics(nil, largeMetrics()); err == nil {
+			t.Fatalf("Expected error for status 400")
+		}
+	}
+	expected := []string{"gzip", "", "gzip"}
+	if len(encodings) != len(expected) || encodings[0] != "gzip" || encodings[1] != "" || encodings[2] != "gzip" {
+		t.Errorf("Expected encodings %q but was %q", expected, encodings)
+	}
+}
+
+func TestCompressedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		writer.Write([]byte(`{"links": [], "elements": [{"id": "1", "name": "cpu", "columns": ["timestamp", "value", "value_meta"], "measurements": [["2017-02-27T06:00:00Z", 1.5, {}]]}]}`))
+		writer.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buffer.Bytes())
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetHeaders(http.Header{"Accept-Encoding": []string{"gzip"}})
+	response, err := client.GetMeasurements(&models.MeasurementQuery{})
+	if err != nil {
+		t.Fatalf("Error %s getting measurements", err)
+	}
+	if len(response.Elements) != 1 || len(response.Elements[0].Measurements) != 1 {
+		t.Errorf("Expected one decompressed measurement but was %+v", response)
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// BalancePolicy decides which endpoint receives the next call.
+type BalancePolicy int
+
+const (
+	// RoundRobin rotates through the healthy endpoints.
+	RoundRobin BalancePolicy = iota
+	// LeastLatency picks the healthy endpoint with the lowest average latency.
+	LeastLatency
+)
+
+// latencyWeight is the weight of a new observation in the moving average.
+const latencyWeight = 0.2
+
+// EndpointStatus reports the health of an endpoint as seen by the client.
+type EndpointStatus struct {
+	URL            string
+	Healthy        bool
+	UnhealthySince time.Time
+	Latency        time.Duration
+}
+
+func SetEndpoints(urls []string) error {
+	return monClient.SetEndpoints(urls)
+}
+
+func SetBalancePolicy(policy BalancePolicy) {
+	monClient.SetBalancePolicy(policy)
+}
+
+// SetEndpoints spreads calls over several API nodes. Paths of the URLs are
+// ignored, as they are for the base URL, which becomes the first endpoint.
+// Endpoints answering with connection errors or 5xx statuses are marked
+// unhealthy and avoided until CheckEndpoints finds them responding again;
+// idempotent calls that fail that way are retried on the next endpoint.
+func (c *Client) SetEndpoints(urls []string) error {
+	if len(urls) == 0 {
+		return fmt.Errorf("At least one endpoint is required")
+	}
+	endpoints := make([]*endpoint, len(urls))
+	for i, rawURL := range urls {
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("Failed to parse endpoint %s: %v", rawURL, err)
+		}
+		if parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("Endpoint %s must be an absolute URL", rawURL)
+		}
+		endpoints[i] = &endpoint{url: parsed, healthy: true}
+	}
+	c.baseURL = urls[0]
+	c.endpointPool().set(endpoints)
+	return nil
+}
+
+// SetBalancePolicy chooses how calls are spread over the endpoints; the
+// default is RoundRobin.
+func (c *Client) SetBalancePolicy(policy BalancePolicy) {
+	pool := c.endpointPool()
+	pool.mutex.Lock()
+	pool.policy = policy
+	pool.mutex.Unlock()
+}
+
+// Endpoints returns the status of each endpoint set by SetEndpoints.
+func (c *Client) Endpoints() []EndpointStatus {
+	if c.endpoints == nil {
+		return nil
+	}
+	pool := c.endpoints
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	statuses := make([]EndpointStatus, len(pool.endpoints))
+	for i, e := range pool.endpoints {
+		statuses[i] = EndpointStatus{
+			URL:            e.url.String(),
+			Healthy:        e.healthy,
+			UnhealthySince: e.unhealthySince,
+			Latency:        e.latency,
+		}
+	}
+	return statuses
+}
+
+// CheckEndpoints probes the unhealthy endpoints by requesting their version
+// document and marks those that answer without a 5xx status healthy again.
+func (c *Client) CheckEndpoints(ctx context.Context) {
+	if c.endpoints == nil {
+		return
+	}
+	pool := c.endpoints
+	pool.mutex.Lock()
+	var unhealthy []*endpoint
+	for _, e := range pool.endpoints {
+		if !e.healthy {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	pool.mutex.Unlock()
+
+	client := c.httpClient()
+	for _, e := range unhealthy {
+		root := *e.url
+		root.Path = "/"
+		root.RawQuery = ""
+		req, err := http.NewRequest("GET", root.String(), nil)
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Accept", "application/json")
+		start := time.Now()
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		pool.report(e, resp.StatusCode >= 500, time.Since(start))
+	}
+}
+
+// RunEndpointChecks calls CheckEndpoints every interval until ctx is done.
+func (c *Client) RunEndpointChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.CheckEndpoints(ctx)
+		}
+	}
+}
+
+func (c *Client) endpointPool() *endpointPool {
+	if c.endpoints == nil {
+		c.endpoints = &endpointPool{}
+	}
+	return c.endpoints
+}
+
+type endpoint struct {
+	url            *url.URL
+	healthy        bool
+	unhealthySince time.Time
+	latency        time.Duration
+}
+
+// resolve points a request URL at the endpoint.
+func (e *endpoint) resolve(requestURL *url.URL) string {
+	if e == nil {
+		return requestURL.String()
+	}
+	resolved := *requestURL
+	resolved.Scheme = e.url.Scheme
+	resolved.Host = e.url.Host
+	resolved.User = e.url.User
+	return resolved.String()
+}
+
+type endpointPool struct {
+	mutex     sync.Mutex
+	endpoints []*endpoint
+	policy    BalancePolicy
+	next      int
+}
+
+func (p *endpointPool) set(endpoints []*endpoint) {
+	p.mutex.Lock()
+	p.endpoints = endpoints
+	p.next = 0
+	p.mutex.Unlock()
+}
+
+func (p *endpointPool) size() int {
+	if p == nil {
+		return 0
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.endpoints)
+}
+
+// pick chooses an endpoint not yet tried for the call, preferring healthy
+// ones. It returns nil when there are no endpoints or all have been tried.
+func (p *endpointPool) pick(tried map[*endpoint]bool) *endpoint {
+	if p == nil {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var healthy, unhealthy []*endpoint
+	for _, e := range p.endpoints {
+		if tried[e] {
+			continue
+		}
+		if e.healthy {
+			healthy = append(healthy, e)
+		} else {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		candidates = unhealthy
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	if p.policy == LeastLatency {
+		best := candidates[0]
+		for _, e := range candidates[1:] {
+			if e.latency < best.latency {
+				best = e
+			}
+		}
+		return best
+	}
+	p.next++
+	return candidates[p.next%len(candidates)]
+}
+
+// report records the outcome of a call to the endpoint.
+func (p *endpointPool) report(e *endpoint, failed bool, latency time.Duration) {
+	if p == nil || e == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if failed {
+		if e.healthy {
+			e.healthy = false
+			e.unhealthySince = time.Now()
+		}
+		return
+	}
+	e.healthy = true
+	e.unhealthySince = time.Time{}
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency += time.Duration(latencyWeight * float64(latency-e.latency))
+	}
+}
+
+// idempotent reports whether a call may be repeated on another endpoint.
+func idempotent(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "PUT" || method == "DELETE"
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpointsRoundRobin(t *testing.T) {
+	counts := map[string]int{}
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			counts[name]++
+			w.Write([]byte(`{"links": [], "elements": []}`))
+		}
+	}
+	first := httptest.NewServer(handler("first"))
+	defer first.Close()
+	second := httptest.NewServer(handler("second"))
+	defer second.Close()
+
+	client := New()
+	if err := client.SetEndpoints([]string{first.URL, second.URL}); err != nil {
+		t.Fatalf("Error %s setting endpoints", err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := client.GetMetrics(nil); err != nil {
+			t.Fatalf("Error %s getting metrics", err)
+		}
+	}
+	if counts["first"] != 2 || counts["second"] != 2 {
+		t.Errorf("Expected requests to alternate but was %v", counts)
+	}
+}
+
+func TestEndpointsFailover(t *testing.T) {
+	healthy := false
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"links": [], "elements": []}`))
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"links": [], "elements": []}`))
+	}))
+	defer working.Close()
+
+	client := New()
+	client.SetEndpoints([]string{working.URL, failing.URL})
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetMetrics(nil); err != nil {
+			t.Errorf("Expected GET to fail over but was %v", err)
+		}
+	}
+	statuses := client.Endpoints()
+	if !statuses[0].Healthy || statuses[1].Healthy {
+		t.Errorf("Expected only the failing endpoint to be unhealthy but was %+v", statuses)
+	}
+
+	healthy = true
+	client.CheckEndpoints(context.Background())
+	if statuses = client.Endpoints(); !statuses[1].Healthy {
+		t.Errorf("Expected the endpoint to recover but was %+v", statuses[1])
+	}
+}
+
+func TestEndpointsNoFailoverForPost(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(503)
+	})
+	first := httptest.NewServer(handler)
+	defer first.Close()
+	second := httptest.NewServer(handler)
+	defer second.Close()
+
+	client := New()
+	client.SetEndpoints([]string{first.URL, second.URL})
+	if err := client.CreateMetric(nil, &models.MetricRequestBody{}); err == nil {
+		t.Errorf("Expected POST to fail")
+	}
+	if calls != 1 {
+		t.Errorf("Expected POST to be sent once but was %d", calls)
+	}
+	if _, err := client.GetMetrics(nil); err == nil || calls != 3 {
+		t.Errorf("Expected GET to be tried on both endpoints but was %d calls, %v", calls, err)
+	}
+}
+
+func TestSetEndpointsInvalid(t *testing.T) {
+	client := New()
+	if err := client.SetEndpoints(nil); err == nil {
+		t.Errorf("Expected error for no endpoints")
+	}
+	if err := client.SetEndpoints([]string{"localhost:8070"}); err == nil {
+		t.Errorf("Expected error for relative endpoint")
+	}
+}
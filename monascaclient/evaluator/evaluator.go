@@ -0,0 +1,392 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package evaluator replays Monasca threshold semantics offline so alarm
+// definitions can be tuned against historical measurements before they are
+// created.
+package evaluator
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	StateOK           = "OK"
+	StateAlarm        = "ALARM"
+	StateUndetermined = "UNDETERMINED"
+)
+
+type Point struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// Series is a time ordered list of points of one metric. Preaggregated series
+// come from the statistics API and already hold one value per period.
+type Series struct {
+	Name          string
+	Dimensions    map[string]string
+	Points        []Point
+	Preaggregated bool
+}
+
+// Transition is a state change of the alarm for one match_by group.
+type Transition struct {
+	Timestamp time.Time
+	MatchBy   map[string]string
+	OldState  string
+	NewState  string
+}
+
+// SeriesFromMeasurements converts a GetMeasurements response.
+func SeriesFromMeasurements(elements []models.MeasurementElement) ([]Series, error) {
+	series := make([]Series, 0, len(elements))
+	for _, element := range elements {
+		points, err := columnPoints(element.Columns, element.Measurements, "value")
+		if err != nil {
+			return nil, fmt.Errorf("Measurements of %s: %v", element.Name, err)
+		}
+		series = append(series, Series{Name: element.Name, Dimensions: element.Dimensions, Points: points})
+	}
+	return series, nil
+}
+
+// SeriesFromStatistics converts a GetStatistics response using the given
+// statistic column. The statistics period should equal the period of the sub
+// expression and the statistic should match its function.
+func SeriesFromStatistics(elements []models.StatisticElement, statistic string) ([]Series, error) {
+	series := make([]Series, 0, len(elements))
+	for _, element := range elements {
+		points, err := columnPoints(element.Columns, element.Statistics, statistic)
+		if err != nil {
+			return nil, fmt.Errorf("Statistics of %s: %v", element.Name, err)
+		}
+		series = append(series, Series{Name: element.Name, Dimensions: element.Dimensions, Points: points, Preaggregated: true})
+	}
+	return series, nil
+}
+
+func columnPoints(columns []string, rows [][]interface{}, valueColumn string) ([]Point, error) {
+	timestampIndex, valueIndex := -1, -1
+	for i, column := range columns {
+		switch column {
+		case "timestamp":
+			timestampIndex = i
+		case valueColumn:
+			valueIndex = i
+		}
+	}
+	if timestampIndex < 0 || valueIndex < 0 {
+		return nil, fmt.Errorf("missing timestamp or %s column in %v", valueColumn, columns)
+	}
+
+	points := make([]Point, 0, len(rows))
+	for _, row := range rows {
+		if len(row) <= timestampIndex || len(row) <= valueIndex {
+			return nil, fmt.Errorf("short row %v", row)
+		}
+		timestampString, ok := row[timestampIndex].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid timestamp %v", row[timestampIndex])
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, timestampString)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := row[valueIndex].(float64)
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v", row[valueIndex])
+		}
+		points = append(points, Point{Timestamp: timestamp, Value: value})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp.Before(points[j].Timestamp) })
+	return points, nil
+}
+
+// MeasurementQueries returns one query per sub expression that fetches the
+// data needed to backtest the expression between start and end.
+func (e *Expression) MeasurementQueries(start time.Time, end time.Time) []models.MeasurementQuery {
+	queries := make([]models.MeasurementQuery, 0, len(e.SubExpressions))
+	groupBy := "*"
+	for _, subExpression := range e.SubExpressions {
+		name := subExpression.MetricName
+		dimensions := map[string]string{}
+		for key, value := range subExpression.Dimensions {
+			dimensions[key] = value
+		}
+		startTime, endTime := start, end
+		query := models.MeasurementQuery{Name: &name, StartTime: &startTime, EndTime: &endTime, GroupBy: &groupBy}
+		if len(dimensions) > 0 {
+			query.Dimensions = &dimensions
+		}
+		queries = append(queries, query)
+	}
+	return queries
+}
+
+// Backtest evaluates the alarm definition over [start, end) and returns the
+// state transitions it would have produced. Each sub expression is evaluated
+// whenever one of its periods closes, using the last "times" periods. A group
+// of series sharing the match_by dimension values forms one alarm.
+func Backtest(definition *models.AlarmDefinition, series []Series, start time.Time, end time.Time) ([]Transition, error) {
+	expression, err := Parse(definition.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("End time %v must be after start time %v", end, start)
+	}
+
+	groups := groupSeries(expression, definition.MatchBy, series)
+	groupKeys := make([]string, 0, len(groups))
+	for key := range groups {
+		groupKeys = append(groupKeys, key)
+	}
+	sort.Strings(groupKeys)
+
+	ticks := evaluationTicks(expression, start, end)
+	transitions := []Transition{}
+	for _, key := range groupKeys {
+		group := groups[key]
+		states := map[*SubExpression]string{}
+		for _, subExpression := range expression.SubExpressions {
+			states[subExpression] = initialState(subExpression)
+		}
+		state := combine(expression.Root, states)
+		for _, tick := range ticks {
+			for _, subExpression := range expression.SubExpressions {
+				if tick.Sub(start)%subExpression.Period != 0 {
+					continue
+				}
+				states[subExpression] = evaluateSubExpression(subExpression, group.series[subExpression], start, tick)
+			}
+			newState := combine(expression.Root, states)
+			if newState != state {
+				transitions = append(transitions, Transition{Timestamp: tick, MatchBy: group.matchBy, OldState: state, NewState: newState})
+				state = newState
+			}
+		}
+	}
+	sort.SliceStable(transitions, func(i, j int) bool { return transitions[i].Timestamp.Before(transitions[j].Timestamp) })
+	return transitions, nil
+}
+
+type seriesGroup struct {
+	matchBy map[string]string
+	series  map[*SubExpression][]Series
+}
+
+func (s *SubExpression) matches(series *Series) bool {
+	if series.Name != s.MetricName {
+		return false
+	}
+	for key, value := range s.Dimensions {
+		if series.Dimensions[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func groupSeries(expression *Expression, matchBy []string, series []Series) map[string]*seriesGroup {
+	groups := map[string]*seriesGroup{}
+	for i := range series {
+		matchByValues := map[string]string{}
+		keyParts := make([]string, 0, len(matchBy))
+		for _, dimension := range matchBy {
+			value, found := series[i].Dimensions[dimension]
+			if found {
+				matchByValues[dimension] = value
+			}
+			keyParts = append(keyParts, dimension+"="+value)
+		}
+		key := strings.Join(keyParts, ",")
+
+		for _, subExpression := range expression.SubExpressions {
+			if !subExpression.matches(&series[i]) {
+				continue
+			}
+			group, found := groups[key]
+			if !found {
+				group = &seriesGroup{matchBy: matchByValues, series: map[*SubExpression][]Series{}}
+				groups[key] = group
+			}
+			group.series[subExpression] = append(group.series[subExpression], series[i])
+		}
+	}
+	return groups
+}
+
+func evaluationTicks(expression *Expression, start time.Time, end time.Time) []time.Time {
+	seen := map[time.Time]bool{}
+	ticks := []time.Time{}
+	for _, subExpression := range expression.SubExpressions {
+		for tick := start.Add(subExpression.Period); !tick.After(end); tick = tick.Add(subExpression.Period) {
+			if !seen[tick] {
+				seen[tick] = true
+				ticks = append(ticks, tick)
+			}
+		}
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i].Before(ticks[j]) })
+	return ticks
+}
+
+func initialState(subExpression *SubExpression) string {
+	if subExpression.Deterministic {
+		return StateOK
+	}
+	return StateUndetermined
+}
+
+// evaluateSubExpression looks at the last "times" periods ending at tick. The
+// sub expression is in ALARM if all of them breach the threshold, OK if any
+// of them has data that does not breach and UNDETERMINED otherwise.
+func evaluateSubExpression(subExpression *SubExpression, series []Series, start time.Time, tick time.Time) string {
+	breaching := 0
+	for i := 0; i < subExpression.Times; i++ {
+		periodEnd := tick.Add(-time.Duration(i) * subExpression.Period)
+		periodStart := periodEnd.Add(-subExpression.Period)
+		if periodStart.Before(start) {
+			break
+		}
+		value, found := aggregate(subExpression, series, periodStart, periodEnd)
+		if !found {
+			continue
+		}
+		if !compare(value, subExpression.Operator, subExpression.Threshold) {
+			return StateOK
+		}
+		breaching++
+	}
+	if breaching == subExpression.Times {
+		return StateAlarm
+	}
+	return initialState(subExpression)
+}
+
+func aggregate(subExpression *SubExpression, series []Series, periodStart time.Time, periodEnd time.Time) (float64, bool) {
+	// Statistics already hold one aggregated value per period, counts of
+	// several series are therefore summed rather than counted again.
+	function := subExpression.Function
+	preaggregated := len(series) > 0
+	for _, s := range series {
+		preaggregated = preaggregated && s.Preaggregated
+	}
+	if preaggregated && function == "count" {
+		function = "sum"
+	}
+
+	values := []float64{}
+	var lastTimestamp time.Time
+	last := 0.0
+	for _, s := range series {
+		for _, point := range s.Points {
+			if point.Timestamp.Before(periodStart) || !point.Timestamp.Before(periodEnd) {
+				continue
+			}
+			values = append(values, point.Value)
+			if !point.Timestamp.Before(lastTimestamp) {
+				lastTimestamp = point.Timestamp
+				last = point.Value
+			}
+		}
+	}
+	if len(values) == 0 {
+		if subExpression.Deterministic && subExpression.Function == "count" {
+			return 0, true
+		}
+		return 0, false
+	}
+
+	result := values[0]
+	switch function {
+	case "min":
+		for _, value := range values[1:] {
+			if value < result {
+				result = value
+			}
+		}
+	case "max":
+		for _, value := range values[1:] {
+			if value > result {
+				result = value
+			}
+		}
+	case "sum", "avg":
+		for _, value := range values[1:] {
+			result += value
+		}
+		if function == "avg" {
+			result /= float64(len(values))
+		}
+	case "count":
+		result = float64(len(values))
+	case "last":
+		result = last
+	}
+	return result, true
+}
+
+func compare(value float64, operator string, threshold float64) bool {
+	switch operator {
+	case "<":
+		return value < threshold
+	case ">":
+		return value > threshold
+	case "<=":
+		return value <= threshold
+	case ">=":
+		return value >= threshold
+	}
+	return false
+}
+
+// combine applies the logical operators: "and" is in ALARM when all children
+// are, "or" when any child is. OK wins over UNDETERMINED for "and", the
+// reverse holds for "or".
+func combine(node *Node, states map[*SubExpression]string) string {
+	if node.SubExpression != nil {
+		return states[node.SubExpression]
+	}
+	alarms, oks := 0, 0
+	for _, child := range node.Children {
+		switch combine(child, states) {
+		case StateAlarm:
+			alarms++
+		case StateOK:
+			oks++
+		}
+	}
+	if node.Operator == "and" {
+		if alarms == len(node.Children) {
+			return StateAlarm
+		}
+		if oks > 0 {
+			return StateOK
+		}
+		return StateUndetermined
+	}
+	if alarms > 0 {
+		return StateAlarm
+	}
+	if oks == len(node.Children) {
+		return StateOK
+	}
+	return StateUndetermined
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package evaluator
+
+import (
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+
+func testSeries(name string, dimensions map[string]string, values ...float64) Series {
+	series := Series{Name: name, Dimensions: dimensions}
+	for i, value := range values {
+		series.Points = append(series.Points, Point{Timestamp: testStart.Add(time.Duration(i)*time.Minute + time.Second), Value: value})
+	}
+	return series
+}
+
+func TestBacktestTimes(t *testing.T) {
+	definition := &models.AlarmDefinition{Expression: "avg(cpu.idle_perc) < 10 times 2"}
+	series := []Series{testSeries("cpu.idle_perc", map[string]string{"hostname": "web1"}, 50, 5, 5, 5, 50)}
+
+	transitions, err := Backtest(definition, series, testStart, testStart.Add(5*time.Minute))
+	if err != nil {
+		t.Fatalf("Error %s backtesting", err)
+	}
+	expected := []Transition{
+		{Timestamp: testStart.Add(1 * time.Minute), OldState: StateUndetermined, NewState: StateOK},
+		{Timestamp: testStart.Add(3 * time.Minute), OldState: StateOK, NewState: StateAlarm},
+		{Timestamp: testStart.Add(5 * time.Minute), OldState: StateAlarm, NewState: StateOK},
+	}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected %d transitions but was %+v", len(expected), transitions)
+	}
+	for i := range expected {
+		if !transitions[i].Timestamp.Equal(expected[i].Timestamp) || transitions[i].OldState != expected[i].OldState || transitions[i].NewState != expected[i].NewState {
+			t.Errorf("Expected %+v but was %+v", expected[i], transitions[i])
+		}
+	}
+}
+
+func TestBacktestMatchByAndUndetermined(t *testing.T) {
+	definition := &models.AlarmDefinition{Expression: "max(disk.used) > 90", MatchBy: []string{"hostname"}}
+	series := []Series{
+		testSeries("disk.used", map[string]string{"hostname": "a"}, 95),
+		testSeries("disk.used", map[string]string{"hostname": "b"}, 10),
+		testSeries("other", map[string]string{"hostname": "c"}, 99),
+	}
+
+	transitions, err := Backtest(definition, series, testStart, testStart.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("Error %s backtesting", err)
+	}
+	states := map[string][]string{}
+	for _, transition := range transitions {
+		host := transition.MatchBy["hostname"]
+		states[host] = append(states[host], transition.NewState)
+	}
+	if len(states) != 2 {
+		t.Fatalf("Expected transitions for two hosts but was %v", states)
+	}
+	if len(states["a"]) != 2 || states["a"][0] != StateAlarm || states["a"][1] != StateUndetermined {
+		t.Errorf("Expected host a to alarm and then become undetermined but was %v", states["a"])
+	}
+	if len(states["b"]) != 2 || states["b"][0] != StateOK || states["b"][1] != StateUndetermined {
+		t.Errorf("Expected host b to be OK and then become undetermined but was %v", states["b"])
+	}
+}
+
+func TestBacktestDeterministicCount(t *testing.T) {
+	definition := &models.AlarmDefinition{Expression: "count(log.error, deterministic) > 0"}
+	series := []Series{testSeries("log.error", nil, 1)}
+
+	transitions, err := Backtest(definition, series, testStart, testStart.Add(3*time.Minute))
+	if err != nil {
+		t.Fatalf("Error %s backtesting", err)
+	}
+	if len(transitions) != 2 || transitions[0].NewState != StateAlarm || transitions[1].NewState != StateOK {
+		t.Errorf("Expected ALARM then OK without UNDETERMINED but was %+v", transitions)
+	}
+}
+
+func TestSeriesFromStatistics(t *testing.T) {
+	elements := []models.StatisticElement{{
+		Name:       "cpu.idle_perc",
+		Columns:    []string{"timestamp", "avg", "count"},
+		Statistics: [][]interface{}{{"2017-02-27T06:00:00.000Z", 5.0, 3.0}, {"2017-02-27T06:01:00.000Z", 50.0, 3.0}},
+	}}
+	series, err := SeriesFromStatistics(elements, "count")
+	if err != nil {
+		t.Fatalf("Error %s converting statistics", err)
+	}
+	definition := &models.AlarmDefinition{Expression: "count(cpu.idle_perc) >= 3"}
+	transitions, err := Backtest(definition, series, testStart, testStart.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Error %s backtesting", err)
+	}
+	if len(transitions) != 1 || transitions[0].NewState != StateAlarm {
+		t.Errorf("Expected preaggregated count to alarm but was %+v", transitions)
+	}
+}
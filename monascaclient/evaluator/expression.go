@@ -0,0 +1,355 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package evaluator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultPeriod = 60 * time.Second
+	defaultTimes  = 1
+)
+
+// SubExpression is a single threshold comparison such as
+// avg(cpu.idle_perc{hostname=web1}, 120) < 10 times 3.
+type SubExpression struct {
+	Function      string
+	MetricName    string
+	Dimensions    map[string]string
+	Deterministic bool
+	Period        time.Duration
+	Operator      string
+	Threshold     float64
+	Times         int
+}
+
+// Node is an element of the parsed expression tree. Leaves carry a
+// SubExpression, inner nodes combine their children with "and" or "or".
+type Node struct {
+	Operator      string
+	Children      []*Node
+	SubExpression *SubExpression
+}
+
+// Expression is a parsed Monasca alarm expression.
+type Expression struct {
+	Root           *Node
+	SubExpressions []*SubExpression
+}
+
+var functions = map[string]bool{"min": true, "max": true, "sum": true, "count": true, "avg": true, "last": true}
+
+var operators = map[string]string{
+	"<": "<", "lt": "<",
+	">": ">", "gt": ">",
+	"<=": "<=", "lte": "<=",
+	">=": ">=", "gte": ">=",
+}
+
+// Parse parses an alarm expression using the Monasca grammar.
+func Parse(expression string) (*Expression, error) {
+	p := &parser{input: expression}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos:])
+	}
+	parsed := &Expression{Root: root}
+	collectSubExpressions(root, &parsed.SubExpressions)
+	return parsed, nil
+}
+
+func collectSubExpressions(node *Node, subExpressions *[]*SubExpression) {
+	if node.SubExpression != nil {
+		*subExpressions = append(*subExpressions, node.SubExpression)
+		return
+	}
+	for _, child := range node.Children {
+		collectSubExpressions(child, subExpressions)
+	}
+}
+
+// Deterministic reports whether every sub expression is deterministic, in
+// which case alarms of the definition never become UNDETERMINED.
+func (e *Expression) Deterministic() bool {
+	for _, subExpression := range e.SubExpressions {
+		if !subExpression.Deterministic {
+			return false
+		}
+	}
+	return true
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Invalid expression at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// consumeKeyword consumes one of the given logical operators, keywords must
+// not be followed by an identifier character.
+func (p *parser) consumeKeyword(keywords ...string) bool {
+	p.skipSpace()
+	rest := p.input[p.pos:]
+	for _, keyword := range keywords {
+		if len(rest) < len(keyword) || !strings.EqualFold(rest[:len(keyword)], keyword) {
+			continue
+		}
+		if isIdentifierChar(keyword[0]) && len(rest) > len(keyword) && isIdentifierChar(rest[len(keyword)]) {
+			continue
+		}
+		p.pos += len(keyword)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(char byte) error {
+	p.skipSpace()
+	if p.pos >= len(p.input) || p.input[p.pos] != char {
+		return p.errorf("expected %q", char)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) parseOr() (*Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{Operator: "or", Children: []*Node{left}}
+	for p.consumeKeyword("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, right)
+	}
+	if len(node.Children) == 1 {
+		return left, nil
+	}
+	return node, nil
+}
+
+func (p *parser) parseAnd() (*Node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{Operator: "and", Children: []*Node{left}}
+	for p.consumeKeyword("and", "&&") {
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, right)
+	}
+	if len(node.Children) == 1 {
+		return left, nil
+	}
+	return node, nil
+}
+
+func (p *parser) parsePrimary() (*Node, error) {
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(')')
+	}
+	subExpression, err := p.parseSubExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &Node{SubExpression: subExpression}, nil
+}
+
+func (p *parser) parseSubExpression() (*SubExpression, error) {
+	subExpression := &SubExpression{Period: defaultPeriod, Times: defaultTimes, Dimensions: map[string]string{}}
+
+	function := strings.ToLower(p.identifier())
+	if !functions[function] {
+		return nil, p.errorf("unknown function %q", function)
+	}
+	subExpression.Function = function
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+
+	subExpression.MetricName = p.identifier()
+	if subExpression.MetricName == "" {
+		return nil, p.errorf("expected metric name")
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] == '{' {
+		p.pos++
+		if err := p.parseDimensions(subExpression.Dimensions); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos < len(p.input) && p.input[p.pos] == ')' {
+			p.pos++
+			break
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+		if p.consumeKeyword("deterministic") {
+			subExpression.Deterministic = true
+			continue
+		}
+		period, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		if period <= 0 {
+			return nil, p.errorf("period must be positive")
+		}
+		subExpression.Period = time.Duration(period) * time.Second
+	}
+
+	p.skipSpace()
+	operator := p.operator()
+	normalized, found := operators[strings.ToLower(operator)]
+	if !found {
+		return nil, p.errorf("expected comparison operator")
+	}
+	subExpression.Operator = normalized
+
+	threshold, err := p.number()
+	if err != nil {
+		return nil, err
+	}
+	subExpression.Threshold = threshold
+
+	if p.consumeKeyword("times") {
+		times, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		if times <= 0 {
+			return nil, p.errorf("times must be positive")
+		}
+		subExpression.Times = times
+	}
+	return subExpression, nil
+}
+
+func (p *parser) parseDimensions(dimensions map[string]string) error {
+	for {
+		p.skipSpace()
+		if p.pos < len(p.input) && p.input[p.pos] == '}' {
+			p.pos++
+			return nil
+		}
+		start := p.pos
+		for p.pos < len(p.input) && !strings.ContainsRune("=,}", rune(p.input[p.pos])) {
+			p.pos++
+		}
+		key := strings.TrimSpace(p.input[start:p.pos])
+		if err := p.expect('='); err != nil {
+			return err
+		}
+		start = p.pos
+		for p.pos < len(p.input) && !strings.ContainsRune(",}", rune(p.input[p.pos])) {
+			p.pos++
+		}
+		if p.pos >= len(p.input) {
+			return p.errorf("unterminated dimensions")
+		}
+		value := strings.TrimSpace(p.input[start:p.pos])
+		if key == "" || value == "" {
+			return p.errorf("invalid dimension")
+		}
+		dimensions[key] = value
+		if p.input[p.pos] == ',' {
+			p.pos++
+		}
+	}
+}
+
+func isIdentifierChar(char byte) bool {
+	return char == '.' || char == '_' || char == '-' || char == ':' || unicode.IsLetter(rune(char)) || unicode.IsDigit(rune(char))
+}
+
+func (p *parser) identifier() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && isIdentifierChar(p.input[p.pos]) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *parser) operator() string {
+	rest := p.input[p.pos:]
+	for _, operator := range []string{"<=", ">=", "<", ">"} {
+		if strings.HasPrefix(rest, operator) {
+			p.pos += len(operator)
+			return operator
+		}
+	}
+	return p.identifier()
+}
+
+func (p *parser) number() (float64, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && strings.ContainsRune("0123456789.-+eE", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return 0, p.errorf("expected number")
+	}
+	return value, nil
+}
+
+func (p *parser) integer() (int, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && unicode.IsDigit(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	value, err := strconv.Atoi(p.input[start:p.pos])
+	if err != nil {
+		return 0, p.errorf("expected integer")
+	}
+	return value, nil
+}
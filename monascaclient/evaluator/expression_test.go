@@ -0,0 +1,65 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSubExpression(t *testing.T) {
+	expression, err := Parse("avg(cpu.idle_perc{hostname=web1, service = monitoring}, deterministic, 120) lt 10.5 times 3")
+	if err != nil {
+		t.Fatalf("Error %s parsing expression", err)
+	}
+	expected := &SubExpression{
+		Function:      "avg",
+		MetricName:    "cpu.idle_perc",
+		Dimensions:    map[string]string{"hostname": "web1", "service": "monitoring"},
+		Deterministic: true,
+		Period:        120 * time.Second,
+		Operator:      "<",
+		Threshold:     10.5,
+		Times:         3,
+	}
+	if len(expression.SubExpressions) != 1 || !reflect.DeepEqual(expected, expression.SubExpressions[0]) {
+		t.Errorf("Expected %+v but was %+v", expected, expression.SubExpressions[0])
+	}
+}
+
+func TestParseLogicalOperators(t *testing.T) {
+	expression, err := Parse("max(a) > 1 and (min(b) < 2 or count(c) >= 3) && last(d) <= 4")
+	if err != nil {
+		t.Fatalf("Error %s parsing expression", err)
+	}
+	if expression.Root.Operator != "and" || len(expression.Root.Children) != 3 {
+		t.Fatalf("Expected a three way and but was %+v", expression.Root)
+	}
+	if expression.Root.Children[1].Operator != "or" {
+		t.Errorf("Expected nested or but was %+v", expression.Root.Children[1])
+	}
+	if len(expression.SubExpressions) != 4 || expression.SubExpressions[0].Period != defaultPeriod || expression.SubExpressions[0].Times != defaultTimes {
+		t.Errorf("Unexpected sub expressions %+v", expression.SubExpressions)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, invalid := range []string{"", "median(a) > 1", "avg(a) ~ 1", "avg(a > 1", "avg(a{b=}) > 1", "avg(a) > 1 times 0", "avg(a) > 1 xor"} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("Expected error parsing '%s'", invalid)
+		}
+	}
+}
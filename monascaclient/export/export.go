@@ -0,0 +1,288 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package export writes Monasca measurements and statistics to CSV, JSON Lines
+// or Parquet for offline analysis.
+package export
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Source pages through query results; a *monascaclient.Client satisfies it.
+type Source interface {
+	GetMetrics(metricQuery *models.MetricQuery) ([]models.Metric, error)
+	ForEachMeasurementPage(measurementQuery *models.MeasurementQuery, handle func(*models.MeasurementsResponse) error) error
+	ForEachStatisticPage(statisticsQuery *models.StatisticQuery, handle func(*models.StatisticsResponse) error) error
+}
+
+// Layout describes the rows of an export. Values holds the value columns,
+// "value" for measurements or the statistics names, and Dimensions the
+// dimension columns in order.
+type Layout struct {
+	Values     []string
+	ValueMeta  bool
+	Dimensions []string
+}
+
+// Row is a single point of a series. Values is aligned with Layout.Values and
+// holds nil where the point has no value.
+type Row struct {
+	Name       string
+	Dimensions map[string]string
+	Timestamp  time.Time
+	Values     []*float64
+	ValueMeta  map[string]string
+}
+
+// Writer writes rows in one format. Begin is called once before the first row
+// and Close after the last; Close finishes the format but does not close the
+// underlying io.Writer.
+type Writer interface {
+	Begin(layout Layout) error
+	Write(row Row) error
+	Close() error
+}
+
+const (
+	CSV        = "csv"
+	JSONLines  = "jsonl"
+	Parquet    = "parquet"
+	timeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// NewWriter returns the writer for a format name: csv, jsonl or parquet.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch strings.ToLower(format) {
+	case CSV:
+		return NewCSVWriter(w), nil
+	case JSONLines, "json", "ndjson":
+		return NewJSONLinesWriter(w), nil
+	case Parquet:
+		return NewParquetWriter(w), nil
+	}
+	return nil, fmt.Errorf("Unknown export format %q", format)
+}
+
+// Exporter runs a query across all of its pages and streams the points to a
+// Writer, so that only one page is held in memory.
+type Exporter struct {
+	source           Source
+	writer           Writer
+	dimensionColumns []string
+}
+
+func New(source Source, writer Writer) *Exporter {
+	return &Exporter{
+		source: source,
+		writer: writer,
+	}
+}
+
+// SetDimensionColumns fixes the dimension columns. Otherwise they are the
+// dimension names of the metrics matching the query, looked up with GetMetrics
+// before the export starts. Dimensions without a column are left out of CSV
+// and Parquet exports.
+func (e *Exporter) SetDimensionColumns(dimensionColumns []string) {
+	e.dimensionColumns = dimensionColumns
+}
+
+// Measurements exports every measurement of the query, which needs a start
+// time, and returns the number of rows written.
+func (e *Exporter) Measurements(measurementQuery *models.MeasurementQuery) (int64, error) {
+	if measurementQuery == nil || measurementQuery.StartTime == nil {
+		return 0, fmt.Errorf("Measurement export requires a start time")
+	}
+	dimensions, err := e.dimensions(&models.MetricQuery{
+		TenantID:        measurementQuery.TenantID,
+		Name:            measurementQuery.Name,
+		Dimensions:      measurementQuery.Dimensions,
+		DimensionFilter: measurementQuery.DimensionFilter,
+		StartTime:       measurementQuery.StartTime,
+		EndTime:         measurementQuery.EndTime,
+	})
+	if err != nil {
+		return 0, err
+	}
+	layout := Layout{Values: []string{"value"}, ValueMeta: true, Dimensions: dimensions}
+	if err = e.writer.Begin(layout); err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	err = e.source.ForEachMeasurementPage(measurementQuery, func(page *models.MeasurementsResponse) error {
+		for _, element := range page.Elements {
+			written, err := e.writeElement(layout, element.Name, element.Dimensions, element.Columns, element.Measurements)
+			rows += written
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	return rows, e.writer.Close()
+}
+
+// Statistics exports every statistics period of the query, which needs a
+// start time and the statistics to compute, and returns the number of rows
+// written.
+func (e *Exporter) Statistics(statisticsQuery *models.StatisticQuery) (int64, error) {
+	if statisticsQuery == nil || statisticsQuery.StartTime == nil {
+		return 0, fmt.Errorf("Statistics export requires a start time")
+	}
+	if statisticsQuery.Statistics == nil || *statisticsQuery.Statistics == "" {
+		return 0, fmt.Errorf("Statistics export requires statistics")
+	}
+	dimensions, err := e.dimensions(&models.MetricQuery{
+		TenantID:        statisticsQuery.TenantID,
+		Name:            statisticsQuery.Name,
+		Dimensions:      statisticsQuery.Dimensions,
+		DimensionFilter: statisticsQuery.DimensionFilter,
+		StartTime:       statisticsQuery.StartTime,
+		EndTime:         statisticsQuery.EndTime,
+	})
+	if err != nil {
+		return 0, err
+	}
+	layout := Layout{Values: strings.Split(*statisticsQuery.Statistics, ","), Dimensions: dimensions}
+	if err = e.writer.Begin(layout); err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	err = e.source.ForEachStatisticPage(statisticsQuery, func(page *models.StatisticsResponse) error {
+		for _, element := range page.Elements {
+			written, err := e.writeElement(layout, element.Name, element.Dimensions, element.Columns, element.Statistics)
+			rows += written
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	return rows, e.writer.Close()
+}
+
+func (e *Exporter) dimensions(metricQuery *models.MetricQuery) ([]string, error) {
+	if e.dimensionColumns != nil {
+		return e.dimensionColumns, nil
+	}
+	metrics, err := e.source.GetMetrics(metricQuery)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to look up dimension columns: %v", err)
+	}
+	seen := map[string]bool{}
+	columns := []string{}
+	for _, metric := range metrics {
+		for key := range metric.Dimensions {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns, nil
+}
+
+func (e *Exporter) writeElement(layout Layout, name string, dimensions map[string]string, columns []string, points [][]interface{}) (int64, error) {
+	timestampIndex, valueMetaIndex := -1, -1
+	valueIndexes := make([]int, len(layout.Values))
+	for i := range valueIndexes {
+		valueIndexes[i] = -1
+	}
+	for i, column := range columns {
+		switch column {
+		case "timestamp":
+			timestampIndex = i
+		case "value_meta":
+			valueMetaIndex = i
+		default:
+			for j, value := range layout.Values {
+				if value == column {
+					valueIndexes[j] = i
+				}
+			}
+		}
+	}
+	if timestampIndex < 0 {
+		return 0, fmt.Errorf("Series %s has no timestamp column", name)
+	}
+
+	var rows int64
+	for _, point := range points {
+		row := Row{Name: name, Dimensions: dimensions, Values: make([]*float64, len(layout.Values))}
+		rawTimestamp, _ := point[timestampIndex].(string)
+		timestamp, err := time.Parse(time.RFC3339Nano, rawTimestamp)
+		if err != nil {
+			return rows, fmt.Errorf("Series %s has an invalid timestamp %v", name, point[timestampIndex])
+		}
+		row.Timestamp = timestamp
+		for i, index := range valueIndexes {
+			if index < 0 || index >= len(point) {
+				continue
+			}
+			if value, ok := point[index].(float64); ok {
+				row.Values[i] = &value
+			}
+		}
+		if valueMetaIndex >= 0 && valueMetaIndex < len(point) {
+			if valueMeta, ok := point[valueMetaIndex].(map[string]interface{}); ok && len(valueMeta) > 0 {
+				row.ValueMeta = make(map[string]string, len(valueMeta))
+				for key, value := range valueMeta {
+					row.ValueMeta[key] = fmt.Sprint(value)
+				}
+			}
+		}
+		if err = e.writer.Write(row); err != nil {
+			return rows, err
+		}
+		rows++
+	}
+	return rows, nil
+}
+
+// columnNames returns the flat column names of a layout: name, timestamp, the
+// values, value_meta and the dimensions. Dimensions clashing with another
+// column are prefixed with "dimension_".
+func columnNames(layout Layout) []string {
+	columns := []string{"name", "timestamp"}
+	columns = append(columns, layout.Values...)
+	if layout.ValueMeta {
+		columns = append(columns, "value_meta")
+	}
+	taken := map[string]bool{}
+	for _, column := range columns {
+		taken[column] = true
+	}
+	for _, dimension := range layout.Dimensions {
+		if taken[dimension] {
+			dimension = "dimension_" + dimension
+		}
+		taken[dimension] = true
+		columns = append(columns, dimension)
+	}
+	return columns
+}
@@ -0,0 +1,299 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T) (*monascaclient.Client, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2.0/metrics":
+			w.Write([]byte(`{"links": [], "elements": [
+				{"name": "cpu", "dimensions": {"hostname": "web1"}},
+				{"name": "cpu", "dimensions": {"hostname": "web2", "name": "x"}}]}`))
+		case r.URL.Path == "/v2.0/metrics/measurements" && r.URL.Query().Get("offset") == "":
+			w.Write([]byte(`{"links": [{"rel": "next", "href": "http://monasca/v2.0/metrics/measurements?offset=2"}], "elements": [
+				{"name": "cpu", "dimensions": {"hostname": "web1"}, "columns": ["timestamp", "value", "value_meta"],
+				 "measurements": [["2017-01-01T00:00:00.000Z", 1.5, {}], ["2017-01-01T00:01:00.000Z", 2, {"reason": "spike"}]]}]}`))
+		case r.URL.Path == "/v2.0/metrics/measurements":
+			w.Write([]byte(`{"links": [], "elements": [
+				{"name": "cpu", "dimensions": {"hostname": "web2", "name": "x"}, "columns": ["timestamp", "value", "value_meta"],
+				 "measurements": [["2017-01-01T00:00:00.000Z", 3, null]]}]}`))
+		case r.URL.Path == "/v2.0/metrics/statistics":
+			w.Write([]byte(`{"links": [], "elements": [
+				{"name": "cpu", "dimensions": {}, "columns": ["timestamp", "max", "avg"],
+				 "statistics": [["2017-01-01T00:00:00Z", 4, 2], ["2017-01-01T00:05:00Z", null, 1]]}]}`))
+		default:
+			t.Errorf("Unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	client := monascaclient.New()
+	client.SetBaseURL(server.URL)
+	return client, server.Close
+}
+
+func measurementQuery() *models.MeasurementQuery {
+	name := "cpu"
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &models.MeasurementQuery{Name: &name, StartTime: &start}
+}
+
+func TestExportMeasurementsCSV(t *testing.T) {
+	client, closeServer := newTestClient(t)
+	defer closeServer()
+
+	output := &bytes.Buffer{}
+	rows, err := New(client, NewCSVWriter(output)).Measurements(measurementQuery())
+	if err != nil {
+		t.Fatalf("Error %s exporting", err)
+	}
+	if rows != 3 {
+		t.Errorf("Expected 3 rows but was %d", rows)
+	}
+	expected := "name,timestamp,value,value_meta,hostname,dimension_name\n" +
+		"cpu,2017-01-01T00:00:00.000Z,1.5,,web1,\n" +
+		"cpu,2017-01-01T00:01:00.000Z,2,\"{\"\"reason\"\":\"\"spike\"\"}\",web1,\n" +
+		"cpu,2017-01-01T00:00:00.000Z,3,,web2,x\n"
+	if output.String() != expected {
+		t.Errorf("Expected '%v' but was '%v'", expected, output.String())
+	}
+}
+
+func TestExportStatisticsJSONLines(t *testing.T) {
+	client, closeServer := newTestClient(t)
+	defer closeServer()
+
+	name, statistics := "cpu", "avg,max"
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	output := &bytes.Buffer{}
+	exporter := New(client, NewJSONLinesWriter(output))
+	exporter.SetDimensionColumns([]string{})
+	rows, err := exporter.Statistics(&models.StatisticQuery{Name: &name, Statistics: &statistics, StartTime: &start})
+	if err != nil {
+		t.Fatalf("Error %s exporting", err)
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if rows != 2 || len(lines) != 2 {
+		t.Fatalf("Expected 2 rows but was %d: %v", rows, lines)
+	}
+	var first, second map[string]interface{}
+	json.Unmarshal([]byte(lines[0]), &first)
+	json.Unmarshal([]byte(lines[1]), &second)
+	if first["avg"] != 2.0 || first["max"] != 4.0 || first["timestamp"] != "2017-01-01T00:00:00.000Z" {
+		t.Errorf("Unexpected first row %v", first)
+	}
+	if _, found := second["max"]; found {
+		t.Errorf("Expected null max to be left out but was %v", second)
+	}
+
+	if _, err := exporter.Statistics(&models.StatisticQuery{Name: &name, StartTime: &start}); err == nil {
+		t.Errorf("Expected error without statistics")
+	}
+}
+
+func TestExportMeasurementsParquet(t *testing.T) {
+	client, closeServer := newTestClient(t)
+	defer closeServer()
+
+	output := &bytes.Buffer{}
+	rows, err := New(client, NewParquetWriter(output)).Measurements(measurementQuery())
+	if err != nil {
+		t.Fatalf("Error %s exporting", err)
+	}
+	data := output.Bytes()
+	if rows != 3 || len(data) < 12 {
+		t.Fatalf("Expected 3 rows in a Parquet file but was %d rows in %d bytes", rows, len(data))
+	}
+	if string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Errorf("Expected Parquet magic at both ends")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footerLength <= 0 || footerLength > len(data)-12 {
+		t.Fatalf("Expected a footer inside the file but was %d bytes", footerLength)
+	}
+
+	footer := (&thriftReader{data: data, pos: len(data) - 8 - footerLength}).readStruct()
+	if footer[3] != int64(3) {
+		t.Errorf("Expected 3 rows in the footer but was %v", footer[3])
+	}
+	var names []string
+	for _, element := range footer[2].([]interface{})[1:] {
+		names = append(names, element.(map[int16]interface{})[4].(string))
+	}
+	if strings.Join(names, ",") != "name,timestamp,value,value_meta,hostname,dimension_name" {
+		t.Errorf("Unexpected schema %v", names)
+	}
+	rowGroups := footer[4].([]interface{})
+	if len(rowGroups) != 1 {
+		t.Fatalf("Expected one row group but was %d", len(rowGroups))
+	}
+	chunks := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+
+	defined, values := readParquetColumn(t, data, chunks[1], false)
+	var timestamps []int64
+	for len(values) >= 8 {
+		timestamps = append(timestamps, int64(binary.LittleEndian.Uint64(values)))
+		values = values[8:]
+	}
+	if len(defined) != 3 || len(timestamps) != 3 || timestamps[1] != 1483228860000 {
+		t.Errorf("Expected 3 millisecond timestamps but was %v", timestamps)
+	}
+
+	defined, values = readParquetColumn(t, data, chunks[2], true)
+	var doubles []float64
+	for len(values) >= 8 {
+		doubles = append(doubles, math.Float64frombits(binary.LittleEndian.Uint64(values)))
+		values = values[8:]
+	}
+	if len(defined) != 3 || len(doubles) != 3 || doubles[0] != 1.5 || doubles[1] != 2 || doubles[2] != 3 {
+		t.Errorf("Expected values 1.5, 2 and 3 but was %v %v", defined, doubles)
+	}
+
+	defined, values = readParquetColumn(t, data, chunks[5], true)
+	if len(defined) != 3 || defined[0] || defined[1] || !defined[2] {
+		t.Errorf("Expected dimension_name to be null, null, set but was %v", defined)
+	}
+	if len(values) < 4 || string(values[4:4+binary.LittleEndian.Uint32(values)]) != "x" {
+		t.Errorf("Expected the single dimension_name value x but was %q", values)
+	}
+}
+
+// readParquetColumn decodes the data page of a column chunk written by
+// ParquetWriter, returning the definition levels and the PLAIN values.
+func readParquetColumn(t *testing.T, data []byte, chunk interface{}, optional bool) ([]bool, []byte) {
+	meta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+	reader := &thriftReader{data: data, pos: int(meta[9].(int64))}
+	header := reader.readStruct()
+	valueCount := int(header[5].(map[int16]interface{})[1].(int64))
+	page := data[reader.pos : reader.pos+int(header[3].(int64))]
+
+	defined := make([]bool, 0, valueCount)
+	if !optional {
+		for i := 0; i < valueCount; i++ {
+			defined = append(defined, true)
+		}
+		return defined, page
+	}
+	levelsLength := int(binary.LittleEndian.Uint32(page))
+	levels := page[4 : 4+levelsLength]
+	for len(levels) > 0 {
+		run, n := binary.Uvarint(levels)
+		if run&1 != 0 {
+			t.Fatalf("Expected RLE runs of definition levels but found bit packing")
+		}
+		for i := uint64(0); i < run>>1; i++ {
+			defined = append(defined, levels[n] == 1)
+		}
+		levels = levels[n+1:]
+	}
+	if len(defined) != valueCount {
+		t.Errorf("Expected %d definition levels but was %d", valueCount, len(defined))
+	}
+	return defined, page[4+levelsLength:]
+}
+
+// thriftReader decodes Thrift compact protocol structs into maps of field ID
+// to int64, string, []interface{} or a nested map.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var last int16
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint())
+		}
+		fields[id] = r.readValue(header & 0x0f)
+		last = id
+	}
+}
+
+func (r *thriftReader) readValue(valueType byte) interface{} {
+	switch valueType {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		length := int(r.uvarint())
+		value := string(r.data[r.pos : r.pos+length])
+		r.pos += length
+		return value
+	case thriftList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.readValue(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic("unexpected Thrift type")
+}
+
+func (r *thriftReader) varint() int64 {
+	value, n := binary.Varint(r.data[r.pos:])
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return value
+}
+
+func TestExportRequiresStartTime(t *testing.T) {
+	if _, err := New(nil, NewCSVWriter(&bytes.Buffer{})).Measurements(&models.MeasurementQuery{}); err == nil {
+		t.Errorf("Expected error without start time")
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	for _, format := range []string{"csv", "jsonl", "parquet"} {
+		if _, err := NewWriter(format, &bytes.Buffer{}); err != nil {
+			t.Errorf("Error %s for format %s", err, format)
+		}
+	}
+	if _, err := NewWriter("xml", &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,443 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Parquet files are written by hand, like the remote write protobuf decoding,
+// to avoid pulling a full Parquet implementation and its compression codecs
+// into the client. The writer only needs flat schemas, PLAIN encoded values,
+// RLE definition levels and uncompressed pages, with one data page per column
+// chunk. The file footer is Thrift compact protocol.
+
+const (
+	parquetMagic        = "PAR1"
+	parquetRowGroupSize = 16 << 20
+
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32
+	optional      bool
+
+	defined []bool
+	strings []string
+	int64s  []int64
+	doubles []float64
+}
+
+func (c *parquetColumn) appendNull() {
+	c.defined = append(c.defined, false)
+}
+
+func (c *parquetColumn) appendString(value string) {
+	c.defined = append(c.defined, true)
+	c.strings = append(c.strings, value)
+}
+
+func (c *parquetColumn) reset() {
+	c.defined = c.defined[:0]
+	c.strings = c.strings[:0]
+	c.int64s = c.int64s[:0]
+	c.doubles = c.doubles[:0]
+}
+
+// page returns the PLAIN encoded data page body of the buffered values.
+func (c *parquetColumn) page() []byte {
+	page := &bytes.Buffer{}
+	if c.optional {
+		levels := encodeDefinitionLevels(c.defined)
+		binary.Write(page, binary.LittleEndian, uint32(len(levels)))
+		page.Write(levels)
+	}
+	switch c.physicalType {
+	case parquetByteArray:
+		for _, value := range c.strings {
+			binary.Write(page, binary.LittleEndian, uint32(len(value)))
+			page.WriteString(value)
+		}
+	case parquetInt64:
+		for _, value := range c.int64s {
+			binary.Write(page, binary.LittleEndian, value)
+		}
+	case parquetDouble:
+		for _, value := range c.doubles {
+			binary.Write(page, binary.LittleEndian, math.Float64bits(value))
+		}
+	}
+	return page.Bytes()
+}
+
+// encodeDefinitionLevels writes levels of bit width 1 as RLE runs of the
+// RLE/bit-packing hybrid encoding.
+func encodeDefinitionLevels(defined []bool) []byte {
+	levels := &bytes.Buffer{}
+	varint := make([]byte, binary.MaxVarintLen64)
+	for start := 0; start < len(defined); {
+		end := start
+		for end < len(defined) && defined[end] == defined[start] {
+			end++
+		}
+		levels.Write(varint[:binary.PutUvarint(varint, uint64(end-start)<<1)])
+		if defined[start] {
+			levels.WriteByte(1)
+		} else {
+			levels.WriteByte(0)
+		}
+		start = end
+	}
+	return levels.Bytes()
+}
+
+type parquetColumnChunk struct {
+	name         string
+	physicalType int32
+	offset       int64
+	size         int64
+	valueCount   int64
+}
+
+type parquetRowGroup struct {
+	chunks []parquetColumnChunk
+	size   int64
+	rows   int64
+}
+
+// ParquetWriter writes a flat Parquet file with the columns of columnNames:
+// UTF8 strings, the timestamp as TIMESTAMP_MILLIS and the values as doubles.
+// Rows are buffered per row group of about 16MB, not for the whole export.
+type ParquetWriter struct {
+	output io.Writer
+	layout Layout
+
+	columns    []*parquetColumn
+	offset     int64
+	rows       int64
+	bufferSize int64
+	rowGroups  []parquetRowGroup
+}
+
+func NewParquetWriter(w io.Writer) *ParquetWriter {
+	return &ParquetWriter{output: w}
+}
+
+func (p *ParquetWriter) Begin(layout Layout) error {
+	p.layout = layout
+	p.columns = nil
+	for i, name := range columnNames(layout) {
+		column := &parquetColumn{name: name, physicalType: parquetByteArray, convertedType: parquetUTF8, optional: true}
+		switch {
+		case i == 0:
+			column.optional = false
+		case i == 1:
+			column.physicalType, column.convertedType, column.optional = parquetInt64, parquetTimestampMillis, false
+		case i < 2+len(layout.Values):
+			column.physicalType, column.convertedType = parquetDouble, -1
+		}
+		p.columns = append(p.columns, column)
+	}
+	return p.write([]byte(parquetMagic))
+}
+
+func (p *ParquetWriter) Write(row Row) error {
+	p.columns[0].appendString(row.Name)
+	p.columns[1].defined = append(p.columns[1].defined, true)
+	p.columns[1].int64s = append(p.columns[1].int64s, row.Timestamp.UnixNano()/1e6)
+	size := int64(len(row.Name) + 8)
+
+	index := 2
+	for _, value := range row.Values {
+		column := p.columns[index]
+		if value == nil {
+			column.appendNull()
+		} else {
+			column.defined = append(column.defined, true)
+			column.doubles = append(column.doubles, *value)
+			size += 8
+		}
+		index++
+	}
+	if p.layout.ValueMeta {
+		if len(row.ValueMeta) > 0 {
+			encoded, err := json.Marshal(row.ValueMeta)
+			if err != nil {
+				return err
+			}
+			p.columns[index].appendString(string(encoded))
+			size += int64(len(encoded))
+		} else {
+			p.columns[index].appendNull()
+		}
+		index++
+	}
+	for _, dimension := range p.layout.Dimensions {
+		if value, found := row.Dimensions[dimension]; found {
+			p.columns[index].appendString(value)
+			size += int64(len(value))
+		} else {
+			p.columns[index].appendNull()
+		}
+		index++
+	}
+
+	p.rows++
+	p.bufferSize += size
+	if p.bufferSize >= parquetRowGroupSize {
+		return p.flushRowGroup()
+	}
+	return nil
+}
+
+func (p *ParquetWriter) Close() error {
+	if p.columns == nil {
+		return nil
+	}
+	if err := p.flushRowGroup(); err != nil {
+		return err
+	}
+	footer := p.footer()
+	if err := p.write(footer); err != nil {
+		return err
+	}
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(footer)))
+	if err := p.write(length); err != nil {
+		return err
+	}
+	return p.write([]byte(parquetMagic))
+}
+
+func (p *ParquetWriter) write(data []byte) error {
+	n, err := p.output.Write(data)
+	p.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("Failed to write Parquet: %v", err)
+	}
+	return nil
+}
+
+func (p *ParquetWriter) flushRowGroup() error {
+	if p.rows == 0 {
+		return nil
+	}
+	rowGroup := parquetRowGroup{rows: p.rows}
+	for _, column := range p.columns {
+		page := column.page()
+		header := &thriftWriter{}
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5)
+		header.i32(1, int32(len(column.defined)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.stop()
+
+		chunk := parquetColumnChunk{
+			name:         column.name,
+			physicalType: column.physicalType,
+			offset:       p.offset,
+			size:         int64(header.buffer.Len() + len(page)),
+			valueCount:   int64(len(column.defined)),
+		}
+		if err := p.write(header.buffer.Bytes()); err != nil {
+			return err
+		}
+		if err := p.write(page); err != nil {
+			return err
+		}
+		rowGroup.chunks = append(rowGroup.chunks, chunk)
+		rowGroup.size += chunk.size
+		column.reset()
+	}
+	p.rowGroups = append(p.rowGroups, rowGroup)
+	p.rows = 0
+	p.bufferSize = 0
+	return nil
+}
+
+func (p *ParquetWriter) footer() []byte {
+	var totalRows int64
+	for _, rowGroup := range p.rowGroups {
+		totalRows += rowGroup.rows
+	}
+
+	footer := &thriftWriter{}
+	footer.i32(1, 1)
+	footer.beginList(2, thriftStruct, len(p.columns)+1)
+	footer.beginElement()
+	footer.binary(4, "schema")
+	footer.i32(5, int32(len(p.columns)))
+	footer.endStruct()
+	for _, column := range p.columns {
+		footer.beginElement()
+		footer.i32(1, column.physicalType)
+		if column.optional {
+			footer.i32(3, parquetOptional)
+		} else {
+			footer.i32(3, parquetRequired)
+		}
+		footer.binary(4, column.name)
+		if column.convertedType >= 0 {
+			footer.i32(6, column.convertedType)
+		}
+		footer.endStruct()
+	}
+	footer.i64(3, totalRows)
+
+	footer.beginList(4, thriftStruct, len(p.rowGroups))
+	for _, rowGroup := range p.rowGroups {
+		footer.beginElement()
+		footer.beginList(1, thriftStruct, len(rowGroup.chunks))
+		for _, chunk := range rowGroup.chunks {
+			footer.beginElement()
+			footer.i64(2, chunk.offset)
+			footer.beginStruct(3)
+			footer.i32(1, chunk.physicalType)
+			footer.beginList(2, thriftI32, 2)
+			footer.listI32(parquetPlain)
+			footer.listI32(parquetRLE)
+			footer.beginList(3, thriftBinary, 1)
+			footer.listBinary(chunk.name)
+			footer.i32(4, 0) // UNCOMPRESSED
+			footer.i64(5, chunk.valueCount)
+			footer.i64(6, chunk.size)
+			footer.i64(7, chunk.size)
+			footer.i64(9, chunk.offset)
+			footer.endStruct()
+			footer.endStruct()
+		}
+		footer.i64(2, rowGroup.size)
+		footer.i64(3, rowGroup.rows)
+		footer.endStruct()
+	}
+	footer.binary(6, "golang-monascaclient")
+	footer.stop()
+	return footer.buffer.Bytes()
+}
+
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes Thrift compact protocol structs. Nested structs are
+// opened with beginStruct, or beginElement inside a list, and closed with
+// endStruct; the outermost struct is closed with stop.
+type thriftWriter struct {
+	buffer    bytes.Buffer
+	lastField []int16
+	last      int16
+}
+
+func (t *thriftWriter) field(id int16, fieldType byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buffer.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buffer.WriteByte(fieldType)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(value int64) {
+	encoded := make([]byte, binary.MaxVarintLen64)
+	t.buffer.Write(encoded[:binary.PutVarint(encoded, value)])
+}
+
+func (t *thriftWriter) uvarint(value uint64) {
+	encoded := make([]byte, binary.MaxVarintLen64)
+	t.buffer.Write(encoded[:binary.PutUvarint(encoded, value)])
+}
+
+func (t *thriftWriter) i32(id int16, value int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(value))
+}
+
+func (t *thriftWriter) i64(id int16, value int64) {
+	t.field(id, thriftI64)
+	t.varint(value)
+}
+
+func (t *thriftWriter) binary(id int16, value string) {
+	t.field(id, thriftBinary)
+	t.listBinary(value)
+}
+
+func (t *thriftWriter) beginList(id int16, elementType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buffer.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		t.buffer.WriteByte(0xf0 | elementType)
+		t.uvarint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) listI32(value int32) {
+	t.varint(int64(value))
+}
+
+func (t *thriftWriter) listBinary(value string) {
+	t.uvarint(uint64(len(value)))
+	t.buffer.WriteString(value)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElement()
+}
+
+func (t *thriftWriter) beginElement() {
+	t.lastField = append(t.lastField, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.last = t.lastField[len(t.lastField)-1]
+	t.lastField = t.lastField[:len(t.lastField)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buffer.WriteByte(0)
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// CSVWriter writes one row per point with a header of columnNames. Missing
+// values and dimensions are empty, value_meta is a JSON object.
+type CSVWriter struct {
+	writer *csv.Writer
+	layout Layout
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{writer: csv.NewWriter(w)}
+}
+
+func (c *CSVWriter) Begin(layout Layout) error {
+	c.layout = layout
+	return c.writer.Write(columnNames(layout))
+}
+
+func (c *CSVWriter) Write(row Row) error {
+	record := make([]string, 0, 3+len(c.layout.Values)+len(c.layout.Dimensions))
+	record = append(record, row.Name, row.Timestamp.UTC().Format(timeFormat))
+	for _, value := range row.Values {
+		if value == nil {
+			record = append(record, "")
+		} else {
+			record = append(record, strconv.FormatFloat(*value, 'g', -1, 64))
+		}
+	}
+	if c.layout.ValueMeta {
+		valueMeta := ""
+		if len(row.ValueMeta) > 0 {
+			encoded, err := json.Marshal(row.ValueMeta)
+			if err != nil {
+				return err
+			}
+			valueMeta = string(encoded)
+		}
+		record = append(record, valueMeta)
+	}
+	for _, dimension := range c.layout.Dimensions {
+		record = append(record, row.Dimensions[dimension])
+	}
+	return c.writer.Write(record)
+}
+
+func (c *CSVWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// JSONLinesWriter writes one JSON object per point with the name, timestamp,
+// dimensions, value_meta and one member per value.
+type JSONLinesWriter struct {
+	encoder *json.Encoder
+	layout  Layout
+}
+
+func NewJSONLinesWriter(w io.Writer) *JSONLinesWriter {
+	return &JSONLinesWriter{encoder: json.NewEncoder(w)}
+}
+
+func (j *JSONLinesWriter) Begin(layout Layout) error {
+	j.layout = layout
+	return nil
+}
+
+func (j *JSONLinesWriter) Write(row Row) error {
+	object := map[string]interface{}{
+		"name":       row.Name,
+		"timestamp":  row.Timestamp.UTC().Format(timeFormat),
+		"dimensions": row.Dimensions,
+	}
+	for i, name := range j.layout.Values {
+		if row.Values[i] != nil {
+			object[name] = *row.Values[i]
+		}
+	}
+	if len(row.ValueMeta) > 0 {
+		object["value_meta"] = row.ValueMeta
+	}
+	return j.encoder.Encode(object)
+}
+
+func (j *JSONLinesWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package exporter
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Config is the YAML configuration of the monasca-exporter binary.
+type Config struct {
+	MonascaURL string      `yaml:"monasca_url"`
+	Insecure   bool        `yaml:"insecure"`
+	Timeout    int         `yaml:"timeout"`
+	Keystone   bool        `yaml:"keystone"`
+	Listen     string      `yaml:"listen"`
+	Prefix     *string     `yaml:"prefix"`
+	Jobs       []JobConfig `yaml:"jobs"`
+}
+
+// JobConfig describes a job. Jobs listing statistics run a statistics query,
+// the others a measurements query.
+type JobConfig struct {
+	Name         string            `yaml:"name"`
+	Interval     time.Duration     `yaml:"interval"`
+	Lookback     time.Duration     `yaml:"lookback"`
+	TenantID     string            `yaml:"tenant_id"`
+	Metric       string            `yaml:"metric"`
+	Dimensions   map[string]string `yaml:"dimensions"`
+	Statistics   []string          `yaml:"statistics"`
+	Period       int               `yaml:"period"`
+	GroupBy      string            `yaml:"group_by"`
+	MergeMetrics bool              `yaml:"merge_metrics"`
+}
+
+func LoadConfig(reader io.Reader) (*Config, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	config := new(Config)
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse exporter config: %v", err)
+	}
+	return config, nil
+}
+
+// BuildJobs converts the job configurations into Jobs.
+func (c *Config) BuildJobs() ([]Job, error) {
+	jobs := make([]Job, 0, len(c.Jobs))
+	for i := range c.Jobs {
+		jobConfig := c.Jobs[i]
+		if jobConfig.Metric == "" {
+			return nil, fmt.Errorf("Job %q has no metric", jobConfig.Name)
+		}
+		job := Job{Name: jobConfig.Name, Interval: jobConfig.Interval, Lookback: jobConfig.Lookback}
+
+		var tenantID *string
+		if jobConfig.TenantID != "" {
+			tenantID = &jobConfig.TenantID
+		}
+		var dimensions *map[string]string
+		if len(jobConfig.Dimensions) > 0 {
+			dimensions = &jobConfig.Dimensions
+		}
+		var groupBy *string
+		if jobConfig.GroupBy != "" {
+			groupBy = &jobConfig.GroupBy
+		}
+		var merge *bool
+		if jobConfig.MergeMetrics {
+			merge = &jobConfig.MergeMetrics
+		}
+
+		if len(jobConfig.Statistics) > 0 {
+			if jobConfig.Period <= 0 {
+				return nil, fmt.Errorf("Job %q needs a period for statistics", jobConfig.Name)
+			}
+			statistics := strings.Join(jobConfig.Statistics, ",")
+			job.StatisticQuery = &models.StatisticQuery{
+				TenantID:   tenantID,
+				Name:       &jobConfig.Metric,
+				Dimensions: dimensions,
+				Statistics: &statistics,
+				Period:     &jobConfig.Period,
+				Merge:      merge,
+				GroupBy:    groupBy,
+			}
+		} else {
+			job.MeasurementQuery = &models.MeasurementQuery{
+				TenantID:   tenantID,
+				Name:       &jobConfig.Metric,
+				Dimensions: dimensions,
+				Merge:      merge,
+				GroupBy:    groupBy,
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
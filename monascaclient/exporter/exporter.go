@@ -0,0 +1,434 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package exporter periodically queries Monasca and exposes the latest values
+// in the Prometheus text exposition format.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPrefix   = "monasca_"
+	defaultInterval = time.Minute
+	defaultLookback = 10 * time.Minute
+)
+
+// Source runs the queries of the jobs; a *monascaclient.Client satisfies it.
+type Source interface {
+	ForEachStatisticPage(statisticsQuery *models.StatisticQuery, handle func(*models.StatisticsResponse) error) error
+	ForEachMeasurementPage(measurementQuery *models.MeasurementQuery, handle func(*models.MeasurementsResponse) error) error
+}
+
+// Job is a query run every Interval over the last Lookback. Exactly one of
+// StatisticQuery and MeasurementQuery must be set; their start and end times
+// are overwritten on each run.
+type Job struct {
+	Name             string
+	Interval         time.Duration
+	Lookback         time.Duration
+	StatisticQuery   *models.StatisticQuery
+	MeasurementQuery *models.MeasurementQuery
+}
+
+type sample struct {
+	name   string
+	help   string
+	labels map[string]string
+	value  float64
+}
+
+type jobState struct {
+	samples     []sample
+	errors      int
+	lastSuccess time.Time
+}
+
+// Exporter holds the latest samples of every job and serves them on
+// /metrics through ServeHTTP.
+type Exporter struct {
+	source Source
+	jobs   []Job
+	prefix string
+
+	lock   sync.RWMutex
+	states map[string]*jobState
+}
+
+// New checks the jobs and fills in their defaults. Two jobs querying the same
+// metric with the same dimensions are rejected, as they would export the same
+// series.
+func New(source Source, jobs []Job) (*Exporter, error) {
+	names := map[string]bool{}
+	exported := map[string]string{}
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Name == "" {
+			return nil, fmt.Errorf("Job %d has no name", i)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("Duplicate job %q", job.Name)
+		}
+		names[job.Name] = true
+		if (job.StatisticQuery == nil) == (job.MeasurementQuery == nil) {
+			return nil, fmt.Errorf("Job %q needs exactly one of a statistic or a measurement query", job.Name)
+		}
+		if job.Interval <= 0 {
+			job.Interval = defaultInterval
+		}
+		if job.Lookback <= 0 {
+			job.Lookback = defaultLookback
+		}
+		for _, series := range exportedSeries(job) {
+			if other, found := exported[series]; found {
+				return nil, fmt.Errorf("Jobs %q and %q both export %s", other, job.Name, series)
+			}
+			exported[series] = job.Name
+		}
+	}
+
+	exporter := &Exporter{source: source, jobs: jobs, prefix: DefaultPrefix, states: map[string]*jobState{}}
+	for _, job := range jobs {
+		exporter.states[job.Name] = &jobState{}
+	}
+	return exporter, nil
+}
+
+func (e *Exporter) SetPrefix(prefix string) {
+	e.prefix = prefix
+}
+
+// Run runs every job on its own interval until ctx is done.
+func (e *Exporter) Run(ctx context.Context) {
+	var wait sync.WaitGroup
+	for i := range e.jobs {
+		wait.Add(1)
+		go func(job *Job) {
+			defer wait.Done()
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+			for {
+				e.runJob(job, time.Now())
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(&e.jobs[i])
+	}
+	wait.Wait()
+}
+
+// Collect runs every job once and returns the first error.
+func (e *Exporter) Collect() error {
+	var firstErr error
+	now := time.Now()
+	for i := range e.jobs {
+		err := e.runJob(&e.jobs[i], now)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (e *Exporter) runJob(job *Job, now time.Time) error {
+	start := now.Add(-job.Lookback)
+	var samples []sample
+	var err error
+	if job.StatisticQuery != nil {
+		query := *job.StatisticQuery
+		query.StartTime, query.EndTime = &start, &now
+		err = e.source.ForEachStatisticPage(&query, func(response *models.StatisticsResponse) error {
+			samples = append(samples, e.statisticSamples(response.Elements)...)
+			return nil
+		})
+	} else {
+		query := *job.MeasurementQuery
+		query.StartTime, query.EndTime = &start, &now
+		err = e.source.ForEachMeasurementPage(&query, func(response *models.MeasurementsResponse) error {
+			samples = append(samples, e.measurementSamples(response.Elements)...)
+			return nil
+		})
+	}
+	samples = latestSamples(samples)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	state := e.states[job.Name]
+	if err != nil {
+		state.errors++
+		return fmt.Errorf("Job %q failed: %v", job.Name, err)
+	}
+	state.samples = samples
+	state.lastSuccess = now
+	return nil
+}
+
+// latestSamples keeps the last sample of every series, since a series that
+// continues on the next page shows up once per page.
+func latestSamples(samples []sample) []sample {
+	index := map[string]int{}
+	latest := make([]sample, 0, len(samples))
+	for _, s := range samples {
+		key := s.name + formatLabels(s.labels)
+		if i, found := index[key]; found {
+			latest[i] = s
+			continue
+		}
+		index[key] = len(latest)
+		latest = append(latest, s)
+	}
+	return latest
+}
+
+// exportedSeries identifies the series of a job by the metric names it exports
+// and its dimension filter.
+func exportedSeries(job *Job) []string {
+	var metricName string
+	var names []string
+	var filter string
+	if query := job.StatisticQuery; query != nil {
+		if query.Name != nil {
+			metricName = SanitizeMetricName(*query.Name)
+		}
+		if query.Statistics != nil {
+			for _, statistic := range strings.Split(*query.Statistics, ",") {
+				names = append(names, metricName+"_"+SanitizeMetricName(strings.TrimSpace(statistic)))
+			}
+		}
+		filter = dimensionFilterString(query.Dimensions, query.DimensionFilter)
+	} else if query := job.MeasurementQuery; query != nil {
+		if query.Name != nil {
+			metricName = SanitizeMetricName(*query.Name)
+		}
+		names = append(names, metricName)
+		filter = dimensionFilterString(query.Dimensions, query.DimensionFilter)
+	}
+	series := make([]string, 0, len(names))
+	for _, name := range names {
+		series = append(series, name+"{"+filter+"}")
+	}
+	return series
+}
+
+func dimensionFilterString(dimensions *map[string]string, filter *models.DimensionFilter) string {
+	if filter != nil {
+		return filter.String()
+	}
+	if dimensions != nil {
+		return models.DimensionFilterFromMap(*dimensions).String()
+	}
+	return ""
+}
+
+func (e *Exporter) statisticSamples(elements []models.StatisticElement) []sample {
+	samples := []sample{}
+	for _, element := range elements {
+		if len(element.Statistics) == 0 {
+			continue
+		}
+		latest := element.Statistics[len(element.Statistics)-1]
+		for i, column := range element.Columns {
+			if column == "timestamp" || i >= len(latest) {
+				continue
+			}
+			value, ok := latest[i].(float64)
+			if !ok {
+				continue
+			}
+			samples = append(samples, sample{
+				name:   e.prefix + SanitizeMetricName(element.Name) + "_" + SanitizeMetricName(column),
+				help:   fmt.Sprintf("Monasca statistic %s of %s", column, element.Name),
+				labels: SanitizeLabels(element.Dimensions),
+				value:  value,
+			})
+		}
+	}
+	return samples
+}
+
+func (e *Exporter) measurementSamples(elements []models.MeasurementElement) []sample {
+	samples := []sample{}
+	for _, element := range elements {
+		valueIndex := -1
+		for i, column := range element.Columns {
+			if column == "value" {
+				valueIndex = i
+			}
+		}
+		if valueIndex < 0 || len(element.Measurements) == 0 {
+			continue
+		}
+		latest := element.Measurements[len(element.Measurements)-1]
+		if valueIndex >= len(latest) {
+			continue
+		}
+		value, ok := latest[valueIndex].(float64)
+		if !ok {
+			continue
+		}
+		samples = append(samples, sample{
+			name:   e.prefix + SanitizeMetricName(element.Name),
+			help:   fmt.Sprintf("Monasca measurement %s", element.Name),
+			labels: SanitizeLabels(element.Dimensions),
+			value:  value,
+		})
+	}
+	return samples
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.WriteMetrics(w)
+}
+
+// WriteMetrics writes the latest samples of every job followed by the
+// exporter's own job metrics.
+func (e *Exporter) WriteMetrics(writer io.Writer) error {
+	e.lock.RLock()
+	byName := map[string][]sample{}
+	jobNames := make([]string, 0, len(e.states))
+	for name, state := range e.states {
+		jobNames = append(jobNames, name)
+		for _, s := range state.samples {
+			byName[s.name] = append(byName[s.name], s)
+		}
+	}
+	sort.Strings(jobNames)
+	for _, name := range jobNames {
+		state := e.states[name]
+		labels := map[string]string{"job": name}
+		errorsName := e.prefix + "exporter_job_errors_total"
+		byName[errorsName] = append(byName[errorsName], sample{name: errorsName, help: "Failed runs of an exporter job", labels: labels, value: float64(state.errors)})
+		if !state.lastSuccess.IsZero() {
+			successName := e.prefix + "exporter_job_last_success_timestamp_seconds"
+			byName[successName] = append(byName[successName], sample{name: successName, help: "Time of the last successful run of an exporter job", labels: labels, value: float64(state.lastSuccess.Unix())})
+		}
+	}
+	e.lock.RUnlock()
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	for _, name := range names {
+		samples := byName[name]
+		metricType := "gauge"
+		if strings.HasSuffix(name, "_total") {
+			metricType = "counter"
+		}
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(samples[0].help), name, metricType)
+		lines := make([]string, 0, len(samples))
+		for _, s := range samples {
+			lines = append(lines, name+formatLabels(s.labels)+" "+strconv.FormatFloat(s.value, 'g', -1, 64)+"\n")
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			out.WriteString(line)
+		}
+	}
+	_, err := io.WriteString(writer, out.String())
+	return err
+}
+
+// SanitizeMetricName maps a Monasca metric name such as cpu.idle_perc to a
+// valid Prometheus metric name, cpu_idle_perc.
+func SanitizeMetricName(name string) string {
+	return sanitize(name, true)
+}
+
+// SanitizeLabels maps dimensions to valid Prometheus labels. Label names that
+// collide after sanitizing keep the value of the first name in sorted order.
+func SanitizeLabels(dimensions map[string]string) map[string]string {
+	keys := make([]string, 0, len(dimensions))
+	for key := range dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	labels := make(map[string]string, len(dimensions))
+	for _, key := range keys {
+		label := strings.TrimLeft(sanitize(key, false), "_")
+		if label == "" {
+			continue
+		}
+		if label[0] >= '0' && label[0] <= '9' {
+			label = "_" + label
+		}
+		if _, found := labels[label]; !found {
+			labels[label] = dimensions[key]
+		}
+	}
+	return labels
+}
+
+func sanitize(name string, allowColon bool) string {
+	var out strings.Builder
+	for i, char := range name {
+		valid := char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') ||
+			(char >= '0' && char <= '9' && i > 0) || (allowColon && char == ':')
+		if char >= '0' && char <= '9' && i == 0 {
+			out.WriteRune('_')
+			valid = true
+		}
+		if valid {
+			out.WriteRune(char)
+		} else {
+			out.WriteRune('_')
+		}
+	}
+	return out.String()
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabelValue(labels[name])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
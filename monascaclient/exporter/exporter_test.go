@@ -0,0 +1,183 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package exporter
+
+import (
+	"errors"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"strings"
+	"testing"
+)
+
+type testSource struct {
+	statistics   []*models.StatisticsResponse
+	measurements *models.MeasurementsResponse
+	err          error
+}
+
+func (s *testSource) ForEachStatisticPage(query *models.StatisticQuery, handle func(*models.StatisticsResponse) error) error {
+	if query.StartTime == nil || query.EndTime == nil {
+		return errors.New("missing time range")
+	}
+	if s.err != nil {
+		return s.err
+	}
+	for _, page := range s.statistics {
+		if err := handle(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testSource) ForEachMeasurementPage(query *models.MeasurementQuery, handle func(*models.MeasurementsResponse) error) error {
+	if s.err != nil {
+		return s.err
+	}
+	return handle(s.measurements)
+}
+
+func TestSanitize(t *testing.T) {
+	if name := SanitizeMetricName("cpu.idle-perc"); name != "cpu_idle_perc" {
+		t.Errorf("Unexpected metric name %s", name)
+	}
+	if name := SanitizeMetricName("1xx"); name != "_1xx" {
+		t.Errorf("Unexpected metric name %s", name)
+	}
+	labels := SanitizeLabels(map[string]string{"host.name": "a", "host_name": "b", "__id": "c", "9": "d"})
+	expected := map[string]string{"host_name": "a", "id": "c", "_9": "d"}
+	if len(labels) != len(expected) {
+		t.Fatalf("Expected %v but was %v", expected, labels)
+	}
+	for key, value := range expected {
+		if labels[key] != value {
+			t.Errorf("Expected label %s=%s but was %v", key, value, labels)
+		}
+	}
+}
+
+func TestExporterWritesLatestValues(t *testing.T) {
+	config, err := LoadConfig(strings.NewReader(`
+jobs:
+  - name: cpu
+    metric: cpu.idle_perc
+    statistics: [avg, max]
+    period: 300
+    interval: 30s
+  - name: disk
+    metric: disk.used
+`))
+	if err != nil {
+		t.Fatalf("Error %s loading config", err)
+	}
+	jobs, err := config.BuildJobs()
+	if err != nil {
+		t.Fatalf("Error %s building jobs", err)
+	}
+	if jobs[0].Interval.Seconds() != 30 || jobs[0].StatisticQuery == nil || jobs[1].MeasurementQuery == nil {
+		t.Fatalf("Unexpected jobs %+v", jobs)
+	}
+
+	source := &testSource{
+		statistics: []*models.StatisticsResponse{
+			{Elements: []models.StatisticElement{{
+				Name:       "cpu.idle_perc",
+				Dimensions: map[string]string{"hostname": `web"1`},
+				Columns:    []string{"timestamp", "avg", "max"},
+				Statistics: [][]interface{}{{"2017-02-27T06:00:00Z", 1.0, 2.0}},
+			}}},
+			{Elements: []models.StatisticElement{{
+				Name:       "cpu.idle_perc",
+				Dimensions: map[string]string{"hostname": `web"1`},
+				Columns:    []string{"timestamp", "avg", "max"},
+				Statistics: [][]interface{}{{"2017-02-27T06:05:00Z", 12.5, 40.0}},
+			}}},
+		},
+		measurements: &models.MeasurementsResponse{Elements: []models.MeasurementElement{{
+			Name:         "disk.used",
+			Columns:      []string{"timestamp", "value", "value_meta"},
+			Measurements: [][]interface{}{{"2017-02-27T06:00:00Z", 90.0, nil}},
+		}}},
+	}
+	metricsExporter, err := New(source, jobs)
+	if err != nil {
+		t.Fatalf("Error %s creating exporter", err)
+	}
+	err = metricsExporter.Collect()
+	if err != nil {
+		t.Fatalf("Error %s collecting", err)
+	}
+
+	var out strings.Builder
+	metricsExporter.WriteMetrics(&out)
+	for _, line := range []string{
+		"# TYPE monasca_cpu_idle_perc_avg gauge",
+		`monasca_cpu_idle_perc_avg{hostname="web\"1"} 12.5`,
+		`monasca_cpu_idle_perc_max{hostname="web\"1"} 40`,
+		"monasca_disk_used 90",
+		`monasca_exporter_job_errors_total{job="cpu"} 0`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Expected line '%s' in output:\n%s", line, out.String())
+		}
+	}
+	if strings.Count(out.String(), "monasca_cpu_idle_perc_avg{") != 1 {
+		t.Errorf("Expected a series continued on the next page to be written once:\n%s", out.String())
+	}
+
+	source.err = errors.New("unavailable")
+	if metricsExporter.Collect() == nil {
+		t.Errorf("Expected collect error")
+	}
+	out.Reset()
+	metricsExporter.WriteMetrics(&out)
+	if !strings.Contains(out.String(), `monasca_exporter_job_errors_total{job="disk"} 1`) || !strings.Contains(out.String(), "monasca_disk_used 90") {
+		t.Errorf("Expected error count and previous values to be kept:\n%s", out.String())
+	}
+}
+
+func TestNewRejectsInvalidJobs(t *testing.T) {
+	if _, err := New(&testSource{}, []Job{{Name: "empty"}}); err == nil {
+		t.Errorf("Expected error for job without query")
+	}
+
+	config, err := LoadConfig(strings.NewReader(`
+jobs:
+  - name: cpu
+    metric: cpu.idle_perc
+    dimensions: {hostname: web1}
+  - name: cpu-web2
+    metric: cpu.idle_perc
+    dimensions: {hostname: web2}
+  - name: cpu-again
+    metric: cpu.idle_perc
+    dimensions: {hostname: web1}
+    tenant_id: other
+`))
+	if err != nil {
+		t.Fatalf("Error %s loading config", err)
+	}
+	jobs, err := config.BuildJobs()
+	if err != nil {
+		t.Fatalf("Error %s building jobs", err)
+	}
+	_, err = New(&testSource{}, jobs)
+	if err == nil || !strings.Contains(err.Error(), `"cpu" and "cpu-again"`) {
+		t.Errorf("Expected error for jobs exporting the same series but was %v", err)
+	}
+	if _, err := New(&testSource{}, jobs[:2]); err != nil {
+		t.Errorf("Expected jobs with different dimensions to be accepted but was %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package ingest
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type graphiteTemplate struct {
+	filter     []string
+	parts      []string
+	dimensions map[string]string
+}
+
+// GraphiteParser converts Graphite plaintext lines, "path value [timestamp]",
+// into metrics. Templates of the form
+//
+//	[filter] template [dimension=value,...]
+//
+// map the dot separated segments of a path onto the metric name and
+// dimensions, for example "servers.* .host.measurement*" turns
+// servers.web1.cpu.idle into cpu.idle{host=web1}. In the template,
+// "measurement" and "field" segments form the metric name, "measurement*"
+// takes all remaining segments, empty segments are dropped and any other name
+// becomes a dimension. Filters match segment by segment with * globs; the
+// first matching template wins and a template without a filter is the
+// fallback. Paths no template matches are used as the metric name unchanged.
+// Graphite tags, path;tag=value, become dimensions as well.
+type GraphiteParser struct {
+	templates []graphiteTemplate
+	fallback  *graphiteTemplate
+	separator string
+}
+
+func NewGraphiteParser(templates []string) (*GraphiteParser, error) {
+	parser := &GraphiteParser{separator: "."}
+	for _, template := range templates {
+		parsed, err := parseGraphiteTemplate(template)
+		if err != nil {
+			return nil, err
+		}
+		if parsed.filter == nil {
+			if parser.fallback != nil {
+				return nil, fmt.Errorf("Invalid Graphite template %q: only one template may omit the filter", template)
+			}
+			parser.fallback = &parsed
+			continue
+		}
+		parser.templates = append(parser.templates, parsed)
+	}
+	return parser, nil
+}
+
+// SetSeparator sets the string joining the segments of the metric name.
+func (p *GraphiteParser) SetSeparator(separator string) {
+	p.separator = separator
+}
+
+func parseGraphiteTemplate(template string) (graphiteTemplate, error) {
+	parsed := graphiteTemplate{dimensions: map[string]string{}}
+	fields := strings.Fields(template)
+	if len(fields) == 0 || len(fields) > 3 {
+		return parsed, fmt.Errorf("Invalid Graphite template %q", template)
+	}
+	if len(fields) == 3 || (len(fields) == 2 && !strings.Contains(fields[1], "=")) {
+		parsed.filter = strings.Split(fields[0], ".")
+		fields = fields[1:]
+	}
+	for _, pattern := range parsed.filter {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return parsed, fmt.Errorf("Invalid Graphite template %q: bad filter: %v", template, err)
+		}
+	}
+
+	parsed.parts = strings.Split(fields[0], ".")
+	for i, part := range parsed.parts {
+		if part == "measurement*" && i != len(parsed.parts)-1 {
+			return parsed, fmt.Errorf("Invalid Graphite template %q: measurement* must be the last segment", template)
+		}
+	}
+	if len(fields) == 2 {
+		for _, pair := range strings.Split(fields[1], ",") {
+			keyValue := strings.SplitN(pair, "=", 2)
+			if len(keyValue) != 2 || keyValue[0] == "" {
+				return parsed, fmt.Errorf("Invalid Graphite template %q: bad dimension %q", template, pair)
+			}
+			parsed.dimensions[keyValue[0]] = keyValue[1]
+		}
+	}
+	return parsed, nil
+}
+
+func (t *graphiteTemplate) matches(segments []string) bool {
+	if len(t.filter) > len(segments) {
+		return false
+	}
+	for i, pattern := range t.filter {
+		if matched, _ := path.Match(pattern, segments[i]); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *graphiteTemplate) apply(segments []string, separator string) (string, map[string]string) {
+	nameParts := []string{}
+	dimensions := map[string]string{}
+	for name, value := range t.dimensions {
+		dimensions[name] = value
+	}
+	for i, part := range t.parts {
+		if i >= len(segments) {
+			break
+		}
+		switch part {
+		case "":
+		case "measurement", "field":
+			nameParts = append(nameParts, segments[i])
+		case "measurement*":
+			nameParts = append(nameParts, segments[i:]...)
+		default:
+			if existing, found := dimensions[part]; found && i > 0 && t.parts[i-1] == part {
+				dimensions[part] = existing + "." + segments[i]
+			} else {
+				dimensions[part] = segments[i]
+			}
+		}
+	}
+	return strings.Join(nameParts, separator), dimensions
+}
+
+func (p *GraphiteParser) template(segments []string) *graphiteTemplate {
+	for i := range p.templates {
+		if p.templates[i].matches(segments) {
+			return &p.templates[i]
+		}
+	}
+	return p.fallback
+}
+
+// ParseLine parses a single line. Lines without a timestamp, or with -1, are
+// stamped with now; empty lines yield no metrics.
+func (p *GraphiteParser) ParseLine(line string, now time.Time) ([]models.MetricRequestBody, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("Invalid Graphite line %q: expected path, value and timestamp", line)
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("Invalid Graphite line %q: bad value", line)
+	}
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	if len(fields) == 3 && fields[2] != "-1" {
+		seconds, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Graphite line %q: bad timestamp", line)
+		}
+		timestamp = int64(seconds * 1000)
+	}
+
+	tags := strings.Split(fields[0], ";")
+	metricPath := tags[0]
+	segments := strings.Split(metricPath, ".")
+	name := metricPath
+	dimensions := map[string]string{}
+	if template := p.template(segments); template != nil {
+		name, dimensions = template.apply(segments, p.separator)
+	}
+	for _, tag := range tags[1:] {
+		keyValue := strings.SplitN(tag, "=", 2)
+		if len(keyValue) != 2 || keyValue[0] == "" {
+			return nil, fmt.Errorf("Invalid Graphite line %q: bad tag %q", line, tag)
+		}
+		dimensions[keyValue[0]] = keyValue[1]
+	}
+
+	name = monascaclient.SanitizeMetricString(name, monascaclient.MaxMetricNameLength)
+	if name == "" {
+		return nil, fmt.Errorf("Invalid Graphite line %q: empty metric name", line)
+	}
+	metric := models.MetricRequestBody{Name: &name, Value: &value, Timestamp: &timestamp}
+	if dimensions = monascaclient.SanitizeDimensions(dimensions); len(dimensions) > 0 {
+		metric.Dimensions = &dimensions
+	}
+	return []models.MetricRequestBody{metric}, nil
+}
+
+// Parse parses every line of data. Lines that fail to parse are reported in
+// errs and skipped.
+func (p *GraphiteParser) Parse(data []byte, now time.Time) (metrics []models.MetricRequestBody, errs []error) {
+	return parseLines(data, now, p.ParseLine)
+}
@@ -0,0 +1,232 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package ingest converts InfluxDB line protocol and Graphite plaintext into
+// Monasca metrics and listens for them on the network.
+package ingest
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InfluxParser converts InfluxDB line protocol
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// into one metric per numeric field, named <measurement>.<field>. A field
+// called "value" is published under the measurement name alone. Tags become
+// dimensions, booleans become 1 or 0 and string fields are skipped.
+type InfluxParser struct {
+	precision time.Duration
+	separator string
+}
+
+func NewInfluxParser() *InfluxParser {
+	return &InfluxParser{
+		precision: time.Nanosecond,
+		separator: ".",
+	}
+}
+
+// SetPrecision sets the unit of the timestamps, nanoseconds by default. Only
+// the Influx precisions ns, us, ms and s are accepted.
+func (p *InfluxParser) SetPrecision(precision time.Duration) error {
+	switch precision {
+	case time.Nanosecond, time.Microsecond, time.Millisecond, time.Second:
+		p.precision = precision
+		return nil
+	}
+	return fmt.Errorf("Unsupported precision %v: use ns, us, ms or s", precision)
+}
+
+// influxPrecisions maps the precision parameter of the InfluxDB /write
+// endpoint to the unit of the timestamps.
+var influxPrecisions = map[string]time.Duration{
+	"n":  time.Nanosecond,
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// withPrecision returns a copy of the parser reading timestamps at the named
+// precision, as given to the InfluxDB /write endpoint.
+func (p *InfluxParser) withPrecision(name string) (*InfluxParser, error) {
+	precision, found := influxPrecisions[name]
+	if !found {
+		return nil, fmt.Errorf("Unsupported precision %q: use ns, us, ms or s", name)
+	}
+	parser := *p
+	parser.precision = precision
+	return &parser, nil
+}
+
+// SetSeparator sets the string joining measurement and field names.
+func (p *InfluxParser) SetSeparator(separator string) {
+	p.separator = separator
+}
+
+// ParseLine parses a single line. Lines without a timestamp are stamped with
+// now; empty lines and comments yield no metrics.
+func (p *InfluxParser) ParseLine(line string, now time.Time) ([]models.MetricRequestBody, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	sections := splitUnescaped(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("Invalid line protocol %q: expected measurement, fields and timestamp", line)
+	}
+
+	keyParts := splitUnescaped(sections[0], ',', false)
+	measurement := unescape(keyParts[0])
+	if measurement == "" {
+		return nil, fmt.Errorf("Invalid line protocol %q: missing measurement", line)
+	}
+	tags := map[string]string{}
+	for _, tag := range keyParts[1:] {
+		pair := splitUnescaped(tag, '=', false)
+		if len(pair) != 2 || pair[0] == "" {
+			return nil, fmt.Errorf("Invalid line protocol %q: bad tag %q", line, tag)
+		}
+		tags[unescape(pair[0])] = unescape(pair[1])
+	}
+
+	timestamp := now.UnixNano() / int64(time.Millisecond)
+	if len(sections) == 3 {
+		raw, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid line protocol %q: bad timestamp: %v", line, err)
+		}
+		if p.precision < time.Millisecond {
+			timestamp = raw / int64(time.Millisecond/p.precision)
+		} else {
+			timestamp = raw * int64(p.precision/time.Millisecond)
+		}
+	}
+
+	dimensions := monascaclient.SanitizeDimensions(tags)
+	metrics := []models.MetricRequestBody{}
+	for _, field := range splitUnescaped(sections[1], ',', true) {
+		pair := splitUnescaped(field, '=', true)
+		if len(pair) != 2 || pair[0] == "" || pair[1] == "" {
+			return nil, fmt.Errorf("Invalid line protocol %q: bad field %q", line, field)
+		}
+		value, numeric, err := parseFieldValue(pair[1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid line protocol %q: %v", line, err)
+		}
+		if !numeric {
+			continue
+		}
+		name := measurement
+		if fieldName := unescape(pair[0]); fieldName != "value" {
+			name += p.separator + fieldName
+		}
+		name = monascaclient.SanitizeMetricString(name, monascaclient.MaxMetricNameLength)
+		metric := models.MetricRequestBody{Name: &name, Value: &value, Timestamp: &timestamp}
+		if len(dimensions) > 0 {
+			metric.Dimensions = &dimensions
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// Parse parses every line of data. Lines that fail to parse are reported in
+// errs and skipped.
+func (p *InfluxParser) Parse(data []byte, now time.Time) (metrics []models.MetricRequestBody, errs []error) {
+	return parseLines(data, now, p.ParseLine)
+}
+
+func parseFieldValue(raw string) (float64, bool, error) {
+	switch {
+	case strings.HasPrefix(raw, "\""):
+		return 0, false, nil
+	case raw == "t" || raw == "T" || raw == "true" || raw == "True" || raw == "TRUE":
+		return 1, true, nil
+	case raw == "f" || raw == "F" || raw == "false" || raw == "False" || raw == "FALSE":
+		return 0, true, nil
+	case strings.HasSuffix(raw, "i"):
+		value, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("bad integer field %q", raw)
+		}
+		return float64(value), true, nil
+	case strings.HasSuffix(raw, "u"):
+		value, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("bad unsigned field %q", raw)
+		}
+		return float64(value), true, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false, fmt.Errorf("bad float field %q", raw)
+	}
+	return value, true, nil
+}
+
+// splitUnescaped splits s on sep, skipping backslash escaped separators and,
+// when quoted is set, separators inside double quoted strings.
+func splitUnescaped(s string, sep byte, quoted bool) []string {
+	parts := []string{}
+	start := 0
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quoted && s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var unescaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(", =\"\\", s[i+1]) >= 0 {
+			i++
+		}
+		unescaped.WriteByte(s[i])
+	}
+	return unescaped.String()
+}
+
+func parseLines(data []byte, now time.Time, parseLine func(string, time.Time) ([]models.MetricRequestBody, error)) (metrics []models.MetricRequestBody, errs []error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		parsed, err := parseLine(line, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		metrics = append(metrics, parsed...)
+	}
+	return metrics, errs
+}
@@ -0,0 +1,252 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSender struct {
+	lock    sync.Mutex
+	metrics []models.MetricRequestBody
+}
+
+func (r *recordingSender) CreateMetrics(tenantID *string, metricRequestBodies []models.MetricRequestBody) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics = append(r.metrics, metricRequestBodies...)
+	return nil
+}
+
+func (r *recordingSender) count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.metrics)
+}
+
+func byName(metrics []models.MetricRequestBody) map[string]models.MetricRequestBody {
+	named := map[string]models.MetricRequestBody{}
+	for _, metric := range metrics {
+		named[*metric.Name] = metric
+	}
+	return named
+}
+
+func TestInfluxParseLine(t *testing.T) {
+	parser := NewInfluxParser()
+	metrics, err := parser.ParseLine(`cpu\ load,host=web1,region=us\,west idle=92.5,busy=7i,up=t,note="a b,c=d" 1465839830100400200`, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(metrics) != 3 {
+		t.Fatalf("Expected 3 numeric fields but was %d", len(metrics))
+	}
+	named := byName(metrics)
+	idle, found := named["cpu load.idle"]
+	if !found || *idle.Value != 92.5 {
+		t.Errorf("Expected cpu load.idle 92.5 but was %v", named)
+	}
+	if *idle.Timestamp != 1465839830100 {
+		t.Errorf("Expected timestamp 1465839830100 but was %d", *idle.Timestamp)
+	}
+	if (*idle.Dimensions)["host"] != "web1" || (*idle.Dimensions)["region"] != "us_west" {
+		t.Errorf("Expected sanitized dimensions but was %v", *idle.Dimensions)
+	}
+	if *named["cpu load.busy"].Value != 7 || *named["cpu load.up"].Value != 1 {
+		t.Errorf("Expected integer and boolean fields to convert")
+	}
+
+	if err := parser.SetPrecision(0); err == nil {
+		t.Errorf("Expected error for zero precision")
+	}
+	if err := parser.SetPrecision(time.Second); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	metrics, err = parser.ParseLine("temp value=21 1465839830", time.Now())
+	if err != nil || len(metrics) != 1 || *metrics[0].Name != "temp" || *metrics[0].Timestamp != 1465839830000 {
+		t.Errorf("Expected temp at second precision but was %v, %v", metrics, err)
+	}
+
+	now := time.Unix(10, 0)
+	metrics, _ = parser.ParseLine("temp value=21", now)
+	if *metrics[0].Timestamp != 10000 {
+		t.Errorf("Expected missing timestamp to use now but was %d", *metrics[0].Timestamp)
+	}
+
+	for _, line := range []string{"temp", "temp value=x", ",host=a value=1", "temp,host value=1", "temp value=1 soon", "temp value=NaN", "temp value=+Inf", "temp value=-inf"} {
+		if _, err := parser.ParseLine(line, now); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestGraphiteTemplates(t *testing.T) {
+	parser, err := NewGraphiteParser([]string{
+		"servers.* .host.measurement* env=prod",
+		"stats.*.* .region.host.measurement.field",
+		"measurement*",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	metrics, err := parser.ParseLine("servers.web1.cpu.idle 92.5 1465839830", time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	metric := metrics[0]
+	if *metric.Name != "cpu.idle" || *metric.Value != 92.5 || *metric.Timestamp != 1465839830000 {
+		t.Errorf("Expected cpu.idle 92.5 but was %s %v %d", *metric.Name, *metric.Value, *metric.Timestamp)
+	}
+	if (*metric.Dimensions)["host"] != "web1" || (*metric.Dimensions)["env"] != "prod" {
+		t.Errorf("Expected host and env dimensions but was %v", *metric.Dimensions)
+	}
+
+	metrics, _ = parser.ParseLine("stats.east.db1.disk.used 10", time.Unix(5, 0))
+	metric = metrics[0]
+	if *metric.Name != "disk.used" || (*metric.Dimensions)["region"] != "east" || (*metric.Dimensions)["host"] != "db1" {
+		t.Errorf("Expected disk.used{region=east,host=db1} but was %s %v", *metric.Name, *metric.Dimensions)
+	}
+	if *metric.Timestamp != 5000 {
+		t.Errorf("Expected missing timestamp to use now but was %d", *metric.Timestamp)
+	}
+
+	metrics, _ = parser.ParseLine("other.metric;team=ops 1 -1", time.Now())
+	metric = metrics[0]
+	if *metric.Name != "other.metric" || (*metric.Dimensions)["team"] != "ops" {
+		t.Errorf("Expected fallback name with tag dimension but was %s %v", *metric.Name, metric.Dimensions)
+	}
+
+	for _, line := range []string{"path", "path x", "path 1 soon", "path;tag 1"} {
+		if _, err := parser.ParseLine(line, time.Now()); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+
+	for _, template := range []string{"a b c d", "x.measurement*.y", "[ .host"} {
+		if _, err := NewGraphiteParser([]string{template}); err == nil {
+			t.Errorf("Expected error for template %q", template)
+		}
+	}
+	if _, err := NewGraphiteParser([]string{"measurement*", ".host.measurement*"}); err == nil {
+		t.Errorf("Expected error for two fallback templates")
+	}
+}
+
+func TestListenerHTTP(t *testing.T) {
+	sender := &recordingSender{}
+	listener := NewListener(sender, NewInfluxParser())
+	listener.SetBatchSize(2)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/write", strings.NewReader("a value=1\nb value=2\nc x=1,y=2\n"))
+	listener.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNoContent || sender.count() != 4 {
+		t.Errorf("Expected 204 and 4 metrics but was %d and %d", recorder.Code, sender.count())
+	}
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest("POST", "/write", strings.NewReader("a value=1\nbroken\n"))
+	listener.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest || sender.count() != 4 {
+		t.Errorf("Expected 400 without publishing but was %d and %d", recorder.Code, sender.count())
+	}
+
+	recorder = httptest.NewRecorder()
+	listener.ServeHTTP(recorder, httptest.NewRequest("GET", "/write", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 but was %d", recorder.Code)
+	}
+}
+
+func TestListenerHTTPPrecisionAndGzip(t *testing.T) {
+	sender := &recordingSender{}
+	listener := NewListener(sender, NewInfluxParser())
+
+	var body bytes.Buffer
+	writer := gzip.NewWriter(&body)
+	writer.Write([]byte("a value=1 1465839830\n"))
+	writer.Close()
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/write?precision=s", &body)
+	request.Header.Set("Content-Encoding", "gzip")
+	listener.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNoContent || sender.count() != 1 {
+		t.Fatalf("Expected 204 and 1 metric but was %d and %d", recorder.Code, sender.count())
+	}
+	if *sender.metrics[0].Timestamp != 1465839830000 {
+		t.Errorf("Expected timestamp 1465839830000 but was %d", *sender.metrics[0].Timestamp)
+	}
+
+	recorder = httptest.NewRecorder()
+	listener.ServeHTTP(recorder, httptest.NewRequest("POST", "/write", strings.NewReader("a value=1 1465839830000000000\n")))
+	if recorder.Code != http.StatusNoContent || *sender.metrics[1].Timestamp != 1465839830000 {
+		t.Errorf("Expected the default precision to stay nanoseconds but was %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	listener.ServeHTTP(recorder, httptest.NewRequest("POST", "/write?precision=h", strings.NewReader("a value=1 1\n")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported precision but was %d", recorder.Code)
+	}
+
+	parser, _ := NewGraphiteParser(nil)
+	recorder = httptest.NewRecorder()
+	NewListener(sender, parser).ServeHTTP(recorder, httptest.NewRequest("POST", "/write?precision=s", strings.NewReader("a.b 1\n")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for precision with Graphite lines but was %d", recorder.Code)
+	}
+}
+
+func TestListenerTCP(t *testing.T) {
+	sender := &recordingSender{}
+	parser, _ := NewGraphiteParser(nil)
+	listener := NewListener(sender, parser)
+	listener.SetFlushInterval(10 * time.Millisecond)
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on TCP: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go listener.ServeTCP(ctx, tcpListener)
+	go listener.RunFlusher(ctx)
+
+	conn, err := net.Dial("tcp", tcpListener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	conn.Write([]byte("a.b 1 1465839830\na.c 2 1465839830\n"))
+	conn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && sender.count() < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sender.count() != 2 {
+		t.Errorf("Expected 2 metrics to be published but was %d", sender.count())
+	}
+}
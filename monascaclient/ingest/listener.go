@@ -0,0 +1,227 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"github.com/monasca/golang-monascaclient/monascaclient/relay"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultFlushInterval = time.Second
+	defaultMaxBodySize   = 32 << 20
+	maxLineSize          = 65535
+)
+
+// Parser is implemented by InfluxParser and GraphiteParser.
+type Parser interface {
+	ParseLine(line string, now time.Time) ([]models.MetricRequestBody, error)
+}
+
+// Listener feeds lines received over TCP, UDP or HTTP through a Parser and
+// publishes the metrics in batches. Network input is buffered and sent when a
+// batch is full or the flush interval elapses; HTTP requests are sent before
+// responding so that failures reach the client.
+type Listener struct {
+	parser        Parser
+	batcher       *relay.Batcher
+	server        *relay.Server
+	flushInterval time.Duration
+	maxBodySize   int64
+	errorHandler  func(error)
+}
+
+func NewListener(sender relay.MetricSender, parser Parser) *Listener {
+	l := &Listener{
+		parser:        parser,
+		batcher:       relay.NewBatcher(sender),
+		flushInterval: defaultFlushInterval,
+		maxBodySize:   defaultMaxBodySize,
+		errorHandler:  func(error) {},
+	}
+	l.server = relay.NewServer(l.handle)
+	l.server.SetErrorHandler(func(err error) {
+		l.errorHandler(err)
+	})
+	return l
+}
+
+// SetTenantID publishes the metrics on behalf of another tenant.
+func (l *Listener) SetTenantID(tenantID *string) {
+	l.batcher.SetTenantID(tenantID)
+}
+
+func (l *Listener) SetBatchSize(batchSize int) {
+	l.batcher.SetBatchSize(batchSize)
+}
+
+func (l *Listener) SetFlushInterval(flushInterval time.Duration) {
+	l.flushInterval = flushInterval
+}
+
+func (l *Listener) SetMaxBodySize(maxBodySize int64) {
+	l.maxBodySize = maxBodySize
+}
+
+// SetErrorHandler receives parse and publish errors of network input, which
+// are otherwise dropped.
+func (l *Listener) SetErrorHandler(errorHandler func(error)) {
+	l.errorHandler = errorHandler
+}
+
+// ListenAndServe listens on the given TCP and UDP addresses, either of which
+// may be empty, and serves until ctx is done. Buffered metrics are flushed on
+// shutdown.
+func (l *Listener) ListenAndServe(ctx context.Context, tcpAddress string, udpAddress string) error {
+	err := l.server.ListenAndServe(ctx, tcpAddress, udpAddress, l.RunFlusher)
+	if err != nil {
+		return err
+	}
+	return l.Flush()
+}
+
+// ServeTCP accepts newline delimited streams until ctx is done.
+func (l *Listener) ServeTCP(ctx context.Context, listener net.Listener) {
+	l.server.ServeTCP(ctx, listener)
+}
+
+// ServeUDP reads packets of one or more lines until ctx is done.
+func (l *Listener) ServeUDP(ctx context.Context, conn net.PacketConn) {
+	l.server.ServeUDP(ctx, conn)
+}
+
+// ServeHTTP accepts POSTed lines, as sent to the InfluxDB /write endpoint, and
+// responds 204 once they are published or 400 if any line fails to parse.
+// Bodies may be gzip encoded, and the precision parameter sets the unit of
+// Influx timestamps; other /write parameters are ignored. The maximum body
+// size applies after decompression.
+func (l *Listener) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parser, err := l.requestParser(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var reader io.Reader = req.Body
+	switch req.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gzipReader, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	default:
+		http.Error(w, "Unsupported content encoding", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(reader, l.maxBodySize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > l.maxBodySize {
+		http.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metrics := []models.MetricRequestBody{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 4096), maxLineSize)
+	for scanner.Scan() {
+		parsed, err := parser.ParseLine(scanner.Text(), time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		metrics = append(metrics, parsed...)
+	}
+	if err := scanner.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := l.batcher.Send(metrics); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestParser applies the precision parameter of an HTTP request to the
+// InfluxParser.
+func (l *Listener) requestParser(req *http.Request) (Parser, error) {
+	precision := req.URL.Query().Get("precision")
+	if precision == "" {
+		return l.parser, nil
+	}
+	influx, ok := l.parser.(*InfluxParser)
+	if !ok {
+		return nil, fmt.Errorf("Precision is only supported for Influx lines")
+	}
+	return influx.withPrecision(precision)
+}
+
+// handle parses a TCP line or a UDP packet of one or more lines.
+func (l *Listener) handle(data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 4096), maxLineSize)
+	for scanner.Scan() {
+		metrics, err := l.parser.ParseLine(scanner.Text(), time.Now())
+		if err != nil {
+			l.errorHandler(err)
+			continue
+		}
+		if err := l.batcher.Buffer(metrics); err != nil {
+			l.errorHandler(err)
+		}
+	}
+}
+
+// RunFlusher publishes buffered metrics every flush interval until ctx is done.
+func (l *Listener) RunFlusher(ctx context.Context) {
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Flush(); err != nil {
+				l.errorHandler(err)
+			}
+		}
+	}
+}
+
+// Flush publishes the buffered metrics.
+func (l *Listener) Flush() error {
+	return l.batcher.Flush()
+}
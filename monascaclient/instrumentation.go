@@ -0,0 +1,82 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Operation describes one API call, which may take several HTTP attempts.
+// Name is one of the Operation names, such as OperationGetStatistics.
+type Operation struct {
+	Name     string
+	Method   string
+	URL      *url.URL
+	TenantID string
+}
+
+// OperationResult describes how an operation ended. StatusCode is zero when
+// no response was received and ResultCount is -1 when the response has no
+// list of elements.
+type OperationResult struct {
+	StatusCode      int
+	Err             error
+	Latency         time.Duration
+	Attempts        int
+	Reauthenticated bool
+	ResultCount     int
+}
+
+// Instrumentation observes API operations, for example to trace them; see the
+// otelmonasca package for OpenTelemetry. StartOperation is called before the
+// first attempt and may add headers, such as trace context, that are sent with
+// every attempt. The returned function is called once the response has been
+// read.
+type Instrumentation interface {
+	StartOperation(ctx context.Context, operation Operation, header http.Header) (context.Context, func(OperationResult))
+}
+
+func SetInstrumentation(instrumentation Instrumentation) {
+	monClient.SetInstrumentation(instrumentation)
+}
+
+// SetInstrumentation enables instrumentation, nil disables it.
+func (c *Client) SetInstrumentation(instrumentation Instrumentation) {
+	c.instrumentation = instrumentation
+}
+
+func newOperation(method string, requestURL *url.URL) Operation {
+	return Operation{
+		Name:     operationName(method, requestURL),
+		Method:   method,
+		URL:      requestURL,
+		TenantID: requestURL.Query().Get("tenant_id"),
+	}
+}
+
+// resultCount returns the number of elements of a list response, or -1.
+func resultCount(body []byte) int {
+	var list struct {
+		Elements *[]json.RawMessage `json:"elements"`
+	}
+	if json.Unmarshal(body, &list) != nil || list.Elements == nil {
+		return -1
+	}
+	return len(*list.Elements)
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type recordingInstrumentation struct {
+	operations []Operation
+	results    []OperationResult
+}
+
+func (r *recordingInstrumentation) StartOperation(ctx context.Context, operation Operation, header http.Header) (context.Context, func(OperationResult)) {
+	r.operations = append(r.operations, operation)
+	header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	return ctx, func(result OperationResult) {
+		r.results = append(r.results, result)
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	cases := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{"GET", "/v2.0/metrics", "GetMetrics"},
+		{"POST", "/v2.0/metrics", "CreateMetrics"},
+		{"GET", "/v2.0/metrics/statistics", "GetStatistics"},
+		{"GET", "/v2.0/metrics/dimensions/names/values", "GetDimensionValues"},
+		{"GET", "/v2.0/alarms", "GetAlarms"},
+		{"GET", "/v2.0/alarms/a1", "GetAlarm"},
+		{"PATCH", "/v2.0/alarm-definitions/d1", "PatchAlarmDefinition"},
+		{"POST", "/v2.0/notification-methods", "CreateNotificationMethod"},
+		{"DELETE", "/v2.0/notification-methods/n1", "DeleteNotificationMethod"},
+		{"GET", "/v2.0/alarms/state-history", "GET v2.0/alarms/state-history"},
+		{"GET", "/v2.0/alarms/a1/state-history", "GET v2.0/alarms/{id}/state-history"},
+		{"POST", "/v2.0/alarms", "POST v2.0/alarms"},
+	}
+	for _, c := range cases {
+		name := operationName(c.method, &url.URL{Path: c.path})
+		if name != c.expected {
+			t.Errorf("Expected %s %s to be %s but was %s", c.method, c.path, c.expected, name)
+		}
+	}
+}
+
+func TestInstrumentation(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Write([]byte(`{"links": [], "elements": [{"name": "cpu"}, {"name": "mem"}]}`))
+	}))
+	defer server.Close()
+
+	recorder := &recordingInstrumentation{}
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetInstrumentation(recorder)
+	tenantID := "tenant"
+	_, err := client.GetMetrics(nil)
+	if err != nil {
+		t.Fatalf("Error %s getting metrics", err)
+	}
+	_, err = client.GetMetricNames(&models.MetricNameQuery{TenantID: &tenantID})
+	if err != nil {
+		t.Fatalf("Error %s getting metric names", err)
+	}
+
+	if traceparent == "" {
+		t.Errorf("Expected injected headers to be sent")
+	}
+	if len(recorder.operations) != 2 || recorder.operations[0].Name != "GetMetrics" {
+		t.Fatalf("Expected two operations but was %+v", recorder.operations)
+	}
+	if recorder.operations[1].TenantID != tenantID {
+		t.Errorf("Expected tenant %s but was '%s'", tenantID, recorder.operations[1].TenantID)
+	}
+	result := recorder.results[0]
+	if result.StatusCode != 200 || result.Attempts != 1 || result.ResultCount != 2 || result.Err != nil {
+		t.Errorf("Expected a single successful attempt with 2 results but was %+v", result)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package inventory walks the metrics of a tenant and reports how many series
+// each metric has, the cardinality of its dimensions and which metrics have
+// stopped receiving measurements, to find the dimensions that blow up the
+// number of series stored by Monasca.
+package inventory
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"sort"
+	"time"
+)
+
+const (
+	defaultStaleAfter = 24 * time.Hour
+	defaultTopN       = 10
+)
+
+// Source lists metrics and dimensions; a *monascaclient.Client satisfies it.
+type Source interface {
+	ForEachMetricNamePage(metricQuery *models.MetricNameQuery, handle func(*models.MetricNameResponse) error) error
+	ForEachDimensionNamePage(dimensionQuery *models.DimensionNameQuery, handle func(*models.DimensionValueResponse) error) error
+	ForEachDimensionValuePage(dimensionQuery *models.DimensionValueQuery, handle func(*models.DimensionValueResponse) error) error
+	ForEachMetricPage(metricQuery *models.MetricQuery, handle func(*models.MetricsResponse) error) error
+}
+
+// Report is the inventory of a tenant. Metrics are ordered by series count,
+// Dimensions and Offenders by cardinality, highest first.
+type Report struct {
+	TenantID     string
+	GeneratedAt  time.Time
+	StaleAfter   time.Duration
+	TotalSeries  int
+	ActiveSeries int
+	Metrics      []MetricReport
+	Dimensions   []DimensionReport
+	Offenders    []Offender
+	StaleMetrics []string
+}
+
+// MetricReport describes the series of one metric name. ActiveSeries have
+// received measurements within the report's StaleAfter. Dimensions maps the
+// dimension names of the metric to their number of values.
+type MetricReport struct {
+	Name         string
+	Series       int
+	ActiveSeries int
+	Dimensions   map[string]int
+}
+
+// Stale reports whether none of the metric's series were updated recently.
+func (m MetricReport) Stale() bool {
+	return m.Series > 0 && m.ActiveSeries == 0
+}
+
+// DimensionReport gives the number of values of a dimension across all
+// metrics of the tenant.
+type DimensionReport struct {
+	Name        string
+	Cardinality int
+}
+
+// Offender is a dimension of a metric with many values. Share is its
+// cardinality relative to the series of the metric: near 1 the dimension is
+// unique per series, as identifiers and timestamps are.
+type Offender struct {
+	Metric      string
+	Dimension   string
+	Cardinality int
+	Share       float64
+}
+
+// Analyzer builds reports from a source.
+type Analyzer struct {
+	source     Source
+	tenantID   *string
+	staleAfter time.Duration
+	topN       int
+	now        func() time.Time
+}
+
+func New(source Source) *Analyzer {
+	return &Analyzer{
+		source:     source,
+		staleAfter: defaultStaleAfter,
+		topN:       defaultTopN,
+		now:        time.Now,
+	}
+}
+
+// SetTenantID analyzes another tenant, which requires the delegate role.
+func (a *Analyzer) SetTenantID(tenantID *string) {
+	a.tenantID = tenantID
+}
+
+// SetStaleAfter sets how long a series may go without measurements before it
+// is counted as stale.
+func (a *Analyzer) SetStaleAfter(staleAfter time.Duration) {
+	a.staleAfter = staleAfter
+}
+
+// SetTopN sets the number of offenders reported; zero or less reports none.
+func (a *Analyzer) SetTopN(topN int) {
+	if topN < 0 {
+		topN = 0
+	}
+	a.topN = topN
+}
+
+// Analyze walks every metric name, its series and dimensions. It makes two
+// paged metric listings per name, one of them limited to recent series, and
+// one dimension value listing per dimension of each metric.
+func (a *Analyzer) Analyze() (*Report, error) {
+	now := a.now()
+	report := &Report{GeneratedAt: now, StaleAfter: a.staleAfter}
+	if a.tenantID != nil {
+		report.TenantID = *a.tenantID
+	}
+
+	names := []string{}
+	err := a.source.ForEachMetricNamePage(&models.MetricNameQuery{TenantID: a.tenantID}, func(response *models.MetricNameResponse) error {
+		for _, element := range response.Elements {
+			names = append(names, element["name"])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list metric names: %v", err)
+	}
+	sort.Strings(names)
+	since := now.Add(-a.staleAfter)
+	for _, name := range names {
+		metric, err := a.analyzeMetric(name, since)
+		if err != nil {
+			return nil, err
+		}
+		report.Metrics = append(report.Metrics, *metric)
+		report.TotalSeries += metric.Series
+		report.ActiveSeries += metric.ActiveSeries
+		if metric.Stale() {
+			report.StaleMetrics = append(report.StaleMetrics, name)
+		}
+		for dimension, cardinality := range metric.Dimensions {
+			offender := Offender{Metric: name, Dimension: dimension, Cardinality: cardinality}
+			if metric.Series > 0 {
+				offender.Share = float64(cardinality) / float64(metric.Series)
+			}
+			report.Offenders = append(report.Offenders, offender)
+		}
+	}
+	sort.SliceStable(report.Metrics, func(i, j int) bool { return report.Metrics[i].Series > report.Metrics[j].Series })
+	sort.SliceStable(report.Offenders, func(i, j int) bool {
+		if report.Offenders[i].Cardinality != report.Offenders[j].Cardinality {
+			return report.Offenders[i].Cardinality > report.Offenders[j].Cardinality
+		}
+		if report.Offenders[i].Metric != report.Offenders[j].Metric {
+			return report.Offenders[i].Metric < report.Offenders[j].Metric
+		}
+		return report.Offenders[i].Dimension < report.Offenders[j].Dimension
+	})
+	if len(report.Offenders) > a.topN {
+		report.Offenders = report.Offenders[:a.topN]
+	}
+
+	report.Dimensions, err = a.analyzeDimensions()
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (a *Analyzer) analyzeMetric(name string, since time.Time) (*MetricReport, error) {
+	metric := &MetricReport{Name: name, Dimensions: map[string]int{}}
+	metricName := name
+	err := a.source.ForEachMetricPage(&models.MetricQuery{TenantID: a.tenantID, Name: &metricName}, func(response *models.MetricsResponse) error {
+		for _, element := range response.Elements {
+			if element.Name == name {
+				metric.Series++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list series of %s: %v", name, err)
+	}
+	err = a.source.ForEachMetricPage(&models.MetricQuery{TenantID: a.tenantID, Name: &metricName, StartTime: &since}, func(response *models.MetricsResponse) error {
+		for _, element := range response.Elements {
+			if element.Name == name {
+				metric.ActiveSeries++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list recent series of %s: %v", name, err)
+	}
+
+	dimensionNames, err := a.dimensionNames(&models.DimensionNameQuery{TenantID: a.tenantID, Name: &metricName})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list dimensions of %s: %v", name, err)
+	}
+	for _, dimensionName := range dimensionNames {
+		query := &models.DimensionValueQuery{DimensionName: &dimensionName}
+		query.TenantID = a.tenantID
+		query.Name = &metricName
+		values, err := a.countDimensionValues(query)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list values of %s of %s: %v", dimensionName, name, err)
+		}
+		metric.Dimensions[dimensionName] = values
+	}
+	return metric, nil
+}
+
+func (a *Analyzer) analyzeDimensions() ([]DimensionReport, error) {
+	dimensionNames, err := a.dimensionNames(&models.DimensionNameQuery{TenantID: a.tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list dimension names: %v", err)
+	}
+	dimensions := make([]DimensionReport, 0, len(dimensionNames))
+	for _, dimensionName := range dimensionNames {
+		name := dimensionName
+		query := &models.DimensionValueQuery{DimensionName: &name}
+		query.TenantID = a.tenantID
+		values, err := a.countDimensionValues(query)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list values of %s: %v", dimensionName, err)
+		}
+		dimensions = append(dimensions, DimensionReport{Name: dimensionName, Cardinality: values})
+	}
+	sort.SliceStable(dimensions, func(i, j int) bool {
+		if dimensions[i].Cardinality != dimensions[j].Cardinality {
+			return dimensions[i].Cardinality > dimensions[j].Cardinality
+		}
+		return dimensions[i].Name < dimensions[j].Name
+	})
+	return dimensions, nil
+}
+
+func (a *Analyzer) dimensionNames(query *models.DimensionNameQuery) ([]string, error) {
+	names := []string{}
+	err := a.source.ForEachDimensionNamePage(query, func(response *models.DimensionValueResponse) error {
+		for _, element := range response.Elements {
+			names = append(names, element.Value)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// countDimensionValues counts distinct values, since a value may repeat when
+// pages shift between requests.
+func (a *Analyzer) countDimensionValues(query *models.DimensionValueQuery) (int, error) {
+	values := map[string]bool{}
+	err := a.source.ForEachDimensionValuePage(query, func(response *models.DimensionValueResponse) error {
+		for _, element := range response.Elements {
+			values[element.Value] = true
+		}
+		return nil
+	})
+	return len(values), err
+}
@@ -0,0 +1,157 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package inventory
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	metrics []models.Metric
+	active  map[string]bool
+}
+
+// ForEachMetricNamePage returns one name per page to exercise paging.
+func (f *fakeSource) ForEachMetricNamePage(metricQuery *models.MetricNameQuery, handle func(*models.MetricNameResponse) error) error {
+	seen := map[string]bool{}
+	for _, metric := range f.metrics {
+		if seen[metric.Name] {
+			continue
+		}
+		seen[metric.Name] = true
+		if err := handle(&models.MetricNameResponse{Elements: []map[string]string{{"name": metric.Name}}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachDimensionNamePage returns one name per page to exercise paging.
+func (f *fakeSource) ForEachDimensionNamePage(dimensionQuery *models.DimensionNameQuery, handle func(*models.DimensionValueResponse) error) error {
+	seen := map[string]bool{}
+	for _, metric := range f.metrics {
+		if dimensionQuery.Name != nil && metric.Name != *dimensionQuery.Name {
+			continue
+		}
+		for name := range metric.Dimensions {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if err := handle(&models.DimensionValueResponse{Elements: []models.DimensionValue{{Value: name}}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ForEachDimensionValuePage returns one value per page, repeating values
+// across pages the way shifting pages can.
+func (f *fakeSource) ForEachDimensionValuePage(dimensionQuery *models.DimensionValueQuery, handle func(*models.DimensionValueResponse) error) error {
+	for _, metric := range f.metrics {
+		if dimensionQuery.Name != nil && metric.Name != *dimensionQuery.Name {
+			continue
+		}
+		value, found := metric.Dimensions[*dimensionQuery.DimensionName]
+		if !found {
+			continue
+		}
+		if err := handle(&models.DimensionValueResponse{Elements: []models.DimensionValue{{Value: value}}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachMetricPage returns one metric per page to exercise paging.
+func (f *fakeSource) ForEachMetricPage(metricQuery *models.MetricQuery, handle func(*models.MetricsResponse) error) error {
+	for _, metric := range f.metrics {
+		if metric.Name != *metricQuery.Name || (metricQuery.StartTime != nil && !f.active[metric.Dimensions["hostname"]]) {
+			continue
+		}
+		if err := handle(&models.MetricsResponse{Elements: []models.Metric{metric}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestAnalyze(t *testing.T) {
+	source := &fakeSource{active: map[string]bool{"a": true}}
+	for i := 0; i < 5; i++ {
+		source.metrics = append(source.metrics, models.Metric{
+			Name:       "http.requests",
+			Dimensions: map[string]string{"hostname": "a", "request_id": fmt.Sprintf("r%d", i)},
+		})
+	}
+	source.metrics = append(source.metrics,
+		models.Metric{Name: "cpu", Dimensions: map[string]string{"hostname": "a"}},
+		models.Metric{Name: "cpu", Dimensions: map[string]string{"hostname": "b"}},
+		models.Metric{Name: "disk", Dimensions: map[string]string{"hostname": "b"}},
+	)
+
+	analyzer := New(source)
+	analyzer.SetTopN(2)
+	analyzer.now = func() time.Time { return time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC) }
+	report, err := analyzer.Analyze()
+	if err != nil {
+		t.Fatalf("Error %s analyzing", err)
+	}
+
+	if report.TotalSeries != 8 || report.ActiveSeries != 6 {
+		t.Errorf("Expected 8 series, 6 active but was %d, %d", report.TotalSeries, report.ActiveSeries)
+	}
+	if report.Metrics[0].Name != "http.requests" || report.Metrics[0].Series != 5 || report.Metrics[0].Dimensions["request_id"] != 5 {
+		t.Errorf("Expected http.requests to have the most series but was %+v", report.Metrics[0])
+	}
+	if len(report.Offenders) != 2 || report.Offenders[0].Dimension != "request_id" || report.Offenders[0].Share != 1 {
+		t.Errorf("Expected request_id to be the top offender but was %+v", report.Offenders)
+	}
+	if len(report.StaleMetrics) != 1 || report.StaleMetrics[0] != "disk" {
+		t.Errorf("Expected disk to be stale but was %v", report.StaleMetrics)
+	}
+	if report.Dimensions[0].Name != "request_id" || report.Dimensions[1].Cardinality != 2 {
+		t.Errorf("Expected dimensions by cardinality but was %+v", report.Dimensions)
+	}
+
+	var text bytes.Buffer
+	if err = report.WriteText(&text); err != nil {
+		t.Fatalf("Error %s writing report", err)
+	}
+	if !strings.Contains(text.String(), "http.requests  5") {
+		t.Errorf("Expected metric table in report but was\n%s", text.String())
+	}
+}
+
+func TestAnalyzeNegativeTopN(t *testing.T) {
+	source := &fakeSource{metrics: []models.Metric{
+		{Name: "cpu", Dimensions: map[string]string{"hostname": "a"}},
+	}}
+	analyzer := New(source)
+	analyzer.SetTopN(-1)
+	report, err := analyzer.Analyze()
+	if err != nil {
+		t.Fatalf("Error %s analyzing", err)
+	}
+	if len(report.Offenders) != 0 {
+		t.Errorf("Expected no offenders but was %+v", report.Offenders)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package inventory
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteText writes the report as aligned plain text tables.
+func (r *Report) WriteText(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	tenant := r.TenantID
+	if tenant == "" {
+		tenant = "(own)"
+	}
+	fmt.Fprintf(table, "Tenant %s at %s: %d series, %d updated within %s\n\n",
+		tenant, r.GeneratedAt.UTC().Format("2006-01-02T15:04:05Z"), r.TotalSeries, r.ActiveSeries, r.StaleAfter)
+
+	fmt.Fprintln(table, "METRIC\tSERIES\tACTIVE\tDIMENSIONS")
+	for _, metric := range r.Metrics {
+		dimensions := make([]string, 0, len(metric.Dimensions))
+		for name, cardinality := range metric.Dimensions {
+			dimensions = append(dimensions, fmt.Sprintf("%s=%d", name, cardinality))
+		}
+		sort.Strings(dimensions)
+		fmt.Fprintf(table, "%s\t%d\t%d\t%s\n", metric.Name, metric.Series, metric.ActiveSeries, strings.Join(dimensions, " "))
+	}
+
+	fmt.Fprintln(table, "\nDIMENSION\tVALUES")
+	for _, dimension := range r.Dimensions {
+		fmt.Fprintf(table, "%s\t%d\n", dimension.Name, dimension.Cardinality)
+	}
+
+	fmt.Fprintln(table, "\nOFFENDER\tDIMENSION\tVALUES\tPER SERIES")
+	for _, offender := range r.Offenders {
+		fmt.Fprintf(table, "%s\t%s\t%d\t%.2f\n", offender.Metric, offender.Dimension, offender.Cardinality, offender.Share)
+	}
+
+	fmt.Fprintf(table, "\nSTALE METRICS\n")
+	for _, name := range r.StaleMetrics {
+		fmt.Fprintln(table, name)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,273 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Operation classes for SetOperationLimits. Limits may also be set for a
+// single operation by its name, such as OperationCreateMetrics or
+// OperationGetMeasurements.
+const (
+	ReadOperations  = "read"
+	WriteOperations = "write"
+)
+
+// LimitPolicy decides what happens to a call that is over its limits.
+type LimitPolicy int
+
+const (
+	// Block waits for capacity until the call's context is done. A call whose
+	// context deadline would pass before a rate limit token is available fails
+	// immediately.
+	Block LimitPolicy = iota
+	// FailFast fails the call with a *LimitError.
+	FailFast
+)
+
+// Limits caps the calls a client makes. Rate is in calls per second with
+// bursts of up to Burst calls, at least 1; MaxInFlight caps concurrent calls.
+// Zero values disable the respective limit.
+type Limits struct {
+	Rate        float64
+	Burst       int
+	MaxInFlight int
+	Policy      LimitPolicy
+}
+
+// LimitError is returned when a call is rejected by the client's own limits
+// without being sent. Scope is "global", an operation class or an operation
+// name.
+type LimitError struct {
+	Scope string
+	Rate  bool
+}
+
+func (e *LimitError) Error() string {
+	if e.Rate {
+		return fmt.Sprintf("Client rate limit exceeded for %s operations", e.Scope)
+	}
+	return fmt.Sprintf("Client in-flight limit exceeded for %s operations", e.Scope)
+}
+
+func SetLimits(limits Limits) {
+	monClient.SetLimits(limits)
+}
+
+func SetOperationLimits(class string, limits Limits) {
+	monClient.SetOperationLimits(class, limits)
+}
+
+// SetLimits limits all calls made by the client and its WithContext views.
+func (c *Client) SetLimits(limits Limits) {
+	c.limitSet().set("global", limits)
+}
+
+// SetOperationLimits limits an operation class, ReadOperations or
+// WriteOperations, or a single operation by one of the Operation names, such
+// as OperationCreateMetrics for both CreateMetric and CreateMetrics. Calls
+// must be within the global, class and operation limits that apply to them.
+func (c *Client) SetOperationLimits(class string, limits Limits) {
+	c.limitSet().set(class, limits)
+}
+
+func (c *Client) limitSet() *limiterSet {
+	if c.limiters == nil {
+		c.limiters = &limiterSet{}
+	}
+	return c.limiters
+}
+
+type limiterSet struct {
+	mutex    sync.Mutex
+	limiters map[string]*limiter
+}
+
+func (s *limiterSet) set(scope string, limits Limits) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.limiters == nil {
+		s.limiters = map[string]*limiter{}
+	}
+	if limits.Rate <= 0 && limits.MaxInFlight <= 0 {
+		delete(s.limiters, scope)
+		return
+	}
+	s.limiters[scope] = newLimiter(scope, limits)
+}
+
+// acquire waits for every limit applying to the operation and returns a
+// function releasing the in-flight slots taken. If a limit rejects the
+// operation, the slots and rate tokens taken from earlier limits are given
+// back.
+func (s *limiterSet) acquire(ctx context.Context, operation Operation) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	class := WriteOperations
+	if operation.Method == "GET" {
+		class = ReadOperations
+	}
+	s.mutex.Lock()
+	var applicable []*limiter
+	for _, scope := range []string{"global", class, operation.Name} {
+		if l, found := s.limiters[scope]; found {
+			applicable = append(applicable, l)
+		}
+	}
+	s.mutex.Unlock()
+
+	var releases []func()
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for i, l := range applicable {
+		r, err := l.acquire(ctx)
+		if err != nil {
+			release()
+			for _, taken := range applicable[:i] {
+				taken.refund()
+			}
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+	return release, nil
+}
+
+type limiter struct {
+	scope    string
+	policy   LimitPolicy
+	bucket   *tokenBucket
+	inFlight chan struct{}
+}
+
+func newLimiter(scope string, limits Limits) *limiter {
+	l := &limiter{scope: scope, policy: limits.Policy}
+	if limits.Rate > 0 {
+		burst := limits.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		l.bucket = &tokenBucket{rate: limits.Rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+	}
+	if limits.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, limits.MaxInFlight)
+	}
+	return l
+}
+
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l.bucket != nil {
+		if err := l.bucket.take(ctx, l.policy); err != nil {
+			if err == errNoToken {
+				return nil, &LimitError{Scope: l.scope, Rate: true}
+			}
+			return nil, err
+		}
+	}
+	if l.inFlight == nil {
+		return func() {}, nil
+	}
+
+	if l.policy == FailFast {
+		select {
+		case l.inFlight <- struct{}{}:
+		default:
+			l.refund()
+			return nil, &LimitError{Scope: l.scope}
+		}
+	} else {
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			l.refund()
+			return nil, ctx.Err()
+		}
+	}
+	return func() { <-l.inFlight }, nil
+}
+
+// refund returns the rate token taken by acquire for an operation that was
+// not sent.
+func (l *limiter) refund() {
+	if l.bucket != nil {
+		l.bucket.give()
+	}
+}
+
+var errNoToken = fmt.Errorf("No token available")
+
+// tokenBucket hands out tokens at rate per second, storing up to burst. A
+// blocking taker reserves a token ahead of time, driving the balance
+// negative, and sleeps until it would have been available; it is returned
+// if the caller gives up.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(ctx context.Context, policy LimitPolicy) error {
+	b.mutex.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.mutex.Unlock()
+		return nil
+	}
+
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	deadline, hasDeadline := ctx.Deadline()
+	if policy == FailFast || (hasDeadline && deadline.Before(now.Add(wait))) {
+		b.mutex.Unlock()
+		return errNoToken
+	}
+	b.tokens--
+	b.mutex.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.give()
+		return ctx.Err()
+	}
+}
+
+// give returns a token, keeping the balance within burst.
+func (b *tokenBucket) give() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"context"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitFailFast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"links": [], "elements": []}`))
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetOperationLimits(OperationGetMetrics, Limits{Rate: 0.1, Burst: 2, Policy: FailFast})
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetMetrics(nil); err != nil {
+			t.Fatalf("Error %s within burst", err)
+		}
+	}
+	_, err := client.GetMetrics(nil)
+	if limitErr, ok := err.(*LimitError); !ok || !limitErr.Rate || limitErr.Scope != OperationGetMetrics {
+		t.Errorf("Expected rate limit error but was %v", err)
+	}
+	if _, err = client.GetMetricNames(nil); err != nil {
+		t.Errorf("Expected other operations to be unlimited but was %v", err)
+	}
+}
+
+func TestRateLimitRefundsEarlierScopes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"links": [], "elements": []}`))
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetLimits(Limits{Rate: 0.1, Burst: 2, Policy: FailFast})
+	client.SetOperationLimits(OperationGetMetrics, Limits{Rate: 0.1, Burst: 1, Policy: FailFast})
+	if _, err := client.GetMetrics(nil); err != nil {
+		t.Fatalf("Error %s within burst", err)
+	}
+	_, err := client.GetMetrics(nil)
+	if limitErr, ok := err.(*LimitError); !ok || limitErr.Scope != OperationGetMetrics {
+		t.Errorf("Expected GetMetrics rate limit error but was %v", err)
+	}
+	if _, err = client.GetMetricNames(nil); err != nil {
+		t.Errorf("Expected the global token to be returned but was %v", err)
+	}
+}
+
+func TestRateLimitDeadline(t *testing.T) {
+	bucket := &tokenBucket{rate: 1, burst: 1, last: time.Now()}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := bucket.take(ctx, Block); err != errNoToken {
+		t.Errorf("Expected no token before the deadline but was %v", err)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Errorf("Expected to fail without waiting but took %v", time.Since(start))
+	}
+
+	bucket = &tokenBucket{rate: 50, burst: 1, last: time.Now()}
+	if err := bucket.take(context.Background(), Block); err != nil {
+		t.Errorf("Error %s waiting for token", err)
+	}
+	if time.Since(start) < 15*time.Millisecond {
+		t.Errorf("Expected to wait for a token")
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			entered <- struct{}{}
+			<-unblock
+			w.WriteHeader(204)
+			return
+		}
+		w.Write([]byte(`{"links": [], "elements": []}`))
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetOperationLimits(WriteOperations, Limits{MaxInFlight: 1})
+	done := make(chan error)
+	go func() {
+		done <- client.CreateMetric(nil, &models.MetricRequestBody{})
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := client.WithContext(ctx).CreateMetric(nil, &models.MetricRequestBody{}); err != context.DeadlineExceeded {
+		t.Errorf("Expected second write to time out waiting but was %v", err)
+	}
+	if _, err := client.GetMetrics(nil); err != nil {
+		t.Errorf("Expected reads to be unaffected but was %v", err)
+	}
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Errorf("Error %s creating metric", err)
+	}
+}
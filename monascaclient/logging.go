@@ -0,0 +1,274 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	redacted                 = "REDACTED"
+	defaultMaxLoggedBody     = 4096
+	maxPeekedResponse        = 1 << 20
+	curlAuthTokenVariable    = "$OS_AUTH_TOKEN"
+	authTokenHeader          = "X-Auth-Token"
+	subjectTokenHeader       = "X-Subject-Token"
+	authorizationHeader      = "Authorization"
+	proxyAuthorizationHeader = "Proxy-Authorization"
+)
+
+// Logger receives one record per HTTP request. Its methods match those of
+// *slog.Logger, which can be passed directly: args are alternating keys and
+// values.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// LogOptions selects what is logged besides method, URL, status and latency.
+// Credentials are always redacted: auth token headers, passwords, tokens and
+// secrets in bodies and query strings, and notification method addresses.
+// Curl adds a command reproducing the request, with the token read from
+// $OS_AUTH_TOKEN.
+type LogOptions struct {
+	Headers     bool
+	Bodies      bool
+	Curl        bool
+	MaxBodySize int
+}
+
+var sensitiveHeaders = map[string]bool{
+	authTokenHeader:          true,
+	subjectTokenHeader:       true,
+	authorizationHeader:      true,
+	proxyAuthorizationHeader: true,
+	"Cookie":                 true,
+	"Set-Cookie":             true,
+}
+
+// Members and query parameters containing one of these words are redacted,
+// as are notification method addresses.
+var sensitiveWords = []string{"password", "token", "secret"}
+
+func SetLogger(logger Logger) {
+	monClient.SetLogger(logger)
+}
+
+func SetLogOptions(options LogOptions) {
+	monClient.SetLogOptions(options)
+}
+
+// SetLogger enables request logging, nil disables it. Successful requests are
+// logged at debug level, transport errors and error statuses at error level.
+func (c *Client) SetLogger(logger Logger) {
+	c.logger = logger
+}
+
+func (c *Client) SetLogOptions(options LogOptions) {
+	c.logOptions = options
+}
+
+// logRequest records an attempt. The response body is only passed when bodies
+// are logged.
+func (c *Client) logRequest(req *http.Request, requestBody *[]byte, resp *http.Response, responseBody []byte, latency time.Duration, err error) {
+	args := []interface{}{
+		"method", req.Method,
+		"url", redactURL(req.URL),
+		"latency", latency,
+	}
+	if resp != nil {
+		args = append(args, "status", resp.StatusCode)
+	}
+	if c.logOptions.Headers {
+		args = append(args, "request_headers", redactHeaders(req.Header))
+		if resp != nil {
+			args = append(args, "response_headers", redactHeaders(resp.Header))
+		}
+	}
+	if c.logOptions.Bodies {
+		if requestBody != nil {
+			args = append(args, "request_body", c.truncateBody(redactBody(*requestBody)))
+		}
+		if resp != nil && int64(len(responseBody)) > c.peekLimit() {
+			args = append(args, "response_body", fmt.Sprintf("(more than %d bytes)", c.peekLimit()))
+		} else if resp != nil {
+			args = append(args, "response_body", c.truncateBody(redactBody(responseBody)))
+		}
+	}
+	if c.logOptions.Curl {
+		args = append(args, "curl", curlCommand(req, requestBody))
+	}
+
+	if err != nil {
+		c.logger.Error("Monasca request failed", append(args, "error", err)...)
+	} else if resp.StatusCode >= 400 {
+		c.logger.Error("Monasca request returned an error", args...)
+	} else {
+		c.logger.Debug("Monasca request", args...)
+	}
+}
+
+// peekResponse reads the start of a response body for logging and puts it back
+// in front of the rest, so the size limit and streaming still see the whole
+// body. At most peekLimit bytes plus one are read, the extra byte telling that
+// the body was cut.
+func (c *Client) peekResponse(resp *http.Response) ([]byte, error) {
+	peeked, err := ioutil.ReadAll(io.LimitReader(resp.Body, c.peekLimit()+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), resp.Body), resp.Body}
+	return peeked, err
+}
+
+func (c *Client) peekLimit() int64 {
+	if c.maxResponseSize > 0 && c.maxResponseSize < maxPeekedResponse {
+		return c.maxResponseSize
+	}
+	return maxPeekedResponse
+}
+
+func (c *Client) truncateBody(body string) string {
+	maxSize := c.logOptions.MaxBodySize
+	if maxSize <= 0 {
+		maxSize = defaultMaxLoggedBody
+	}
+	if len(body) > maxSize {
+		return body[:maxSize] + fmt.Sprintf("... (%d bytes)", len(body))
+	}
+	return body
+}
+
+func redactURL(requestURL *url.URL) string {
+	redactedURL := *requestURL
+	redactedURL.User = nil
+	query := redactedURL.Query()
+	changed := false
+	for key := range query {
+		if isSensitiveField(key) {
+			query.Set(key, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		redactedURL.RawQuery = query.Encode()
+	}
+	return redactedURL.String()
+}
+
+func redactHeaders(headers http.Header) map[string]string {
+	redactedHeaders := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			redactedHeaders[name] = redacted
+		} else {
+			redactedHeaders[name] = strings.Join(values, ", ")
+		}
+	}
+	return redactedHeaders
+}
+
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	if name == "address" {
+		return true
+	}
+	for _, word := range sensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactBody replaces the values of sensitive JSON members. Bodies that are not
+// JSON are only logged by size.
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return fmt.Sprintf("(%d bytes, not JSON)", len(body))
+	}
+	encoded, err := json.Marshal(redactValue(decoded))
+	if err != nil {
+		return fmt.Sprintf("(%d bytes)", len(body))
+	}
+	return string(encoded)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, member := range typed {
+			if isSensitiveField(key) {
+				typed[key] = redacted
+			} else {
+				typed[key] = redactValue(member)
+			}
+		}
+	case []interface{}:
+		for i, element := range typed {
+			typed[i] = redactValue(element)
+		}
+	}
+	return value
+}
+
+// curlCommand returns a shell command repeating the request. Credentials stay
+// redacted, the auth token is taken from the environment. requestBody is the
+// uncompressed body, so a gzip Content-Encoding is left out.
+func curlCommand(req *http.Request, requestBody *[]byte) string {
+	command := &bytes.Buffer{}
+	command.WriteString("curl -X " + req.Method + " " + shellQuote(redactURL(req.URL)))
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		canonical := http.CanonicalHeaderKey(name)
+		switch {
+		case canonical == authTokenHeader:
+			command.WriteString(` -H "` + authTokenHeader + `: ` + curlAuthTokenVariable + `"`)
+		case canonical == "Content-Encoding":
+		case sensitiveHeaders[canonical]:
+			command.WriteString(" -H " + shellQuote(name+": "+redacted))
+		default:
+			for _, value := range req.Header[name] {
+				command.WriteString(" -H " + shellQuote(name+": "+value))
+			}
+		}
+	}
+	if requestBody != nil && len(*requestBody) > 0 {
+		command.WriteString(" --data-binary " + shellQuote(redactBody(*requestBody)))
+	}
+	return command.String()
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var _ Logger = slog.Default()
+
+type logRecord struct {
+	level   string
+	message string
+	fields  map[string]string
+}
+
+type recordingLogger struct {
+	records []logRecord
+}
+
+func (l *recordingLogger) record(level string, msg string, args []interface{}) {
+	fields := map[string]string{}
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[fmt.Sprint(args[i])] = fmt.Sprint(args[i+1])
+	}
+	l.records = append(l.records, logRecord{level: level, message: msg, fields: fields})
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {
+	l.record("debug", msg, args)
+}
+
+func (l *recordingLogger) Error(msg string, args ...interface{}) {
+	l.record("error", msg, args)
+}
+
+func TestRequestLoggingRedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": "n1", "name": "ops", "type": "WEBHOOK", "address": "https://hooks.example.com/secret"}`))
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetHeaders(http.Header{"X-Auth-Token": []string{"abcdef"}})
+	client.SetLogger(logger)
+	client.SetLogOptions(LogOptions{Headers: true, Bodies: true, Curl: true})
+
+	name, notificationType, address := "ops", "WEBHOOK", "https://hooks.example.com/secret"
+	response, err := client.CreateNotificationMethod(&models.NotificationRequestBody{
+		Name: &name, Type: &notificationType, Address: &address,
+	})
+	if err != nil {
+		t.Fatalf("Error %s creating notification method", err)
+	}
+	if response.Address != address {
+		t.Errorf("Expected the response body to still reach the caller but was %+v", response)
+	}
+
+	if len(logger.records) != 1 {
+		t.Fatalf("Expected 1 log record but was %d", len(logger.records))
+	}
+	record := logger.records[0]
+	if record.level != "debug" || record.fields["method"] != "POST" || record.fields["status"] != "201" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	for key, value := range record.fields {
+		if strings.Contains(value, "abcdef") || strings.Contains(value, "hooks.example.com") {
+			t.Errorf("Expected %s to be redacted but was %s", key, value)
+		}
+	}
+	if !strings.Contains(record.fields["request_body"], `"address":"REDACTED"`) {
+		t.Errorf("Expected redacted address in request body but was %s", record.fields["request_body"])
+	}
+	curl := record.fields["curl"]
+	if !strings.HasPrefix(curl, "curl -X POST '"+server.URL) || !strings.Contains(curl, `-H "X-Auth-Token: $OS_AUTH_TOKEN"`) {
+		t.Errorf("Unexpected curl command %s", curl)
+	}
+}
+
+func TestRequestLoggingErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetLogger(logger)
+	if _, err := client.GetMetrics(nil); err == nil {
+		t.Fatalf("Expected error for status 500")
+	}
+	if len(logger.records) != 1 || logger.records[0].level != "error" {
+		t.Errorf("Expected one error record but was %+v", logger.records)
+	}
+	if _, found := logger.records[0].fields["request_headers"]; found {
+		t.Errorf("Expected headers to be left out by default")
+	}
+}
+
+func TestRequestLoggingKeepsResponseLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"links": [], "elements": [{"name": "` + strings.Repeat("x", 1000) + `"}]}`))
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetLogger(logger)
+	client.SetLogOptions(LogOptions{Bodies: true})
+	client.SetMaxResponseSize(100)
+	if _, err := client.GetMetrics(nil); err == nil || !strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("Expected the size limit to apply with body logging but was %v", err)
+	}
+	if len(logger.records) != 1 || logger.records[0].fields["response_body"] != "(more than 100 bytes)" {
+		t.Errorf("Expected the logged body to be capped but was %+v", logger.records)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	body := `{"auth": {"identity": {"password": {"user": {"name": "admin", "password": "hunter2"}}}}, "items": [{"api_token": "x"}]}`
+	redactedBody := redactBody([]byte(body))
+	if strings.Contains(redactedBody, "hunter2") || strings.Contains(redactedBody, `"x"`) {
+		t.Errorf("Expected credentials to be redacted but was %s", redactedBody)
+	}
+	if redactBody([]byte("plain text")) != "(10 bytes, not JSON)" {
+		t.Errorf("Expected non JSON bodies to be summarized")
+	}
+}
+
+func TestRequestLoggingCompressedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetLogger(logger)
+	client.SetLogOptions(LogOptions{Bodies: true, Curl: true})
+	client.SetRequestCompression(1024)
+	if err := client.CreateMetrics(nil, largeMetrics()); err != nil {
+		t.Fatalf("Error %s creating metrics", err)
+	}
+	if len(logger.records) != 1 {
+		t.Fatalf("Expected 1 log record but was %d", len(logger.records))
+	}
+	curl := logger.records[0].fields["curl"]
+	if strings.Contains(curl, "Content-Encoding") || !strings.Contains(curl, `--data-binary '[{"dimensions"`) {
+		t.Errorf("Expected an uncompressed curl command but was %s", curl)
+	}
+}
@@ -53,7 +53,23 @@ func CreateMetric(tenantID *string, metricRequestBody *models.MetricRequestBody)
 	return monClient.CreateMetric(tenantID, metricRequestBody)
 }
 
+func CreateMetrics(tenantID *string, metricRequestBodies []models.MetricRequestBody) error {
+	return monClient.CreateMetrics(tenantID, metricRequestBodies)
+}
+
 func (c *Client) CreateMetric(tenantID *string, metricRequestBody *models.MetricRequestBody) error {
+	return c.postMetrics(tenantID, *metricRequestBody)
+}
+
+// CreateMetrics posts several metrics in a single request.
+func (c *Client) CreateMetrics(tenantID *string, metricRequestBodies []models.MetricRequestBody) error {
+	if len(metricRequestBodies) == 0 {
+		return nil
+	}
+	return c.postMetrics(tenantID, metricRequestBodies)
+}
+
+func (c *Client) postMetrics(tenantID *string, toSend interface{}) error {
 	urlValues := url.Values{}
 	if tenantID != nil {
 		urlValues.Add("tenant_id", *tenantID)
@@ -62,7 +78,7 @@ func (c *Client) CreateMetric(tenantID *string, metricRequestBody *models.Metric
 	if URLerr != nil {
 		return URLerr
 	}
-	byteInput, marshalErr := json.Marshal(*metricRequestBody)
+	byteInput, marshalErr := json.Marshal(toSend)
 	if marshalErr != nil {
 		return marshalErr
 	}
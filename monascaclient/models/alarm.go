@@ -36,6 +36,7 @@ type AlarmQuery struct {
 	AlarmDefinitionID     *string            `queryParameter:"alarm_definition_id"`
 	MetricName            *string            `queryParameter:"metric_name"`
 	MetricDimensions      *map[string]string `queryParameter:"metric_dimensions"`
+	MetricDimensionFilter *DimensionFilter   `queryParameter:"metric_dimensions"`
 	State                 *string            `queryParameter:"state"`
 	Severity              *string            `queryParameter:"severity"`
 	LifecycleState        *string            `queryParameter:"lifecycle_state"`
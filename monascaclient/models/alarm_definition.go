@@ -21,8 +21,8 @@ type AlarmDefinitionsResponse struct {
 
 type AlarmDefinitionElement struct {
 	AlarmDefinition
-	// ActionsEnabled 	bool     `json:"actions_enabled"`
-	Deterministic bool `json:"deterministic"`
+	ActionsEnabled bool `json:"actions_enabled"`
+	Deterministic  bool `json:"deterministic"`
 	ResponseElement
 }
 
@@ -38,12 +38,13 @@ type AlarmDefinition struct {
 }
 
 type AlarmDefinitionQuery struct {
-	Name       *string            `queryParameter:"name"`
-	Dimensions *map[string]string `queryParameter:"dimensions"`
-	Severity   *string            `queryParameter:"severity"`
-	SortBy     *string            `queryParameter:"sort_by"`
-	Offset     *int               `queryParameter:"offset"`
-	Limit      *int               `queryParameter:"limit"`
+	Name            *string            `queryParameter:"name"`
+	Dimensions      *map[string]string `queryParameter:"dimensions"`
+	DimensionFilter *DimensionFilter   `queryParameter:"dimensions"`
+	Severity        *string            `queryParameter:"severity"`
+	SortBy          *string            `queryParameter:"sort_by"`
+	Offset          *int               `queryParameter:"offset"`
+	Limit           *int               `queryParameter:"limit"`
 }
 
 type AlarmDefinitionRequestBody struct {
@@ -0,0 +1,115 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DimensionFilter matches metrics by dimension. A key with no values only
+// requires the dimension to exist, a key with several values matches any of
+// them. It is encoded as key1:v1|v2,key2; the API has no escaping, so keys
+// cannot contain commas, pipes or colons and values cannot contain commas or
+// pipes. Query structs combine it with their plain Dimensions map.
+type DimensionFilter map[string][]string
+
+func NewDimensionFilter() DimensionFilter {
+	return DimensionFilter{}
+}
+
+// DimensionFilterFromMap returns a filter matching every dimension exactly.
+func DimensionFilterFromMap(dimensions map[string]string) DimensionFilter {
+	filter := make(DimensionFilter, len(dimensions))
+	for key, value := range dimensions {
+		filter.Exact(key, value)
+	}
+	return filter
+}
+
+// Exact requires the dimension to have the value. An empty value is the same
+// as Exists.
+func (f DimensionFilter) Exact(key string, value string) DimensionFilter {
+	if value == "" {
+		return f.Exists(key)
+	}
+	f[key] = []string{value}
+	return f
+}
+
+// AnyOf requires the dimension to have one of the values.
+func (f DimensionFilter) AnyOf(key string, values ...string) DimensionFilter {
+	nonEmpty := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	f[key] = nonEmpty
+	return f
+}
+
+// Exists requires the dimension to be present with any value.
+func (f DimensionFilter) Exists(key string) DimensionFilter {
+	f[key] = nil
+	return f
+}
+
+// String encodes the filter with the keys sorted, without checking that the
+// API can parse it; see MarshalQuery.
+func (f DimensionFilter) String() string {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entry := key
+		if values := f[key]; len(values) > 0 {
+			entry += ":" + strings.Join(values, "|")
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, ",")
+}
+
+// Copy returns a filter that can be changed independently.
+func (f DimensionFilter) Copy() DimensionFilter {
+	copied := make(DimensionFilter, len(f))
+	for key, values := range f {
+		copied[key] = append([]string(nil), values...)
+	}
+	return copied
+}
+
+// MarshalQuery encodes the filter, failing for keys or values the API would
+// split apart. Colons in values are sent as they are, since the API only
+// splits on the first colon.
+func (f DimensionFilter) MarshalQuery() (string, error) {
+	for key, values := range f {
+		if strings.ContainsAny(key, ",|:") {
+			return "", fmt.Errorf("Dimension name %q cannot contain ',', '|' or ':'", key)
+		}
+		for _, value := range values {
+			if strings.ContainsAny(value, ",|") {
+				return "", fmt.Errorf("Value %q of dimension %s cannot contain ',' or '|'", value, key)
+			}
+		}
+	}
+	return f.String(), nil
+}
@@ -30,13 +30,14 @@ type MeasurementElement struct {
 }
 
 type MeasurementQuery struct {
-	TenantID   *string            `queryParameter:"tenant_id"`
-	Name       *string            `queryParameter:"name"`
-	Dimensions *map[string]string `queryParameter:"dimensions"`
-	StartTime  *time.Time         `queryParameter:"start_time"`
-	EndTime    *time.Time         `queryParameter:"end_time"`
-	Offset     *int               `queryParameter:"offset"`
-	Limit      *int               `queryParameter:"limit"`
-	Merge      *bool              `queryParameter:"merge_metrics"`
-	GroupBy    *string            `queryParameter:"group_by"`
+	TenantID        *string            `queryParameter:"tenant_id"`
+	Name            *string            `queryParameter:"name"`
+	Dimensions      *map[string]string `queryParameter:"dimensions"`
+	DimensionFilter *DimensionFilter   `queryParameter:"dimensions"`
+	StartTime       *time.Time         `queryParameter:"start_time"`
+	EndTime         *time.Time         `queryParameter:"end_time"`
+	Offset          *int               `queryParameter:"offset"`
+	Limit           *int               `queryParameter:"limit"`
+	Merge           *bool              `queryParameter:"merge_metrics"`
+	GroupBy         *string            `queryParameter:"group_by"`
 }
@@ -20,8 +20,9 @@ type MetricNameResponse struct {
 }
 
 type MetricNameQuery struct {
-	TenantID   *string            `queryParameter:"tenant_id"`
-	Dimensions *map[string]string `queryParameter:"dimensions"`
-	Offset     *string            `queryParameter:"offset"`
-	Limit      *int               `queryParameter:"limit"`
+	TenantID        *string            `queryParameter:"tenant_id"`
+	Dimensions      *map[string]string `queryParameter:"dimensions"`
+	DimensionFilter *DimensionFilter   `queryParameter:"dimensions"`
+	Offset          *string            `queryParameter:"offset"`
+	Limit           *int               `queryParameter:"limit"`
 }
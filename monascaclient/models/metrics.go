@@ -27,13 +27,14 @@ type Metric struct {
 }
 
 type MetricQuery struct {
-	TenantID   *string            `queryParameter:"tenant_id"`
-	Name       *string            `queryParameter:"name"`
-	Dimensions *map[string]string `queryParameter:"dimensions"`
-	StartTime  *time.Time         `queryParameter:"start_time"`
-	EndTime    *time.Time         `queryParameter:"end_time"`
-	Offset     *int               `queryParameter:"offset"`
-	Limit      *int               `queryParameter:"limit"`
+	TenantID        *string            `queryParameter:"tenant_id"`
+	Name            *string            `queryParameter:"name"`
+	Dimensions      *map[string]string `queryParameter:"dimensions"`
+	DimensionFilter *DimensionFilter   `queryParameter:"dimensions"`
+	StartTime       *time.Time         `queryParameter:"start_time"`
+	EndTime         *time.Time         `queryParameter:"end_time"`
+	Offset          *int               `queryParameter:"offset"`
+	Limit           *int               `queryParameter:"limit"`
 }
 
 type MetricRequestBody struct {
@@ -30,15 +30,16 @@ type StatisticElement struct {
 }
 
 type StatisticQuery struct {
-	TenantID   *string            `queryParameter:"tenant_id"`
-	Name       *string            `queryParameter:"name"`
-	Dimensions *map[string]string `queryParameter:"dimensions"`
-	Statistics *string            `queryParameter:"statistics"`
-	StartTime  *time.Time         `queryParameter:"start_time"`
-	EndTime    *time.Time         `queryParameter:"end_time"`
-	Period     *int               `queryParameter:"period"`
-	Offset     *int               `queryParameter:"offset"`
-	Limit      *int               `queryParameter:"limit"`
-	Merge      *bool              `queryParameter:"merge_metrics"`
-	GroupBy    *string            `queryParameter:"group_by"`
+	TenantID        *string            `queryParameter:"tenant_id"`
+	Name            *string            `queryParameter:"name"`
+	Dimensions      *map[string]string `queryParameter:"dimensions"`
+	DimensionFilter *DimensionFilter   `queryParameter:"dimensions"`
+	Statistics      *string            `queryParameter:"statistics"`
+	StartTime       *time.Time         `queryParameter:"start_time"`
+	EndTime         *time.Time         `queryParameter:"end_time"`
+	Period          *int               `queryParameter:"period"`
+	Offset          *int               `queryParameter:"offset"`
+	Limit           *int               `queryParameter:"limit"`
+	Merge           *bool              `queryParameter:"merge_metrics"`
+	GroupBy         *string            `queryParameter:"group_by"`
 }
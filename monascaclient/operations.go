@@ -0,0 +1,147 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Operation names, as reported in Operation.Name and accepted by
+// SetOperationLimits. Each is named after the client method making the
+// request, except that CreateMetric and CreateMetrics send the same request
+// and are both OperationCreateMetrics. Requests no client method makes are
+// named after their method and path.
+const (
+	OperationGetMetrics               = "GetMetrics"
+	OperationCreateMetrics            = "CreateMetrics"
+	OperationGetMetricNames           = "GetMetricNames"
+	OperationGetDimensionNames        = "GetDimensionNames"
+	OperationGetDimensionValues       = "GetDimensionValues"
+	OperationGetMeasurements          = "GetMeasurements"
+	OperationGetStatistics            = "GetStatistics"
+	OperationGetAlarmDefinitions      = "GetAlarmDefinitions"
+	OperationGetAlarmDefinition       = "GetAlarmDefinition"
+	OperationCreateAlarmDefinition    = "CreateAlarmDefinition"
+	OperationUpdateAlarmDefinition    = "UpdateAlarmDefinition"
+	OperationPatchAlarmDefinition     = "PatchAlarmDefinition"
+	OperationDeleteAlarmDefinition    = "DeleteAlarmDefinition"
+	OperationGetAlarms                = "GetAlarms"
+	OperationGetAlarm                 = "GetAlarm"
+	OperationUpdateAlarm              = "UpdateAlarm"
+	OperationPatchAlarm               = "PatchAlarm"
+	OperationDeleteAlarm              = "DeleteAlarm"
+	OperationGetNotificationMethods   = "GetNotificationMethods"
+	OperationGetNotificationMethod    = "GetNotificationMethod"
+	OperationCreateNotificationMethod = "CreateNotificationMethod"
+	OperationUpdateNotificationMethod = "UpdateNotificationMethod"
+	OperationPatchNotificationMethod  = "PatchNotificationMethod"
+	OperationDeleteNotificationMethod = "DeleteNotificationMethod"
+)
+
+// resourceOperations names the requests on a resource collection and its
+// members; an empty name is a request no client method makes.
+type resourceOperations struct {
+	list   string
+	get    string
+	create string
+	update string
+	patch  string
+	remove string
+}
+
+var resources = map[string]resourceOperations{
+	"alarm-definitions": {
+		OperationGetAlarmDefinitions, OperationGetAlarmDefinition, OperationCreateAlarmDefinition,
+		OperationUpdateAlarmDefinition, OperationPatchAlarmDefinition, OperationDeleteAlarmDefinition,
+	},
+	"alarms": {
+		list: OperationGetAlarms, get: OperationGetAlarm,
+		update: OperationUpdateAlarm, patch: OperationPatchAlarm, remove: OperationDeleteAlarm,
+	},
+	"notification-methods": {
+		OperationGetNotificationMethods, OperationGetNotificationMethod, OperationCreateNotificationMethod,
+		OperationUpdateNotificationMethod, OperationPatchNotificationMethod, OperationDeleteNotificationMethod,
+	},
+}
+
+var metricOperations = map[string]string{
+	"":                        OperationGetMetrics,
+	"names":                   OperationGetMetricNames,
+	"statistics":              OperationGetStatistics,
+	"measurements":            OperationGetMeasurements,
+	"dimensions/names/values": OperationGetDimensionValues,
+	"dimensions/names/names":  OperationGetDimensionNames,
+}
+
+// collectionPaths are fixed paths below a resource, which would otherwise be
+// taken for the ID of a single resource.
+var collectionPaths = map[string]bool{
+	"alarms/state-history": true,
+}
+
+// operationName maps a request to one of the Operation names, such as
+// OperationGetStatistics or OperationDeleteAlarm. Other requests are named
+// after their method and path, with the ID of a single resource replaced by
+// {id}.
+func operationName(method string, requestURL *url.URL) string {
+	path := strings.Trim(requestURL.Path, "/")
+	segments := strings.SplitN(path, "/", 3)
+	if len(segments) < 2 || segments[0] != "v2.0" {
+		return method + " " + path
+	}
+	rest := ""
+	if len(segments) == 3 {
+		rest = segments[2]
+	}
+
+	if segments[1] == "metrics" {
+		if method == "POST" && rest == "" {
+			return OperationCreateMetrics
+		}
+		if name, found := metricOperations[rest]; found && method == "GET" {
+			return name
+		}
+		return method + " " + path
+	}
+
+	operations, found := resources[segments[1]]
+	if !found || collectionPaths[segments[1]+"/"+rest] {
+		return method + " " + path
+	}
+	if strings.Contains(rest, "/") {
+		member := strings.SplitN(rest, "/", 2)
+		return method + " " + strings.Join([]string{segments[0], segments[1], "{id}", member[1]}, "/")
+	}
+	name := ""
+	switch {
+	case method == "GET" && rest == "":
+		name = operations.list
+	case method == "GET":
+		name = operations.get
+	case method == "POST" && rest == "":
+		name = operations.create
+	case method == "PUT" && rest != "":
+		name = operations.update
+	case method == "PATCH" && rest != "":
+		name = operations.patch
+	case method == "DELETE" && rest != "":
+		name = operations.remove
+	}
+	if name == "" {
+		return method + " " + path
+	}
+	return name
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package otelmonasca instruments a monascaclient.Client with OpenTelemetry.
+// Each API call becomes a client span named after the client method, such as
+// monasca.GetStatistics, its trace context is propagated to the API in the
+// request headers, and request, error, retry and re-authentication counts are
+// recorded together with a latency histogram.
+//
+// The package, and with it OpenTelemetry, is only linked in when imported:
+//
+//	instrumentation, err := otelmonasca.New(nil, nil, nil)
+//	if err != nil {
+//		return err
+//	}
+//	client.SetInstrumentation(instrumentation)
+package otelmonasca
+
+import (
+	"context"
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const (
+	instrumentationName = "github.com/monasca/golang-monascaclient/monascaclient/otelmonasca"
+	spanPrefix          = "monasca."
+)
+
+// Attribute keys set on spans and, except for the result count, on metrics.
+const (
+	OperationKey   = attribute.Key("monasca.operation")
+	TenantIDKey    = attribute.Key("monasca.tenant_id")
+	ResultCountKey = attribute.Key("monasca.result_count")
+	MethodKey      = attribute.Key("http.request.method")
+	StatusCodeKey  = attribute.Key("http.response.status_code")
+	URLKey         = attribute.Key("url.full")
+	ServerKey      = attribute.Key("server.address")
+	RetriesKey     = attribute.Key("monasca.retries")
+)
+
+// Instrumentation implements monascaclient.Instrumentation.
+type Instrumentation struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	requests   metric.Int64Counter
+	errors     metric.Int64Counter
+	retries    metric.Int64Counter
+	reauths    metric.Int64Counter
+	latency    metric.Float64Histogram
+}
+
+// New creates an instrumentation. Nil arguments are replaced by the global
+// tracer provider, meter provider and propagator.
+func New(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider,
+	propagator propagation.TextMapPropagator) (*Instrumentation, error) {
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	if meterProvider == nil {
+		meterProvider = otel.GetMeterProvider()
+	}
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+
+	meter := meterProvider.Meter(instrumentationName)
+	i := &Instrumentation{
+		tracer:     tracerProvider.Tracer(instrumentationName),
+		propagator: propagator,
+	}
+	var err error
+	if i.requests, err = meter.Int64Counter("monasca.client.requests",
+		metric.WithDescription("API calls made"), metric.WithUnit("{request}")); err != nil {
+		return nil, fmt.Errorf("Failed to create request counter: %v", err)
+	}
+	if i.errors, err = meter.Int64Counter("monasca.client.errors",
+		metric.WithDescription("API calls that failed or returned an error status"), metric.WithUnit("{request}")); err != nil {
+		return nil, fmt.Errorf("Failed to create error counter: %v", err)
+	}
+	if i.retries, err = meter.Int64Counter("monasca.client.retries",
+		metric.WithDescription("HTTP attempts beyond the first"), metric.WithUnit("{attempt}")); err != nil {
+		return nil, fmt.Errorf("Failed to create retry counter: %v", err)
+	}
+	if i.reauths, err = meter.Int64Counter("monasca.client.reauthentications",
+		metric.WithDescription("Keystone token refreshes after a 401"), metric.WithUnit("{token}")); err != nil {
+		return nil, fmt.Errorf("Failed to create re-authentication counter: %v", err)
+	}
+	if i.latency, err = meter.Float64Histogram("monasca.client.duration",
+		metric.WithDescription("Duration of API calls including retries"), metric.WithUnit("s")); err != nil {
+		return nil, fmt.Errorf("Failed to create latency histogram: %v", err)
+	}
+	return i, nil
+}
+
+// StartOperation starts a span and injects its context into header.
+func (i *Instrumentation) StartOperation(ctx context.Context, operation monascaclient.Operation,
+	header http.Header) (context.Context, func(monascaclient.OperationResult)) {
+	attributes := []attribute.KeyValue{
+		OperationKey.String(operation.Name),
+		MethodKey.String(operation.Method),
+	}
+	if operation.URL != nil {
+		attributes = append(attributes, ServerKey.String(operation.URL.Hostname()))
+	}
+	if operation.TenantID != "" {
+		attributes = append(attributes, TenantIDKey.String(operation.TenantID))
+	}
+
+	ctx, span := i.tracer.Start(ctx, spanPrefix+operation.Name,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+	if operation.URL != nil {
+		span.SetAttributes(URLKey.String(redactedURL(operation)))
+	}
+	i.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+
+	return ctx, func(result monascaclient.OperationResult) {
+		failed := result.Err != nil || result.StatusCode >= 400
+		retries := result.Attempts - 1
+		if result.StatusCode != 0 {
+			status := StatusCodeKey.Int(result.StatusCode)
+			attributes = append(attributes, status)
+			span.SetAttributes(status)
+		}
+		if result.ResultCount >= 0 {
+			span.SetAttributes(ResultCountKey.Int(result.ResultCount))
+		}
+		if retries > 0 {
+			span.SetAttributes(RetriesKey.Int(retries))
+		}
+		if result.Err != nil {
+			span.RecordError(result.Err)
+			span.SetStatus(codes.Error, result.Err.Error())
+		} else if failed {
+			span.SetStatus(codes.Error, http.StatusText(result.StatusCode))
+		}
+		span.End()
+
+		set := metric.WithAttributeSet(attribute.NewSet(attributes...))
+		i.requests.Add(ctx, 1, set)
+		i.latency.Record(ctx, result.Latency.Seconds(), set)
+		if failed {
+			i.errors.Add(ctx, 1, set)
+		}
+		if retries > 0 {
+			i.retries.Add(ctx, int64(retries), set)
+		}
+		if result.Reauthenticated {
+			i.reauths.Add(ctx, 1, set)
+		}
+	}
+}
+
+// redactedURL drops the query, which may contain dimension values, and any
+// credentials from the URL.
+func redactedURL(operation monascaclient.Operation) string {
+	redacted := *operation.URL
+	redacted.User = nil
+	redacted.RawQuery = ""
+	return redacted.String()
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package otelmonasca
+
+import (
+	"context"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInstrumentation(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		if r.URL.Path == "/v2.0/metrics/statistics" {
+			w.Write([]byte(`{"links": [], "elements": [{"name": "cpu"}]}`))
+			return
+		}
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	instrumentation, err := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+		sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)), propagation.TraceContext{})
+	if err != nil {
+		t.Fatalf("Error %s creating instrumentation", err)
+	}
+	client := monascaclient.New()
+	client.SetBaseURL(server.URL)
+	client.SetInstrumentation(instrumentation)
+
+	tenantID := "tenant"
+	_, err = client.GetStatistics(&models.StatisticQuery{TenantID: &tenantID})
+	if err != nil {
+		t.Fatalf("Error %s getting statistics", err)
+	}
+	if _, err = client.GetMetrics(nil); err == nil {
+		t.Fatalf("Expected server error")
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("Expected 2 spans but was %d", len(ended))
+	}
+	span := ended[0]
+	if span.Name() != "monasca.GetStatistics" {
+		t.Errorf("Expected span monasca.GetStatistics but was %s", span.Name())
+	}
+	if traceparent == "" || traceparent[36:52] != ended[1].SpanContext().SpanID().String() {
+		t.Errorf("Expected trace context of the span to be propagated but was '%s'", traceparent)
+	}
+	values := map[string]string{}
+	for _, kv := range span.Attributes() {
+		values[string(kv.Key)] = kv.Value.Emit()
+	}
+	expected := map[string]string{
+		string(TenantIDKey):    "tenant",
+		string(StatusCodeKey):  "200",
+		string(ResultCountKey): "1",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected attribute %s to be %s but was '%s'", key, value, values[key])
+		}
+	}
+	if ended[1].Status().Code != codes.Error {
+		t.Errorf("Expected failed span to have error status but was %v", ended[1].Status())
+	}
+
+	var collected metricdata.ResourceMetrics
+	if err = reader.Collect(context.Background(), &collected); err != nil {
+		t.Fatalf("Error %s collecting metrics", err)
+	}
+	sums := map[string]int64{}
+	var latencyCount uint64
+	for _, scope := range collected.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, point := range data.DataPoints {
+					sums[m.Name] += point.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, point := range data.DataPoints {
+					latencyCount += point.Count
+				}
+			}
+		}
+	}
+	if sums["monasca.client.requests"] != 2 || sums["monasca.client.errors"] != 1 {
+		t.Errorf("Expected 2 requests and 1 error but was %v", sums)
+	}
+	if latencyCount != 2 {
+		t.Errorf("Expected 2 latency observations but was %d", latencyCount)
+	}
+}
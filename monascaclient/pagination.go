@@ -0,0 +1,223 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/url"
+)
+
+// nextPageOffset returns the offset carried by the "next" link of a paged
+// response, or an empty string when there are no more pages.
+func nextPageOffset(links []models.Link) string {
+	for _, link := range links {
+		if link.Rel != "next" {
+			continue
+		}
+		nextURL, err := url.Parse(link.Href)
+		if err != nil {
+			return ""
+		}
+		return nextURL.Query().Get("offset")
+	}
+	return ""
+}
+
+func (c *Client) callMonascaGetPage(basePath string, queryStruct interface{}, offset string, returned interface{}) error {
+	urlValues, err := convertStructToQueryParameters(queryStruct)
+	if err != nil {
+		return err
+	}
+	if offset != "" {
+		urlValues.Set("offset", offset)
+	}
+	return c.callMonascaGetValues(basePath, urlValues, returned)
+}
+
+// followPages calls page with the offset of every page in turn, starting with
+// none. page fetches and consumes one page and returns its links and element
+// count; paging stops at the last page or when the API repeats an offset.
+func followPages(page func(offset string) ([]models.Link, int, error)) error {
+	offset := ""
+	for {
+		links, count, err := page(offset)
+		if err != nil {
+			return err
+		}
+
+		next := nextPageOffset(links)
+		if next == "" || next == offset || count == 0 {
+			return nil
+		}
+		offset = next
+	}
+}
+
+func (c *Client) getAllAlarmDefinitions(alarmDefinitionQuery *models.AlarmDefinitionQuery) ([]models.AlarmDefinitionElement, error) {
+	elements := []models.AlarmDefinitionElement{}
+	err := followPages(func(offset string) ([]models.Link, int, error) {
+		response := new(models.AlarmDefinitionsResponse)
+		if err := c.callMonascaGetPage(alarmDefinitionsBasePath, alarmDefinitionQuery, offset, response); err != nil {
+			return nil, 0, err
+		}
+		elements = append(elements, response.Elements...)
+		return response.Links, len(response.Elements), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return elements, nil
+}
+
+func (c *Client) getAllNotificationMethods(notificationQuery *models.NotificationQuery) ([]models.NotificationElement, error) {
+	elements := []models.NotificationElement{}
+	err := followPages(func(offset string) ([]models.Link, int, error) {
+		response := new(models.NotificationResponse)
+		if err := c.callMonascaGetPage(notificationsBasePath, notificationQuery, offset, response); err != nil {
+			return nil, 0, err
+		}
+		elements = append(elements, response.Elements...)
+		return response.Links, len(response.Elements), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return elements, nil
+}
+
+func (c *Client) getAllAlarms(alarmQuery *models.AlarmQuery) ([]models.Alarm, error) {
+	elements := []models.Alarm{}
+	err := followPages(func(offset string) ([]models.Link, int, error) {
+		response := new(models.AlarmsResponse)
+		if err := c.callMonascaGetPage(alarmsBasePath, alarmQuery, offset, response); err != nil {
+			return nil, 0, err
+		}
+		elements = append(elements, response.Elements...)
+		return response.Links, len(response.Elements), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return elements, nil
+}
+
+func ForEachMetricPage(metricQuery *models.MetricQuery, handle func(*models.MetricsResponse) error) error {
+	return monClient.ForEachMetricPage(metricQuery, handle)
+}
+
+func ForEachMeasurementPage(measurementQuery *models.MeasurementQuery, handle func(*models.MeasurementsResponse) error) error {
+	return monClient.ForEachMeasurementPage(measurementQuery, handle)
+}
+
+func ForEachStatisticPage(statisticsQuery *models.StatisticQuery, handle func(*models.StatisticsResponse) error) error {
+	return monClient.ForEachStatisticPage(statisticsQuery, handle)
+}
+
+func ForEachMetricNamePage(metricQuery *models.MetricNameQuery, handle func(*models.MetricNameResponse) error) error {
+	return monClient.ForEachMetricNamePage(metricQuery, handle)
+}
+
+func ForEachDimensionNamePage(dimensionQuery *models.DimensionNameQuery, handle func(*models.DimensionValueResponse) error) error {
+	return monClient.ForEachDimensionNamePage(dimensionQuery, handle)
+}
+
+func ForEachDimensionValuePage(dimensionQuery *models.DimensionValueQuery, handle func(*models.DimensionValueResponse) error) error {
+	return monClient.ForEachDimensionValuePage(dimensionQuery, handle)
+}
+
+// ForEachMetricPage follows the next links of a metric query and calls handle
+// with every page.
+func (c *Client) ForEachMetricPage(metricQuery *models.MetricQuery, handle func(*models.MetricsResponse) error) error {
+	return followPages(func(offset string) ([]models.Link, int, error) {
+		response := new(models.MetricsResponse)
+		if err := c.callMonascaGetPage(metricsBasePath, metricQuery, offset, response); err != nil {
+			return nil, 0, err
+		}
+		if err := handle(response); err != nil {
+			return nil, 0, err
+		}
+		return response.Links, len(response.Elements), nil
+	})
+}
+
+// ForEachMeasurementPage follows the next links of a measurement query and
+// calls handle with every page, so that only one page is held at a time.
+func (c *Client) ForEachMeasurementPage(measurementQuery *models.MeasurementQuery, handle func(*models.MeasurementsResponse) error) error {
+	return followPages(func(offset string) ([]models.Link, int, error) {
+		response := new(models.MeasurementsResponse)
+		if err := c.callMonascaGetPage(metricsBasePath+"/measurements", measurementQuery, offset, response); err != nil {
+			return nil, 0, err
+		}
+		if err := handle(response); err != nil {
+			return nil, 0, err
+		}
+		return response.Links, len(response.Elements), nil
+	})
+}
+
+// ForEachStatisticPage follows the next links of a statistics query and calls
+// handle with every page.
+func (c *Client) ForEachStatisticPage(statisticsQuery *models.StatisticQuery, handle func(*models.StatisticsResponse) error) error {
+	return followPages(func(offset string) ([]models.Link, int, error) {
+		response := new(models.StatisticsResponse)
+		if err := c.callMonascaGetPage(metricsBasePath+"/statistics", statisticsQuery, offset, response); err != nil {
+			return nil, 0, err
+		}
+		if err := handle(response); err != nil {
+			return nil, 0, err
+		}
+		return response.Links, len(response.Elements), nil
+	})
+}
+
+// ForEachMetricNamePage follows the next links of a metric name query and
+// calls handle with every page. GetMetricNames returns the first page only.
+func (c *Client) ForEachMetricNamePage(metricQuery *models.MetricNameQuery, handle func(*models.MetricNameResponse) error) error {
+	return followPages(func(offset string) ([]models.Link, int, error) {
+		response := new(models.MetricNameResponse)
+		if err := c.callMonascaGetPage(metricsBasePath+"/names", metricQuery, offset, response); err != nil {
+			return nil, 0, err
+		}
+		if err := handle(response); err != nil {
+			return nil, 0, err
+		}
+		return response.Links, len(response.Elements), nil
+	})
+}
+
+// ForEachDimensionNamePage follows the next links of a dimension name query
+// and calls handle with every page. The names are in the Value of each element.
+func (c *Client) ForEachDimensionNamePage(dimensionQuery *models.DimensionNameQuery, handle func(*models.DimensionValueResponse) error) error {
+	return c.forEachDimensionPage(metricsBasePath+"/dimensions/names/names", dimensionQuery, handle)
+}
+
+// ForEachDimensionValuePage follows the next links of a dimension value query
+// and calls handle with every page.
+func (c *Client) ForEachDimensionValuePage(dimensionQuery *models.DimensionValueQuery, handle func(*models.DimensionValueResponse) error) error {
+	return c.forEachDimensionPage(metricsBasePath+"/dimensions/names/values", dimensionQuery, handle)
+}
+
+func (c *Client) forEachDimensionPage(basePath string, dimensionQuery interface{}, handle func(*models.DimensionValueResponse) error) error {
+	return followPages(func(offset string) ([]models.Link, int, error) {
+		response := new(models.DimensionValueResponse)
+		if err := c.callMonascaGetPage(basePath, dimensionQuery, offset, response); err != nil {
+			return nil, 0, err
+		}
+		if err := handle(response); err != nil {
+			return nil, 0, err
+		}
+		return response.Links, len(response.Elements), nil
+	})
+}
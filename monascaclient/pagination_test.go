@@ -0,0 +1,59 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestForEachDimensionValuePage(t *testing.T) {
+	var queries []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("offset") == "" {
+			fmt.Fprintf(w, `{"links": [{"rel": "next", "href": "%s/v2.0/metrics/dimensions/names/values?offset=web2"}],
+				"elements": [{"dimension_value": "web1"}, {"dimension_value": "web2"}]}`, server.URL)
+			return
+		}
+		w.Write([]byte(`{"links": [], "elements": [{"dimension_value": "web3"}]}`))
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	name := "hostname"
+	var values []string
+	err := client.ForEachDimensionValuePage(&models.DimensionValueQuery{DimensionName: &name}, func(response *models.DimensionValueResponse) error {
+		for _, element := range response.Elements {
+			values = append(values, element.Value)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error %s paging dimension values", err)
+	}
+	if strings.Join(values, ",") != "web1,web2,web3" {
+		t.Errorf("Expected web1,web2,web3 but was %v", values)
+	}
+	if len(queries) != 2 || !strings.Contains(queries[1], "offset=web2") || !strings.Contains(queries[1], "dimension_name=hostname") {
+		t.Errorf("Expected second request to keep the query and add the offset but was %v", queries)
+	}
+}
@@ -16,54 +16,178 @@ package monascaclient
 
 import (
 	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
 	"net/url"
 	"reflect"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func convertStructToQueryParameters(inputStruct interface{}) url.Values {
+// QueryMarshaler is implemented by field types that encode themselves as a
+// query parameter value. An empty value leaves the parameter out.
+type QueryMarshaler interface {
+	MarshalQuery() (string, error)
+}
+
+var (
+	queryMarshalerType = reflect.TypeOf((*QueryMarshaler)(nil)).Elem()
+	timeType           = reflect.TypeOf(time.Time{})
+	durationType       = reflect.TypeOf(time.Duration(0))
+)
+
+// convertStructToQueryParameters encodes the fields of a query struct tagged
+// with `queryParameter:"name[,omitempty]"`. Nil pointers are skipped, as are
+// zero values of omitempty fields. Embedded structs are flattened and fields
+// sharing a parameter name, such as Dimensions and DimensionFilter, are joined
+// with commas. Times are sent in UTC, durations as whole seconds, slices and
+// maps as comma separated lists.
+func convertStructToQueryParameters(inputStruct interface{}) (url.Values, error) {
 	urlValues := url.Values{}
 	values := reflect.ValueOf(inputStruct)
-	if values.IsNil() {
-		return urlValues
+	if !values.IsValid() || (values.Kind() == reflect.Ptr && values.IsNil()) {
+		return urlValues, nil
+	}
+	values = reflect.Indirect(values)
+	if values.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Query must be a struct but was %s", values.Type())
 	}
-	values = values.Elem()
+	return urlValues, addStructParameters(values, urlValues)
+}
+
+func addStructParameters(values reflect.Value, urlValues url.Values) error {
 	typ := values.Type()
-	// Loop through the struct
 	for i := 0; i < typ.NumField(); i++ {
 		currentValue := values.Field(i)
 		currentType := typ.Field(i)
+
+		tag, hasTag := currentType.Tag.Lookup("queryParameter")
+		if tag == "-" {
+			continue
+		}
+		if currentType.Anonymous && !hasTag {
+			if currentValue.Kind() == reflect.Ptr {
+				if currentValue.IsNil() {
+					continue
+				}
+				currentValue = currentValue.Elem()
+			}
+			if currentValue.Kind() == reflect.Struct {
+				if err := addStructParameters(currentValue, urlValues); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !hasTag || currentType.PkgPath != "" {
+			continue
+		}
+
 		// Get Query Parameter Name
-		queryParameterKey := currentType.Tag.Get("queryParameter")
+		options := strings.Split(tag, ",")
+		queryParameterKey := options[0]
+		omitEmpty := false
+		for _, option := range options[1:] {
+			if option == "omitempty" {
+				omitEmpty = true
+			}
+		}
+
 		if currentValue.Kind() == reflect.Ptr {
 			if currentValue.IsNil() {
 				continue
 			}
 			currentValue = currentValue.Elem()
 		}
-		addQueryParameter(currentValue, queryParameterKey, &urlValues)
+		if omitEmpty && currentValue.IsZero() {
+			continue
+		}
+		encoded, err := encodeQueryValue(currentValue)
+		if err != nil {
+			return fmt.Errorf("Cannot encode query parameter %s: %v", queryParameterKey, err)
+		}
+		addQueryParameter(queryParameterKey, encoded, currentValue, urlValues)
+	}
+	return nil
+}
+
+func queryMarshaler(value reflect.Value) (QueryMarshaler, bool) {
+	if value.Type().Implements(queryMarshalerType) {
+		return value.Interface().(QueryMarshaler), true
+	}
+	if value.CanAddr() && value.Addr().Type().Implements(queryMarshalerType) {
+		return value.Addr().Interface().(QueryMarshaler), true
 	}
-	return urlValues
+	return nil, false
 }
 
-func addQueryParameter(value reflect.Value, key string, values *url.Values) {
-	if value.Kind() == reflect.Bool || value.Kind() == reflect.String || value.Kind() == reflect.Int {
-		(*values).Add(key, fmt.Sprint(value.Interface()))
-	} else if value.Type() == reflect.TypeOf(time.Time{}) {
-		timeValue := value.Interface().(time.Time)
-		(*values).Add(key, timeValue.UTC().Format(timeFormat))
-	} else if value.Kind() == reflect.Map {
-		mapValue := value.Interface().(map[string]string)
-		if len(mapValue) > 0 {
-			dimensionsSlice := make([]string, 0, len(mapValue))
-			for key := range mapValue {
-				dimensionsSlice = append(dimensionsSlice, key+":"+mapValue[key])
+func encodeQueryValue(value reflect.Value) (string, error) {
+	if marshaler, ok := queryMarshaler(value); ok {
+		return marshaler.MarshalQuery()
+	}
+
+	switch value.Type() {
+	case timeType:
+		return value.Interface().(time.Time).UTC().Format(timeFormat), nil
+	case durationType:
+		return strconv.FormatInt(int64(value.Interface().(time.Duration)/time.Second), 10), nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits()), nil
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String || value.Type().Elem().Kind() != reflect.String {
+			return "", fmt.Errorf("unsupported map type %s", value.Type())
+		}
+		dimensions := make(map[string]string, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			dimensions[iterator.Key().String()] = iterator.Value().String()
+		}
+		return models.DimensionFilterFromMap(dimensions).MarshalQuery()
+	case reflect.Slice, reflect.Array:
+		elements := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			element := value.Index(i)
+			if element.Kind() == reflect.Slice || element.Kind() == reflect.Map || element.Kind() == reflect.Struct {
+				return "", fmt.Errorf("unsupported slice type %s", value.Type())
+			}
+			encoded, err := encodeQueryValue(element)
+			if err != nil {
+				return "", err
 			}
-			// Make sure dimensions are always in correct order to ensure tests pass
-			sort.Strings(dimensionsSlice)
-			(*values).Add(key, strings.Join(dimensionsSlice, ","))
+			elements = append(elements, encoded)
 		}
+		return strings.Join(elements, ","), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", value.Type())
+}
+
+// addQueryParameter adds a value, joining it with any value already added for
+// the key so that a map and a filter in the same query are combined. Empty
+// lists, maps and marshaled values are left out.
+func addQueryParameter(key string, encoded string, value reflect.Value, urlValues url.Values) {
+	if encoded == "" {
+		if _, ok := queryMarshaler(value); ok {
+			return
+		}
+		switch value.Kind() {
+		case reflect.Map, reflect.Slice, reflect.Array:
+			return
+		}
+	}
+	if existing := urlValues.Get(key); existing != "" && encoded != "" {
+		urlValues.Set(key, existing+","+encoded)
+		return
 	}
+	urlValues.Add(key, encoded)
 }
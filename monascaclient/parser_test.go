@@ -15,6 +15,8 @@
 package monascaclient
 
 import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
 	"net/url"
 	"reflect"
 	"testing"
@@ -31,13 +33,26 @@ type TestStruct struct {
 func TestStructConversionEmptyStruct(t *testing.T) {
 	testStructInput := TestStruct{}
 	urlValuesExpected := url.Values{}
-	urlValuesReturned := convertStructToQueryParameters(&testStructInput)
+	urlValuesReturned, err := convertStructToQueryParameters(&testStructInput)
+	if err != nil {
+		t.Fatalf("Error %s converting struct", err)
+	}
 	if !reflect.DeepEqual(urlValuesExpected, urlValuesReturned) {
 		t.Errorf("URL Values %s returned from method do not match expect values %s ", urlValuesReturned,
 			urlValuesExpected)
 	}
 }
 
+func TestStructConversionNil(t *testing.T) {
+	urlValuesReturned, err := convertStructToQueryParameters(nil)
+	if err != nil {
+		t.Fatalf("Error %s converting struct", err)
+	}
+	if len(urlValuesReturned) != 0 {
+		t.Errorf("URL Values %s returned from method for nil input, expected none", urlValuesReturned)
+	}
+}
+
 func TestStructConversion(t *testing.T) {
 	inputString := "inputstring"
 	inputTime := time.Now()
@@ -50,7 +65,10 @@ func TestStructConversion(t *testing.T) {
 	urlValuesExpected.Add("test_time", inputTime.UTC().Format(timeFormat))
 	urlValuesExpected.Add("test_map", "key1:value1,key2:value2,key3:value3")
 	urlValuesExpected.Add("test_int", "3")
-	urlValuesReturned := convertStructToQueryParameters(&testStructInput)
+	urlValuesReturned, err := convertStructToQueryParameters(&testStructInput)
+	if err != nil {
+		t.Fatalf("Error %s converting struct", err)
+	}
 	if !reflect.DeepEqual(urlValuesExpected, urlValuesReturned) {
 		t.Errorf("URL Values %s returned from method do not match expect values %s ", urlValuesReturned,
 			urlValuesExpected)
@@ -64,9 +82,117 @@ func TestStructConversionPartiallyDefinedStruct(t *testing.T) {
 	urlValuesExpected := url.Values{}
 	urlValuesExpected.Add("test_map", "key1:value1,key2:value2,key3:value3")
 	urlValuesExpected.Add("test_int", "3")
-	urlValuesReturned := convertStructToQueryParameters(&testStructInput)
+	urlValuesReturned, err := convertStructToQueryParameters(&testStructInput)
+	if err != nil {
+		t.Fatalf("Error %s converting struct", err)
+	}
+	if !reflect.DeepEqual(urlValuesExpected, urlValuesReturned) {
+		t.Errorf("URL Values %s returned from method do not match expect values %s ", urlValuesReturned,
+			urlValuesExpected)
+	}
+}
+
+func TestStructConversionDimensionFilter(t *testing.T) {
+	dimensions := map[string]string{"service": "monitoring"}
+	filter := models.NewDimensionFilter().AnyOf("hostname", "web1", "web2").Exists("zone").Exact("url", "http://x:8080/a")
+	query := models.MetricQuery{Dimensions: &dimensions, DimensionFilter: &filter}
+	urlValuesReturned, err := convertStructToQueryParameters(&query)
+	if err != nil {
+		t.Fatalf("Error %s converting struct", err)
+	}
+	expected := `service:monitoring,hostname:web1|web2,url:http://x:8080/a,zone`
+	if urlValuesReturned.Get("dimensions") != expected {
+		t.Errorf("Expected '%v' but was '%v'", expected, urlValuesReturned.Get("dimensions"))
+	}
+	if len(urlValuesReturned["dimensions"]) != 1 {
+		t.Errorf("Expected a single dimensions parameter but was %v", urlValuesReturned["dimensions"])
+	}
+
+	for _, invalid := range []map[string]string{{"path": "a,b"}, {"hostname": "web|2"}, {"a:b": "c"}} {
+		query = models.MetricQuery{Dimensions: &invalid}
+		if _, err = convertStructToQueryParameters(&query); err == nil {
+			t.Errorf("Expected error for dimensions %v", invalid)
+		}
+	}
+}
+
+type embeddedTestStruct struct {
+	TestString *string `queryParameter:"test_string"`
+}
+
+type testPeriod int
+
+func (p testPeriod) MarshalQuery() (string, error) {
+	return fmt.Sprintf("%dm", int(p)), nil
+}
+
+type RichTestStruct struct {
+	*embeddedTestStruct
+	models.DimensionNameQuery
+	GroupBy  []string      `queryParameter:"group_by"`
+	SortBy   []string      `queryParameter:"sort_by,omitempty"`
+	Count    int64         `queryParameter:"count"`
+	Ratio    float64       `queryParameter:"ratio,omitempty"`
+	Period   time.Duration `queryParameter:"period"`
+	Window   testPeriod    `queryParameter:"window"`
+	Skipped  string        `queryParameter:"-"`
+	Untagged string
+}
+
+func TestStructConversionRichStruct(t *testing.T) {
+	inputString := "inputstring"
+	tenantID := "tenant"
+	limit := 10
+	input := RichTestStruct{
+		embeddedTestStruct: &embeddedTestStruct{TestString: &inputString},
+		DimensionNameQuery: models.DimensionNameQuery{TenantID: &tenantID, Limit: &limit},
+		GroupBy:            []string{"hostname", "service"},
+		Count:              1 << 40,
+		Period:             5 * time.Minute,
+		Window:             3,
+		Skipped:            "skipped",
+		Untagged:           "untagged",
+	}
+	urlValuesExpected := url.Values{}
+	urlValuesExpected.Add("test_string", "inputstring")
+	urlValuesExpected.Add("tenant_id", "tenant")
+	urlValuesExpected.Add("limit", "10")
+	urlValuesExpected.Add("group_by", "hostname,service")
+	urlValuesExpected.Add("count", "1099511627776")
+	urlValuesExpected.Add("period", "300")
+	urlValuesExpected.Add("window", "3m")
+	urlValuesReturned, err := convertStructToQueryParameters(&input)
+	if err != nil {
+		t.Fatalf("Error %s converting struct", err)
+	}
 	if !reflect.DeepEqual(urlValuesExpected, urlValuesReturned) {
 		t.Errorf("URL Values %s returned from method do not match expect values %s ", urlValuesReturned,
 			urlValuesExpected)
 	}
 }
+
+func TestStructConversionDimensionValueQuery(t *testing.T) {
+	dimensionName := "hostname"
+	metricName := "cpu.idle_perc"
+	query := models.DimensionValueQuery{DimensionName: &dimensionName}
+	query.Name = &metricName
+	urlValuesReturned, err := convertStructToQueryParameters(&query)
+	if err != nil {
+		t.Fatalf("Error %s converting struct", err)
+	}
+	if urlValuesReturned.Get("metric_name") != metricName || urlValuesReturned.Get("dimension_name") != dimensionName {
+		t.Errorf("Expected embedded metric_name to be encoded but was %v", urlValuesReturned)
+	}
+}
+
+func TestStructConversionUnsupportedType(t *testing.T) {
+	input := struct {
+		Channel chan int `queryParameter:"channel"`
+	}{Channel: make(chan int)}
+	if _, err := convertStructToQueryParameters(&input); err == nil {
+		t.Errorf("Expected error for unsupported type")
+	}
+	if _, err := convertStructToQueryParameters("not a struct"); err == nil {
+		t.Errorf("Expected error for non struct query")
+	}
+}
@@ -0,0 +1,761 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"strings"
+	"time"
+)
+
+// Statistic is a statistic computed by GetStatistics.
+type Statistic string
+
+const (
+	Avg   Statistic = "avg"
+	Min   Statistic = "min"
+	Max   Statistic = "max"
+	Sum   Statistic = "sum"
+	Count Statistic = "count"
+)
+
+var (
+	alarmStates     = []string{"OK", "ALARM", "UNDETERMINED"}
+	alarmSeverities = []string{"LOW", "MEDIUM", "HIGH", "CRITICAL"}
+	lifecycleStates = []string{"OPEN", "ACKNOWLEDGED", "RESOLVED"}
+)
+
+// The query builders below fill the pointer fields of the models query
+// structs. Each setter returns the builder so calls can be chained; the first
+// invalid argument is remembered and reported by Build, which also checks
+// combinations the API would reject.
+
+type queryBuilder struct {
+	err error
+}
+
+func (b *queryBuilder) fail(format string, args ...interface{}) {
+	if b.err == nil {
+		b.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (b *queryBuilder) addDimension(dimensions **map[string]string, key string, value string) {
+	if key == "" {
+		b.fail("Dimension name must not be empty")
+		return
+	}
+	if *dimensions == nil {
+		*dimensions = &map[string]string{}
+	}
+	(**dimensions)[key] = value
+}
+
+func (b *queryBuilder) addDimensionFilter(filter **models.DimensionFilter, key string, values []string) {
+	if key == "" {
+		b.fail("Dimension name must not be empty")
+		return
+	}
+	if *filter == nil {
+		created := models.NewDimensionFilter()
+		*filter = &created
+	}
+	if len(values) == 0 {
+		(*filter).Exists(key)
+	} else {
+		(*filter).AnyOf(key, values...)
+	}
+}
+
+func (b *queryBuilder) setTimeRange(startTime **time.Time, endTime **time.Time, start time.Time, end time.Time) {
+	if !end.IsZero() && end.Before(start) {
+		b.fail("End time %s is before start time %s", end.Format(time.RFC3339), start.Format(time.RFC3339))
+		return
+	}
+	*startTime = &start
+	if !end.IsZero() {
+		*endTime = &end
+	}
+}
+
+func (b *queryBuilder) setLimit(limit **int, value int) {
+	if value <= 0 {
+		b.fail("Limit must be positive but was %d", value)
+		return
+	}
+	*limit = &value
+}
+
+func (b *queryBuilder) oneOf(field string, value string, allowed []string) string {
+	upper := strings.ToUpper(value)
+	for _, candidate := range allowed {
+		if upper == candidate {
+			return upper
+		}
+	}
+	b.fail("Invalid %s %q, expected one of %s", field, value, strings.Join(allowed, ", "))
+	return ""
+}
+
+func (b *queryBuilder) severities(severities []string) *string {
+	if len(severities) == 0 {
+		b.fail("Severity needs at least one value")
+		return nil
+	}
+	valid := make([]string, 0, len(severities))
+	for _, severity := range severities {
+		if severity = b.oneOf("severity", severity, alarmSeverities); severity != "" {
+			valid = append(valid, severity)
+		}
+	}
+	return stringPointer(strings.Join(valid, "|"))
+}
+
+func copyDimensions(dimensions *map[string]string) *map[string]string {
+	if dimensions == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(*dimensions))
+	for key, value := range *dimensions {
+		copied[key] = value
+	}
+	return &copied
+}
+
+func copyDimensionFilter(filter *models.DimensionFilter) *models.DimensionFilter {
+	if filter == nil {
+		return nil
+	}
+	copied := filter.Copy()
+	return &copied
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+type MetricQueryBuilder struct {
+	queryBuilder
+	query models.MetricQuery
+}
+
+// NewMetricQuery lists the metrics with the given name, or all metrics when
+// the name is empty.
+func NewMetricQuery(name string) *MetricQueryBuilder {
+	builder := &MetricQueryBuilder{}
+	if name != "" {
+		builder.query.Name = &name
+	}
+	return builder
+}
+
+func (b *MetricQueryBuilder) Tenant(tenantID string) *MetricQueryBuilder {
+	b.query.TenantID = &tenantID
+	return b
+}
+
+func (b *MetricQueryBuilder) Dimension(key string, value string) *MetricQueryBuilder {
+	b.addDimension(&b.query.Dimensions, key, value)
+	return b
+}
+
+// DimensionAnyOf matches any of the values of the dimension.
+func (b *MetricQueryBuilder) DimensionAnyOf(key string, values ...string) *MetricQueryBuilder {
+	if len(values) == 0 {
+		b.fail("Dimension %s needs at least one value", key)
+		return b
+	}
+	b.addDimensionFilter(&b.query.DimensionFilter, key, values)
+	return b
+}
+
+// DimensionExists matches any value of the dimension.
+func (b *MetricQueryBuilder) DimensionExists(key string) *MetricQueryBuilder {
+	b.addDimensionFilter(&b.query.DimensionFilter, key, nil)
+	return b
+}
+
+// Between limits the query to metrics with measurements in the range. A zero
+// end leaves the range open.
+func (b *MetricQueryBuilder) Between(start time.Time, end time.Time) *MetricQueryBuilder {
+	b.setTimeRange(&b.query.StartTime, &b.query.EndTime, start, end)
+	return b
+}
+
+func (b *MetricQueryBuilder) Limit(limit int) *MetricQueryBuilder {
+	b.setLimit(&b.query.Limit, limit)
+	return b
+}
+
+func (b *MetricQueryBuilder) Offset(offset int) *MetricQueryBuilder {
+	b.query.Offset = &offset
+	return b
+}
+
+func (b *MetricQueryBuilder) Build() (*models.MetricQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	query := b.query
+	query.Dimensions = copyDimensions(b.query.Dimensions)
+	query.DimensionFilter = copyDimensionFilter(b.query.DimensionFilter)
+	return &query, nil
+}
+
+type MetricNameQueryBuilder struct {
+	queryBuilder
+	query models.MetricNameQuery
+}
+
+func NewMetricNameQuery() *MetricNameQueryBuilder {
+	return &MetricNameQueryBuilder{}
+}
+
+func (b *MetricNameQueryBuilder) Tenant(tenantID string) *MetricNameQueryBuilder {
+	b.query.TenantID = &tenantID
+	return b
+}
+
+func (b *MetricNameQueryBuilder) Dimension(key string, value string) *MetricNameQueryBuilder {
+	b.addDimension(&b.query.Dimensions, key, value)
+	return b
+}
+
+// DimensionAnyOf matches any of the values of the dimension.
+func (b *MetricNameQueryBuilder) DimensionAnyOf(key string, values ...string) *MetricNameQueryBuilder {
+	if len(values) == 0 {
+		b.fail("Dimension %s needs at least one value", key)
+		return b
+	}
+	b.addDimensionFilter(&b.query.DimensionFilter, key, values)
+	return b
+}
+
+// DimensionExists matches any value of the dimension.
+func (b *MetricNameQueryBuilder) DimensionExists(key string) *MetricNameQueryBuilder {
+	b.addDimensionFilter(&b.query.DimensionFilter, key, nil)
+	return b
+}
+
+func (b *MetricNameQueryBuilder) Limit(limit int) *MetricNameQueryBuilder {
+	b.setLimit(&b.query.Limit, limit)
+	return b
+}
+
+func (b *MetricNameQueryBuilder) Offset(offset string) *MetricNameQueryBuilder {
+	b.query.Offset = &offset
+	return b
+}
+
+func (b *MetricNameQueryBuilder) Build() (*models.MetricNameQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	query := b.query
+	query.Dimensions = copyDimensions(b.query.Dimensions)
+	query.DimensionFilter = copyDimensionFilter(b.query.DimensionFilter)
+	return &query, nil
+}
+
+type DimensionNameQueryBuilder struct {
+	queryBuilder
+	query models.DimensionNameQuery
+}
+
+func NewDimensionNameQuery() *DimensionNameQueryBuilder {
+	return &DimensionNameQueryBuilder{}
+}
+
+func (b *DimensionNameQueryBuilder) Tenant(tenantID string) *DimensionNameQueryBuilder {
+	b.query.TenantID = &tenantID
+	return b
+}
+
+// Metric limits the dimension names to those of one metric.
+func (b *DimensionNameQueryBuilder) Metric(name string) *DimensionNameQueryBuilder {
+	b.query.Name = &name
+	return b
+}
+
+func (b *DimensionNameQueryBuilder) Limit(limit int) *DimensionNameQueryBuilder {
+	b.setLimit(&b.query.Limit, limit)
+	return b
+}
+
+func (b *DimensionNameQueryBuilder) Offset(offset int) *DimensionNameQueryBuilder {
+	b.query.Offset = &offset
+	return b
+}
+
+func (b *DimensionNameQueryBuilder) Build() (*models.DimensionNameQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	query := b.query
+	return &query, nil
+}
+
+type DimensionValueQueryBuilder struct {
+	queryBuilder
+	query models.DimensionValueQuery
+}
+
+func NewDimensionValueQuery(dimensionName string) *DimensionValueQueryBuilder {
+	return &DimensionValueQueryBuilder{query: models.DimensionValueQuery{DimensionName: &dimensionName}}
+}
+
+func (b *DimensionValueQueryBuilder) Tenant(tenantID string) *DimensionValueQueryBuilder {
+	b.query.TenantID = &tenantID
+	return b
+}
+
+// Metric limits the dimension values to those of one metric.
+func (b *DimensionValueQueryBuilder) Metric(name string) *DimensionValueQueryBuilder {
+	b.query.Name = &name
+	return b
+}
+
+func (b *DimensionValueQueryBuilder) Limit(limit int) *DimensionValueQueryBuilder {
+	b.setLimit(&b.query.Limit, limit)
+	return b
+}
+
+func (b *DimensionValueQueryBuilder) Offset(offset int) *DimensionValueQueryBuilder {
+	b.query.Offset = &offset
+	return b
+}
+
+func (b *DimensionValueQueryBuilder) Build() (*models.DimensionValueQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if *b.query.DimensionName == "" {
+		return nil, fmt.Errorf("Dimension value query requires a dimension name")
+	}
+	query := b.query
+	return &query, nil
+}
+
+type MeasurementQueryBuilder struct {
+	queryBuilder
+	query models.MeasurementQuery
+}
+
+func NewMeasurementQuery(name string) *MeasurementQueryBuilder {
+	return &MeasurementQueryBuilder{query: models.MeasurementQuery{Name: &name}}
+}
+
+func (b *MeasurementQueryBuilder) Tenant(tenantID string) *MeasurementQueryBuilder {
+	b.query.TenantID = &tenantID
+	return b
+}
+
+func (b *MeasurementQueryBuilder) Dimension(key string, value string) *MeasurementQueryBuilder {
+	b.addDimension(&b.query.Dimensions, key, value)
+	return b
+}
+
+// DimensionAnyOf matches any of the values of the dimension.
+func (b *MeasurementQueryBuilder) DimensionAnyOf(key string, values ...string) *MeasurementQueryBuilder {
+	if len(values) == 0 {
+		b.fail("Dimension %s needs at least one value", key)
+		return b
+	}
+	b.addDimensionFilter(&b.query.DimensionFilter, key, values)
+	return b
+}
+
+// DimensionExists matches any value of the dimension.
+func (b *MeasurementQueryBuilder) DimensionExists(key string) *MeasurementQueryBuilder {
+	b.addDimensionFilter(&b.query.DimensionFilter, key, nil)
+	return b
+}
+
+// Between sets the time range; a zero end leaves the range open.
+func (b *MeasurementQueryBuilder) Between(start time.Time, end time.Time) *MeasurementQueryBuilder {
+	b.setTimeRange(&b.query.StartTime, &b.query.EndTime, start, end)
+	return b
+}
+
+// Since is Between(start, time.Time{}).
+func (b *MeasurementQueryBuilder) Since(start time.Time) *MeasurementQueryBuilder {
+	return b.Between(start, time.Time{})
+}
+
+// Merge merges the measurements of all matching metrics into one series.
+func (b *MeasurementQueryBuilder) Merge() *MeasurementQueryBuilder {
+	merge := true
+	b.query.Merge = &merge
+	return b
+}
+
+// GroupBy returns one series per combination of the given dimensions, "*"
+// for every distinct metric.
+func (b *MeasurementQueryBuilder) GroupBy(dimensions ...string) *MeasurementQueryBuilder {
+	b.query.GroupBy = stringPointer(strings.Join(dimensions, ","))
+	return b
+}
+
+func (b *MeasurementQueryBuilder) Limit(limit int) *MeasurementQueryBuilder {
+	b.setLimit(&b.query.Limit, limit)
+	return b
+}
+
+func (b *MeasurementQueryBuilder) Offset(offset int) *MeasurementQueryBuilder {
+	b.query.Offset = &offset
+	return b
+}
+
+func (b *MeasurementQueryBuilder) Build() (*models.MeasurementQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := validateSeriesQuery("Measurement", b.query.Name, b.query.StartTime, b.query.Merge, b.query.GroupBy); err != nil {
+		return nil, err
+	}
+	query := b.query
+	query.Dimensions = copyDimensions(b.query.Dimensions)
+	query.DimensionFilter = copyDimensionFilter(b.query.DimensionFilter)
+	return &query, nil
+}
+
+type StatisticQueryBuilder struct {
+	queryBuilder
+	query models.StatisticQuery
+}
+
+func NewStatisticQuery(name string) *StatisticQueryBuilder {
+	return &StatisticQueryBuilder{query: models.StatisticQuery{Name: &name}}
+}
+
+func (b *StatisticQueryBuilder) Tenant(tenantID string) *StatisticQueryBuilder {
+	b.query.TenantID = &tenantID
+	return b
+}
+
+func (b *StatisticQueryBuilder) Dimension(key string, value string) *StatisticQueryBuilder {
+	b.addDimension(&b.query.Dimensions, key, value)
+	return b
+}
+
+// DimensionAnyOf matches any of the values of the dimension.
+func (b *StatisticQueryBuilder) DimensionAnyOf(key string, values ...string) *StatisticQueryBuilder {
+	if len(values) == 0 {
+		b.fail("Dimension %s needs at least one value", key)
+		return b
+	}
+	b.addDimensionFilter(&b.query.DimensionFilter, key, values)
+	return b
+}
+
+// DimensionExists matches any value of the dimension.
+func (b *StatisticQueryBuilder) DimensionExists(key string) *StatisticQueryBuilder {
+	b.addDimensionFilter(&b.query.DimensionFilter, key, nil)
+	return b
+}
+
+func (b *StatisticQueryBuilder) Stats(statistics ...Statistic) *StatisticQueryBuilder {
+	names := make([]string, 0, len(statistics))
+	for _, statistic := range statistics {
+		switch statistic {
+		case Avg, Min, Max, Sum, Count:
+			names = append(names, string(statistic))
+		default:
+			b.fail("Invalid statistic %q", statistic)
+		}
+	}
+	b.query.Statistics = stringPointer(strings.Join(names, ","))
+	return b
+}
+
+// Period sets the length of each statistics period, in whole seconds.
+func (b *StatisticQueryBuilder) Period(period time.Duration) *StatisticQueryBuilder {
+	if period < time.Second || period%time.Second != 0 {
+		b.fail("Period must be a positive number of seconds but was %s", period)
+		return b
+	}
+	seconds := int(period / time.Second)
+	b.query.Period = &seconds
+	return b
+}
+
+// Between sets the time range; a zero end leaves the range open.
+func (b *StatisticQueryBuilder) Between(start time.Time, end time.Time) *StatisticQueryBuilder {
+	b.setTimeRange(&b.query.StartTime, &b.query.EndTime, start, end)
+	return b
+}
+
+// Since is Between(start, time.Time{}).
+func (b *StatisticQueryBuilder) Since(start time.Time) *StatisticQueryBuilder {
+	return b.Between(start, time.Time{})
+}
+
+// Merge merges the measurements of all matching metrics before computing the
+// statistics.
+func (b *StatisticQueryBuilder) Merge() *StatisticQueryBuilder {
+	merge := true
+	b.query.Merge = &merge
+	return b
+}
+
+// GroupBy returns one series per combination of the given dimensions, "*"
+// for every distinct metric.
+func (b *StatisticQueryBuilder) GroupBy(dimensions ...string) *StatisticQueryBuilder {
+	b.query.GroupBy = stringPointer(strings.Join(dimensions, ","))
+	return b
+}
+
+func (b *StatisticQueryBuilder) Limit(limit int) *StatisticQueryBuilder {
+	b.setLimit(&b.query.Limit, limit)
+	return b
+}
+
+func (b *StatisticQueryBuilder) Offset(offset int) *StatisticQueryBuilder {
+	b.query.Offset = &offset
+	return b
+}
+
+func (b *StatisticQueryBuilder) Build() (*models.StatisticQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := validateSeriesQuery("Statistics", b.query.Name, b.query.StartTime, b.query.Merge, b.query.GroupBy); err != nil {
+		return nil, err
+	}
+	if b.query.Statistics == nil || *b.query.Statistics == "" {
+		return nil, fmt.Errorf("Statistics query requires at least one statistic")
+	}
+	if b.query.Period == nil {
+		return nil, fmt.Errorf("Statistics query requires a period")
+	}
+	query := b.query
+	query.Dimensions = copyDimensions(b.query.Dimensions)
+	query.DimensionFilter = copyDimensionFilter(b.query.DimensionFilter)
+	return &query, nil
+}
+
+func validateSeriesQuery(kind string, name *string, startTime *time.Time, merge *bool, groupBy *string) error {
+	if name == nil || *name == "" {
+		return fmt.Errorf("%s query requires a metric name", kind)
+	}
+	if startTime == nil {
+		return fmt.Errorf("%s query requires a start time", kind)
+	}
+	if merge != nil && *merge && groupBy != nil {
+		return fmt.Errorf("%s query can not both merge and group by", kind)
+	}
+	if groupBy != nil && *groupBy == "" {
+		return fmt.Errorf("%s query group by needs at least one dimension", kind)
+	}
+	return nil
+}
+
+type AlarmQueryBuilder struct {
+	queryBuilder
+	query models.AlarmQuery
+}
+
+func NewAlarmQuery() *AlarmQueryBuilder {
+	return &AlarmQueryBuilder{}
+}
+
+func (b *AlarmQueryBuilder) Definition(alarmDefinitionID string) *AlarmQueryBuilder {
+	b.query.AlarmDefinitionID = &alarmDefinitionID
+	return b
+}
+
+func (b *AlarmQueryBuilder) Metric(name string) *AlarmQueryBuilder {
+	b.query.MetricName = &name
+	return b
+}
+
+func (b *AlarmQueryBuilder) MetricDimension(key string, value string) *AlarmQueryBuilder {
+	b.addDimension(&b.query.MetricDimensions, key, value)
+	return b
+}
+
+// MetricDimensionAnyOf matches any of the values of the dimension.
+func (b *AlarmQueryBuilder) MetricDimensionAnyOf(key string, values ...string) *AlarmQueryBuilder {
+	if len(values) == 0 {
+		b.fail("Dimension %s needs at least one value", key)
+		return b
+	}
+	b.addDimensionFilter(&b.query.MetricDimensionFilter, key, values)
+	return b
+}
+
+// MetricDimensionExists matches any value of the dimension.
+func (b *AlarmQueryBuilder) MetricDimensionExists(key string) *AlarmQueryBuilder {
+	b.addDimensionFilter(&b.query.MetricDimensionFilter, key, nil)
+	return b
+}
+
+func (b *AlarmQueryBuilder) State(state string) *AlarmQueryBuilder {
+	if state = b.oneOf("alarm state", state, alarmStates); state != "" {
+		b.query.State = &state
+	}
+	return b
+}
+
+// Severity matches alarms of any of the given severities.
+func (b *AlarmQueryBuilder) Severity(severities ...string) *AlarmQueryBuilder {
+	b.query.Severity = b.severities(severities)
+	return b
+}
+
+func (b *AlarmQueryBuilder) LifecycleState(lifecycleState string) *AlarmQueryBuilder {
+	if lifecycleState = b.oneOf("lifecycle state", lifecycleState, lifecycleStates); lifecycleState != "" {
+		b.query.LifecycleState = &lifecycleState
+	}
+	return b
+}
+
+func (b *AlarmQueryBuilder) Link(link string) *AlarmQueryBuilder {
+	b.query.Link = &link
+	return b
+}
+
+func (b *AlarmQueryBuilder) StateUpdatedSince(start time.Time) *AlarmQueryBuilder {
+	b.query.StateUpdatedStartTime = &start
+	return b
+}
+
+// SortBy sorts by the given fields, each optionally followed by " asc" or
+// " desc".
+func (b *AlarmQueryBuilder) SortBy(fields ...string) *AlarmQueryBuilder {
+	b.query.SortBy = stringPointer(strings.Join(fields, ","))
+	return b
+}
+
+func (b *AlarmQueryBuilder) Limit(limit int) *AlarmQueryBuilder {
+	b.setLimit(&b.query.Limit, limit)
+	return b
+}
+
+func (b *AlarmQueryBuilder) Offset(offset int) *AlarmQueryBuilder {
+	b.query.Offset = &offset
+	return b
+}
+
+func (b *AlarmQueryBuilder) Build() (*models.AlarmQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	query := b.query
+	query.MetricDimensions = copyDimensions(b.query.MetricDimensions)
+	query.MetricDimensionFilter = copyDimensionFilter(b.query.MetricDimensionFilter)
+	return &query, nil
+}
+
+type AlarmDefinitionQueryBuilder struct {
+	queryBuilder
+	query models.AlarmDefinitionQuery
+}
+
+func NewAlarmDefinitionQuery() *AlarmDefinitionQueryBuilder {
+	return &AlarmDefinitionQueryBuilder{}
+}
+
+func (b *AlarmDefinitionQueryBuilder) Name(name string) *AlarmDefinitionQueryBuilder {
+	b.query.Name = &name
+	return b
+}
+
+func (b *AlarmDefinitionQueryBuilder) Dimension(key string, value string) *AlarmDefinitionQueryBuilder {
+	b.addDimension(&b.query.Dimensions, key, value)
+	return b
+}
+
+// DimensionAnyOf matches any of the values of the dimension.
+func (b *AlarmDefinitionQueryBuilder) DimensionAnyOf(key string, values ...string) *AlarmDefinitionQueryBuilder {
+	if len(values) == 0 {
+		b.fail("Dimension %s needs at least one value", key)
+		return b
+	}
+	b.addDimensionFilter(&b.query.DimensionFilter, key, values)
+	return b
+}
+
+// DimensionExists matches any value of the dimension.
+func (b *AlarmDefinitionQueryBuilder) DimensionExists(key string) *AlarmDefinitionQueryBuilder {
+	b.addDimensionFilter(&b.query.DimensionFilter, key, nil)
+	return b
+}
+
+// Severity matches definitions of any of the given severities.
+func (b *AlarmDefinitionQueryBuilder) Severity(severities ...string) *AlarmDefinitionQueryBuilder {
+	b.query.Severity = b.severities(severities)
+	return b
+}
+
+// SortBy sorts by the given fields, each optionally followed by " asc" or
+// " desc".
+func (b *AlarmDefinitionQueryBuilder) SortBy(fields ...string) *AlarmDefinitionQueryBuilder {
+	b.query.SortBy = stringPointer(strings.Join(fields, ","))
+	return b
+}
+
+func (b *AlarmDefinitionQueryBuilder) Limit(limit int) *AlarmDefinitionQueryBuilder {
+	b.setLimit(&b.query.Limit, limit)
+	return b
+}
+
+func (b *AlarmDefinitionQueryBuilder) Offset(offset int) *AlarmDefinitionQueryBuilder {
+	b.query.Offset = &offset
+	return b
+}
+
+func (b *AlarmDefinitionQueryBuilder) Build() (*models.AlarmDefinitionQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	query := b.query
+	query.Dimensions = copyDimensions(b.query.Dimensions)
+	query.DimensionFilter = copyDimensionFilter(b.query.DimensionFilter)
+	return &query, nil
+}
+
+type NotificationQueryBuilder struct {
+	queryBuilder
+	query models.NotificationQuery
+}
+
+func NewNotificationQuery() *NotificationQueryBuilder {
+	return &NotificationQueryBuilder{}
+}
+
+func (b *NotificationQueryBuilder) SortBy(fields ...string) *NotificationQueryBuilder {
+	b.query.SortBy = stringPointer(strings.Join(fields, ","))
+	return b
+}
+
+func (b *NotificationQueryBuilder) Limit(limit int) *NotificationQueryBuilder {
+	b.setLimit(&b.query.Limit, limit)
+	return b
+}
+
+func (b *NotificationQueryBuilder) Offset(offset string) *NotificationQueryBuilder {
+	b.query.Offset = &offset
+	return b
+}
+
+func (b *NotificationQueryBuilder) Build() (*models.NotificationQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	query := b.query
+	return &query, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatisticQueryBuilder(t *testing.T) {
+	start := time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	query, err := NewStatisticQuery("cpu.idle_perc").
+		Dimension("hostname", "web1").
+		Stats(Avg, Max).
+		Period(5*time.Minute).
+		Between(start, end).
+		GroupBy("hostname").
+		Build()
+	if err != nil {
+		t.Fatalf("Error %s building query", err)
+	}
+
+	values, err := convertStructToQueryParameters(query)
+	if err != nil {
+		t.Fatalf("Error %s converting struct", err)
+	}
+	expected := map[string]string{
+		"name":       "cpu.idle_perc",
+		"dimensions": "hostname:web1",
+		"statistics": "avg,max",
+		"period":     "300",
+		"start_time": "2017-02-27T06:00:00Z",
+		"end_time":   "2017-02-27T08:00:00Z",
+		"group_by":   "hostname",
+	}
+	for key, value := range expected {
+		if values.Get(key) != value {
+			t.Errorf("Expected %s to be '%v' but was '%v'", key, value, values.Get(key))
+		}
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %d parameters but was %v", len(expected), values)
+	}
+}
+
+func TestStatisticQueryBuilderValidation(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	builders := map[string]*StatisticQueryBuilder{
+		"no period":      NewStatisticQuery("cpu").Stats(Avg).Since(start),
+		"no statistics":  NewStatisticQuery("cpu").Period(time.Minute).Since(start),
+		"no start":       NewStatisticQuery("cpu").Stats(Avg).Period(time.Minute),
+		"no name":        NewStatisticQuery("").Stats(Avg).Period(time.Minute).Since(start),
+		"bad statistic":  NewStatisticQuery("cpu").Stats("median").Period(time.Minute).Since(start),
+		"partial period": NewStatisticQuery("cpu").Stats(Avg).Period(1500 * time.Millisecond).Since(start),
+		"reversed range": NewStatisticQuery("cpu").Stats(Avg).Period(time.Minute).Between(start, start.Add(-time.Minute)),
+		"merge and group": NewStatisticQuery("cpu").Stats(Avg).Period(time.Minute).Since(start).
+			Merge().GroupBy("*"),
+	}
+	for name, builder := range builders {
+		if _, err := builder.Build(); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+func TestMeasurementQueryBuilderCopiesDimensions(t *testing.T) {
+	builder := NewMeasurementQuery("cpu").Since(time.Now()).Dimension("hostname", "web1")
+	query, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Error %s building query", err)
+	}
+	builder.Dimension("hostname", "web2")
+	if (*query.Dimensions)["hostname"] != "web1" {
+		t.Errorf("Expected built query to keep hostname web1 but was %v", *query.Dimensions)
+	}
+	if query.EndTime != nil {
+		t.Errorf("Expected open time range but was %v", *query.EndTime)
+	}
+}
+
+func TestAlarmQueryBuilder(t *testing.T) {
+	query, err := NewAlarmQuery().State("alarm").Severity("high", "CRITICAL").MetricDimension("hostname", "web1").Build()
+	if err != nil {
+		t.Fatalf("Error %s building query", err)
+	}
+	if *query.State != "ALARM" || *query.Severity != "HIGH|CRITICAL" {
+		t.Errorf("Expected ALARM and HIGH|CRITICAL but was %s and %s", *query.State, *query.Severity)
+	}
+	if _, err := NewAlarmQuery().State("BROKEN").Build(); err == nil {
+		t.Errorf("Expected error for invalid state")
+	}
+	if _, err := NewAlarmDefinitionQuery().Severity("urgent").Build(); err == nil {
+		t.Errorf("Expected error for invalid severity")
+	}
+	if _, err := NewDimensionValueQuery("").Build(); err == nil {
+		t.Errorf("Expected error for missing dimension name")
+	}
+	if _, err := NewMetricQuery("").Limit(0).Build(); err == nil {
+		t.Errorf("Expected error for zero limit")
+	}
+}
+
+func TestQueryBuilderDimensionFilter(t *testing.T) {
+	query, err := NewMeasurementQuery("cpu").Since(time.Now()).
+		Dimension("service", "web").
+		DimensionAnyOf("hostname", "web1", "web2").
+		DimensionExists("zone").
+		Build()
+	if err != nil {
+		t.Fatalf("Error %s building query", err)
+	}
+	expected := "service:web,hostname:web1|web2,zone"
+	values, err := convertStructToQueryParameters(query)
+	if err != nil {
+		t.Fatalf("Error %s converting struct", err)
+	}
+	if dimensions := values.Get("dimensions"); dimensions != expected {
+		t.Errorf("Expected '%v' but was '%v'", expected, dimensions)
+	}
+	if _, err := NewAlarmQuery().MetricDimensionAnyOf("hostname").Build(); err == nil {
+		t.Errorf("Expected error for any-of without values")
+	}
+}
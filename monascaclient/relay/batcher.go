@@ -0,0 +1,97 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package relay holds what the statsd, ingest and remotewrite packages share:
+// sending metrics to Monasca in batches and reading lines and packets from TCP
+// and UDP.
+package relay
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"sync"
+)
+
+const defaultBatchSize = 1000
+
+// MetricSender posts batches of metrics; a *monascaclient.Client satisfies it.
+type MetricSender interface {
+	CreateMetrics(tenantID *string, metricRequestBodies []models.MetricRequestBody) error
+}
+
+// Batcher sends metrics in batches of at most the batch size, either right
+// away or once enough of them are buffered.
+type Batcher struct {
+	sender    MetricSender
+	tenantID  *string
+	batchSize int
+
+	lock    sync.Mutex
+	pending []models.MetricRequestBody
+}
+
+func NewBatcher(sender MetricSender) *Batcher {
+	return &Batcher{
+		sender:    sender,
+		batchSize: defaultBatchSize,
+	}
+}
+
+// SetTenantID publishes the metrics on behalf of another tenant.
+func (b *Batcher) SetTenantID(tenantID *string) {
+	b.tenantID = tenantID
+}
+
+func (b *Batcher) SetBatchSize(batchSize int) {
+	b.batchSize = batchSize
+}
+
+// Buffer queues metrics and sends the queue once it holds a full batch.
+func (b *Batcher) Buffer(metrics []models.MetricRequestBody) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	b.lock.Lock()
+	b.pending = append(b.pending, metrics...)
+	var full []models.MetricRequestBody
+	if len(b.pending) >= b.batchSize {
+		full, b.pending = b.pending, nil
+	}
+	b.lock.Unlock()
+	return b.Send(full)
+}
+
+// Flush sends the buffered metrics.
+func (b *Batcher) Flush() error {
+	b.lock.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.lock.Unlock()
+	return b.Send(pending)
+}
+
+// Send publishes metrics right away, stopping at the first batch that fails.
+func (b *Batcher) Send(metrics []models.MetricRequestBody) error {
+	for start := 0; start < len(metrics); start += b.batchSize {
+		end := start + b.batchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		err := b.sender.CreateMetrics(b.tenantID, metrics[start:end])
+		if err != nil {
+			return fmt.Errorf("Failed to publish %d metrics: %v", end-start, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package relay
+
+import (
+	"context"
+	"errors"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSender struct {
+	lock    sync.Mutex
+	batches [][]models.MetricRequestBody
+	err     error
+}
+
+func (r *recordingSender) CreateMetrics(tenantID *string, metricRequestBodies []models.MetricRequestBody) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.batches = append(r.batches, metricRequestBodies)
+	return r.err
+}
+
+func metrics(count int) []models.MetricRequestBody {
+	name := "cpu"
+	result := make([]models.MetricRequestBody, count)
+	for i := range result {
+		result[i].Name = &name
+	}
+	return result
+}
+
+func TestBatcher(t *testing.T) {
+	sender := &recordingSender{}
+	batcher := NewBatcher(sender)
+	batcher.SetBatchSize(2)
+
+	batcher.Buffer(metrics(1))
+	if len(sender.batches) != 0 {
+		t.Errorf("Expected a partial batch to stay buffered but sent %d", len(sender.batches))
+	}
+	batcher.Buffer(metrics(2))
+	if len(sender.batches) != 2 || len(sender.batches[0]) != 2 || len(sender.batches[1]) != 1 {
+		t.Errorf("Expected batches of 2 and 1 but was %v", sender.batches)
+	}
+	batcher.Flush()
+	if len(sender.batches) != 2 {
+		t.Errorf("Expected nothing left to flush but sent %d batches", len(sender.batches))
+	}
+
+	sender.err = errors.New("unavailable")
+	err := batcher.Send(metrics(5))
+	if err == nil || !strings.Contains(err.Error(), "Failed to publish 2 metrics") || len(sender.batches) != 3 {
+		t.Errorf("Expected to stop at the first failed batch but was %v after %d batches", err, len(sender.batches))
+	}
+}
+
+func TestServeTCPReleasesClosedConnections(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on TCP: %v", err)
+	}
+	var lock sync.Mutex
+	lines := []string{}
+	server := NewServer(func(data []byte) {
+		lock.Lock()
+		defer lock.Unlock()
+		lines = append(lines, string(data))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		server.ServeTCP(ctx, listener)
+		close(served)
+	}()
+
+	count := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(lines)
+	}
+	send := func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		conn.Write([]byte("a\nb\n"))
+		conn.Close()
+	}
+	send()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && count() < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		send()
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && (count() < 42 || runtime.NumGoroutine() > before) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if count() != 42 {
+		t.Errorf("Expected 42 lines but was %d", count())
+	}
+	if runtime.NumGoroutine() > before {
+		t.Errorf("Expected closed connections to release their goroutines but went from %d to %d", before, runtime.NumGoroutine())
+	}
+
+	cancel()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected ServeTCP to return once ctx is done")
+	}
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// flakyListener fails its first Accept with a temporary error.
+type flakyListener struct {
+	net.Listener
+	failed bool
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if !l.failed {
+		l.failed = true
+		return nil, temporaryError{}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServeTCPRetriesTemporaryErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on TCP: %v", err)
+	}
+	lines := make(chan string, 1)
+	var errs []error
+	server := NewServer(func(data []byte) { lines <- string(data) })
+	server.SetErrorHandler(func(err error) { errs = append(errs, err) })
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		server.ServeTCP(ctx, &flakyListener{Listener: listener})
+		close(served)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	conn.Write([]byte("a:1|c\n"))
+	conn.Close()
+	select {
+	case line := <-lines:
+		if line != "a:1|c" {
+			t.Errorf("Expected a:1|c but was %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the server to keep accepting after a temporary error")
+	}
+	cancel()
+	<-served
+	if len(errs) != 1 {
+		t.Errorf("Expected the temporary error to be reported once but was %v", errs)
+	}
+}
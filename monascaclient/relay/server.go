@@ -0,0 +1,187 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package relay
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxPacketSize bounds UDP packets and lines read over TCP.
+	maxPacketSize = 65535
+	// maxAcceptDelay bounds the wait before accepting again after a
+	// temporary error, such as running out of file descriptors.
+	maxAcceptDelay = time.Second
+)
+
+// Server passes every UDP packet and every newline delimited line read over
+// TCP to a handler.
+type Server struct {
+	handle       func(data []byte)
+	errorHandler func(error)
+}
+
+// NewServer calls handle with each packet or line. The data is only valid
+// until handle returns.
+func NewServer(handle func(data []byte)) *Server {
+	return &Server{
+		handle:       handle,
+		errorHandler: func(error) {},
+	}
+}
+
+// SetErrorHandler receives read errors, which are otherwise dropped. Errors
+// caused by shutting down are not reported.
+func (s *Server) SetErrorHandler(errorHandler func(error)) {
+	s.errorHandler = errorHandler
+}
+
+// ListenAndServe listens on the given TCP and UDP addresses, either of which
+// may be empty, and serves until ctx is done. run, typically a flusher, is
+// called once listening succeeded; ListenAndServe returns when run has
+// returned and every connection is closed.
+func (s *Server) ListenAndServe(ctx context.Context, tcpAddress string, udpAddress string, run func(context.Context)) error {
+	var listener net.Listener
+	var packetConn net.PacketConn
+	var err error
+	if tcpAddress != "" {
+		listener, err = net.Listen("tcp", tcpAddress)
+		if err != nil {
+			return err
+		}
+	}
+	if udpAddress != "" {
+		packetConn, err = net.ListenPacket("udp", udpAddress)
+		if err != nil {
+			if listener != nil {
+				listener.Close()
+			}
+			return err
+		}
+	}
+
+	var wait sync.WaitGroup
+	if listener != nil {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			s.ServeTCP(ctx, listener)
+		}()
+	}
+	if packetConn != nil {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			s.ServeUDP(ctx, packetConn)
+		}()
+	}
+	if run != nil {
+		run(ctx)
+	}
+	wait.Wait()
+	return nil
+}
+
+// ServeTCP accepts newline delimited streams until ctx is done or accepting
+// fails permanently, and returns once every accepted connection is closed.
+// Temporary accept errors are reported and retried with a growing delay.
+func (s *Server) ServeTCP(ctx context.Context, listener net.Listener) {
+	defer closeWhenDone(ctx, listener)()
+	var connections sync.WaitGroup
+	defer connections.Wait()
+	var delay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.errorHandler(err)
+			if netErr, ok := err.(net.Error); !ok || !netErr.Temporary() {
+				return
+			}
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay *= 2; delay > maxAcceptDelay {
+				delay = maxAcceptDelay
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			continue
+		}
+		delay = 0
+		connections.Add(1)
+		go func() {
+			defer connections.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	defer closeWhenDone(ctx, conn)()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxPacketSize)
+	for scanner.Scan() {
+		s.handle(scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		s.errorHandler(err)
+	}
+}
+
+// ServeUDP reads packets until ctx is done, then closes the connection.
+func (s *Server) ServeUDP(ctx context.Context, conn net.PacketConn) {
+	defer closeWhenDone(ctx, conn)()
+	packet := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(packet)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.errorHandler(err)
+			}
+			return
+		}
+		s.handle(packet[:n])
+	}
+}
+
+// closeWhenDone closes closer once ctx is done. Calling the returned function
+// stops the watch, so that no goroutine outlives the connection.
+func closeWhenDone(ctx context.Context, closer io.Closer) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			closer.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package remotewrite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// The remote write protocol only needs a handful of messages, they are decoded
+// by hand to avoid depending on the Prometheus protobuf packages:
+//
+//   message WriteRequest { repeated TimeSeries timeseries = 1; }
+//   message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//   message Label        { string name = 1; string value = 2; }
+//   message Sample       { double value = 1; int64 timestamp = 2; }
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+type WriteRequest struct {
+	TimeSeries []TimeSeries
+}
+
+// forEachField calls handle for every field of a message. For length delimited
+// fields data holds the payload, for the others value holds the raw number.
+func forEachField(message []byte, handle func(number int, wireType int, value uint64, data []byte) error) error {
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return fmt.Errorf("Invalid field key")
+		}
+		message = message[n:]
+		number, wireType := int(key>>3), int(key&7)
+
+		var value uint64
+		var data []byte
+		switch wireType {
+		case wireVarint:
+			value, n = binary.Uvarint(message)
+			if n <= 0 {
+				return fmt.Errorf("Invalid varint in field %d", number)
+			}
+			message = message[n:]
+		case wireFixed64:
+			if len(message) < 8 {
+				return fmt.Errorf("Truncated fixed64 in field %d", number)
+			}
+			value = binary.LittleEndian.Uint64(message)
+			message = message[8:]
+		case wireFixed32:
+			if len(message) < 4 {
+				return fmt.Errorf("Truncated fixed32 in field %d", number)
+			}
+			value = uint64(binary.LittleEndian.Uint32(message))
+			message = message[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return fmt.Errorf("Truncated bytes in field %d", number)
+			}
+			data = message[n : n+int(length)]
+			message = message[n+int(length):]
+		default:
+			return fmt.Errorf("Unsupported wire type %d in field %d", wireType, number)
+		}
+
+		if err := handle(number, wireType, value, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalWriteRequest decodes a protobuf encoded, uncompressed WriteRequest.
+// Unknown fields such as metadata are skipped.
+func UnmarshalWriteRequest(data []byte) (*WriteRequest, error) {
+	request := new(WriteRequest)
+	err := forEachField(data, func(number int, wireType int, value uint64, payload []byte) error {
+		if number != 1 || wireType != wireBytes {
+			return nil
+		}
+		series, err := unmarshalTimeSeries(payload)
+		if err != nil {
+			return err
+		}
+		request.TimeSeries = append(request.TimeSeries, series)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func unmarshalTimeSeries(data []byte) (TimeSeries, error) {
+	series := TimeSeries{}
+	err := forEachField(data, func(number int, wireType int, value uint64, payload []byte) error {
+		if wireType != wireBytes {
+			return nil
+		}
+		switch number {
+		case 1:
+			label := Label{}
+			err := forEachField(payload, func(number int, wireType int, value uint64, payload []byte) error {
+				if wireType != wireBytes {
+					return nil
+				}
+				switch number {
+				case 1:
+					label.Name = string(payload)
+				case 2:
+					label.Value = string(payload)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			series.Labels = append(series.Labels, label)
+		case 2:
+			sample := Sample{}
+			err := forEachField(payload, func(number int, wireType int, value uint64, payload []byte) error {
+				switch {
+				case number == 1 && wireType == wireFixed64:
+					sample.Value = math.Float64frombits(value)
+				case number == 2 && wireType == wireVarint:
+					sample.Timestamp = int64(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			series.Samples = append(series.Samples, sample)
+		}
+		return nil
+	})
+	return series, err
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package remotewrite accepts Prometheus remote_write requests and forwards
+// the samples to Monasca.
+package remotewrite
+
+import (
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"github.com/monasca/golang-monascaclient/monascaclient/relay"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sync/atomic"
+)
+
+const (
+	defaultBatchSize   = 1000
+	defaultMaxBodySize = 32 << 20
+	metricNameLabel    = "__name__"
+)
+
+// Stats counts the samples seen by a Receiver.
+type Stats struct {
+	Received  uint64
+	Forwarded uint64
+	Dropped   uint64
+}
+
+// Receiver is an http.Handler for the remote_write endpoint. Labels become
+// dimensions, __name__ the metric name. Names and dimensions are sanitized to
+// the Monasca limits; samples that still fail validation, have no name or
+// carry a non finite value such as a staleness marker are dropped.
+type Receiver struct {
+	batcher     *relay.Batcher
+	batchSize   int
+	maxBodySize int64
+
+	received  uint64
+	forwarded uint64
+	dropped   uint64
+}
+
+func NewReceiver(sender relay.MetricSender) *Receiver {
+	return &Receiver{
+		batcher:     relay.NewBatcher(sender),
+		batchSize:   defaultBatchSize,
+		maxBodySize: defaultMaxBodySize,
+	}
+}
+
+// SetTenantID forwards the metrics on behalf of another tenant.
+func (r *Receiver) SetTenantID(tenantID *string) {
+	r.batcher.SetTenantID(tenantID)
+}
+
+func (r *Receiver) SetBatchSize(batchSize int) {
+	r.batchSize = batchSize
+	r.batcher.SetBatchSize(batchSize)
+}
+
+func (r *Receiver) SetMaxBodySize(maxBodySize int64) {
+	r.maxBodySize = maxBodySize
+}
+
+func (r *Receiver) Stats() Stats {
+	return Stats{
+		Received:  atomic.LoadUint64(&r.received),
+		Forwarded: atomic.LoadUint64(&r.forwarded),
+		Dropped:   atomic.LoadUint64(&r.dropped),
+	}
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	compressed, err := ioutil.ReadAll(io.LimitReader(req.Body, r.maxBodySize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(compressed)) > r.maxBodySize {
+		http.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid snappy payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	writeRequest, err := UnmarshalWriteRequest(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid write request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	err = r.Forward(writeRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Forward converts a decoded request and sends it in batches.
+func (r *Receiver) Forward(writeRequest *WriteRequest) error {
+	batch := make([]models.MetricRequestBody, 0, r.batchSize)
+	for _, series := range writeRequest.TimeSeries {
+		atomic.AddUint64(&r.received, uint64(len(series.Samples)))
+		metrics := ConvertTimeSeries(series)
+		atomic.AddUint64(&r.dropped, uint64(len(series.Samples)-len(metrics)))
+		for _, metric := range metrics {
+			batch = append(batch, metric)
+			if len(batch) >= r.batchSize {
+				if err := r.send(batch); err != nil {
+					return err
+				}
+				batch = make([]models.MetricRequestBody, 0, r.batchSize)
+			}
+		}
+	}
+	return r.send(batch)
+}
+
+func (r *Receiver) send(batch []models.MetricRequestBody) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := r.batcher.Send(batch); err != nil {
+		return err
+	}
+	atomic.AddUint64(&r.forwarded, uint64(len(batch)))
+	return nil
+}
+
+// ConvertTimeSeries returns one metric per valid sample of the series.
+func ConvertTimeSeries(series TimeSeries) []models.MetricRequestBody {
+	name := ""
+	labels := make(map[string]string, len(series.Labels))
+	for _, label := range series.Labels {
+		if label.Name == metricNameLabel {
+			name = label.Value
+		} else {
+			labels[label.Name] = label.Value
+		}
+	}
+	name = monascaclient.SanitizeMetricString(name, monascaclient.MaxMetricNameLength)
+	if name == "" {
+		return nil
+	}
+	dimensions := monascaclient.SanitizeDimensions(labels)
+
+	metrics := make([]models.MetricRequestBody, 0, len(series.Samples))
+	for _, sample := range series.Samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		value, timestamp := sample.Value, sample.Timestamp
+		metric := models.MetricRequestBody{Name: &name, Value: &value, Timestamp: &timestamp}
+		if len(dimensions) > 0 {
+			metric.Dimensions = &dimensions
+		}
+		if monascaclient.ValidateMetric(&metric) != nil {
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package remotewrite
+
+import (
+	"encoding/binary"
+	"github.com/golang/snappy"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testSender struct {
+	batches [][]models.MetricRequestBody
+}
+
+func (s *testSender) CreateMetrics(tenantID *string, metrics []models.MetricRequestBody) error {
+	s.batches = append(s.batches, metrics)
+	return nil
+}
+
+func appendBytesField(buffer []byte, number int, data []byte) []byte {
+	buffer = binary.AppendUvarint(buffer, uint64(number<<3|wireBytes))
+	buffer = binary.AppendUvarint(buffer, uint64(len(data)))
+	return append(buffer, data...)
+}
+
+func marshalTimeSeries(series TimeSeries) []byte {
+	var data []byte
+	for _, label := range series.Labels {
+		var labelData []byte
+		labelData = appendBytesField(labelData, 1, []byte(label.Name))
+		labelData = appendBytesField(labelData, 2, []byte(label.Value))
+		data = appendBytesField(data, 1, labelData)
+	}
+	for _, sample := range series.Samples {
+		var sampleData []byte
+		sampleData = binary.AppendUvarint(sampleData, 1<<3|wireFixed64)
+		sampleData = binary.LittleEndian.AppendUint64(sampleData, math.Float64bits(sample.Value))
+		sampleData = binary.AppendUvarint(sampleData, 2<<3|wireVarint)
+		sampleData = binary.AppendUvarint(sampleData, uint64(sample.Timestamp))
+		data = appendBytesField(data, 2, sampleData)
+	}
+	return data
+}
+
+func marshalWriteRequest(request WriteRequest) []byte {
+	var data []byte
+	for _, series := range request.TimeSeries {
+		data = appendBytesField(data, 1, marshalTimeSeries(series))
+	}
+	// Metadata, field 3, must be skipped
+	return appendBytesField(data, 3, []byte{})
+}
+
+func TestReceiverForwardsSamples(t *testing.T) {
+	request := WriteRequest{TimeSeries: []TimeSeries{
+		{
+			Labels:  []Label{{"__name__", "http_requests_total"}, {"job", "api"}, {"path", "/a{b}"}, {"__meta", "x"}},
+			Samples: []Sample{{1, 1488175200000}, {math.NaN(), 1488175260000}, {3, 1488175320000}},
+		},
+		{
+			Labels:  []Label{{"job", "nameless"}},
+			Samples: []Sample{{1, 1488175200000}},
+		},
+		{
+			Labels:  []Label{{"__name__", "up"}},
+			Samples: []Sample{{1, 1488175200000}},
+		},
+	}}
+
+	sender := &testSender{}
+	receiver := NewReceiver(sender)
+	receiver.SetBatchSize(2)
+
+	recorder := httptest.NewRecorder()
+	body := snappy.Encode(nil, marshalWriteRequest(request))
+	receiver.ServeHTTP(recorder, httptest.NewRequest("POST", "/write", strings.NewReader(string(body))))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 but was %d: %s", recorder.Code, recorder.Body)
+	}
+
+	if len(sender.batches) != 2 || len(sender.batches[0]) != 2 || len(sender.batches[1]) != 1 {
+		t.Fatalf("Expected batches of 2 and 1 metrics but was %v", sender.batches)
+	}
+	first := sender.batches[0][0]
+	if *first.Name != "http_requests_total" || *first.Timestamp != 1488175200000 || *first.Value != 1 {
+		t.Errorf("Unexpected metric %v %v %v", *first.Name, *first.Timestamp, *first.Value)
+	}
+	dimensions := *first.Dimensions
+	if len(dimensions) != 3 || dimensions["job"] != "api" || dimensions["path"] != "/a_b_" || dimensions["meta"] != "x" {
+		t.Errorf("Unexpected dimensions %v", dimensions)
+	}
+	stats := receiver.Stats()
+	if stats.Received != 5 || stats.Forwarded != 3 || stats.Dropped != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestReceiverRejectsInvalidPayload(t *testing.T) {
+	receiver := NewReceiver(&testSender{})
+	recorder := httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, httptest.NewRequest("POST", "/write", strings.NewReader("not snappy")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 but was %d", recorder.Code)
+	}
+
+	truncated := snappy.Encode(nil, []byte{1<<3 | wireBytes, 10, 1})
+	recorder = httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, httptest.NewRequest("POST", "/write", strings.NewReader(string(truncated))))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for truncated message but was %d", recorder.Code)
+	}
+}
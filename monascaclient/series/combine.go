@@ -0,0 +1,188 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package series
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Align resamples every series onto the common grid of interval from start,
+// truncated to the interval since the Unix epoch, up to but excluding end, so
+// that all series have a point at every grid time. Points missing from a
+// series are filled according to policy; FillPrevious and FillLinear leave
+// NaN where there is no earlier, or later, value.
+func Align(series []Series, start time.Time, end time.Time, interval time.Duration, aggregation Aggregation,
+	policy FillPolicy) ([]Series, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("Interval must be positive")
+	}
+	var grid []time.Time
+	for at := truncate(start, interval); at.Before(end); at = at.Add(interval) {
+		grid = append(grid, at)
+	}
+
+	aligned := make([]Series, len(series))
+	for i, s := range series {
+		resampled, err := Resample(s, interval, aggregation)
+		if err != nil {
+			return nil, err
+		}
+		known := make(map[int64]float64, len(resampled.Points))
+		for _, point := range resampled.Points {
+			known[point.Timestamp.UnixNano()] = point.Value
+		}
+
+		points := make([]Point, len(grid))
+		var previous *Point
+		for j, at := range grid {
+			if value, found := known[at.UnixNano()]; found {
+				points[j] = Point{Timestamp: at, Value: value}
+				previous = &points[j]
+				continue
+			}
+			points[j] = Point{Timestamp: at, Value: alignFill(policy, previous, resampled.Points, at)}
+		}
+		aligned[i] = s.withPoints(points)
+	}
+	return aligned, nil
+}
+
+func alignFill(policy FillPolicy, previous *Point, points []Point, at time.Time) float64 {
+	switch policy {
+	case FillZero:
+		return 0
+	case FillPrevious, FillLinear:
+		if previous == nil {
+			return math.NaN()
+		}
+		if policy == FillPrevious {
+			return previous.Value
+		}
+		next := sort.Search(len(points), func(i int) bool { return points[i].Timestamp.After(at) })
+		if next == len(points) {
+			return math.NaN()
+		}
+		return fill(FillLinear, *previous, points[next], at)
+	}
+	return math.NaN()
+}
+
+// Aggregate combines the series sharing the values of the groupBy dimensions
+// into one series per group, aggregating the points with equal timestamps,
+// which Align ensures. The resulting series carry only the groupBy
+// dimensions, and the name of their members if they share one.
+func Aggregate(series []Series, groupBy []string, aggregation Aggregation) ([]Series, error) {
+	type group struct {
+		name       string
+		dimensions map[string]string
+		values     map[int64][]float64
+		times      map[int64]time.Time
+	}
+	var order []string
+	groups := map[string]*group{}
+	for _, s := range series {
+		dimensions := map[string]string{}
+		parts := make([]string, len(groupBy))
+		for i, key := range groupBy {
+			if value, found := s.Dimensions[key]; found {
+				dimensions[key] = value
+			}
+			parts[i] = key + "=" + s.Dimensions[key]
+		}
+		key := strings.Join(parts, "\x00")
+		g, found := groups[key]
+		if !found {
+			g = &group{name: s.Name, dimensions: dimensions, values: map[int64][]float64{}, times: map[int64]time.Time{}}
+			groups[key] = g
+			order = append(order, key)
+		} else if g.name != s.Name {
+			g.name = ""
+		}
+		for _, point := range s.Points {
+			at := point.Timestamp.UnixNano()
+			g.values[at] = append(g.values[at], point.Value)
+			g.times[at] = point.Timestamp
+		}
+	}
+
+	results := make([]Series, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		points := make([]Point, 0, len(g.values))
+		for at, values := range g.values {
+			value, err := aggregation.Apply(values)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, Point{Timestamp: g.times[at], Value: value})
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i].Timestamp.Before(points[j].Timestamp) })
+		results = append(results, Series{Name: g.name, Dimensions: g.dimensions, Points: points})
+	}
+	return results, nil
+}
+
+// TopN returns the n series ranking highest by the aggregation of their
+// points, highest first. Series without values rank last.
+func TopN(series []Series, n int, by Aggregation) ([]Series, error) {
+	return rank(series, n, by, true)
+}
+
+// BottomN returns the n series ranking lowest, lowest first.
+func BottomN(series []Series, n int, by Aggregation) ([]Series, error) {
+	return rank(series, n, by, false)
+}
+
+func rank(series []Series, n int, by Aggregation, descending bool) ([]Series, error) {
+	scores := make([]float64, len(series))
+	indexes := make([]int, len(series))
+	for i, s := range series {
+		values := make([]float64, len(s.Points))
+		for j, point := range s.Points {
+			values[j] = point.Value
+		}
+		score, err := by.Apply(values)
+		if err != nil {
+			return nil, err
+		}
+		scores[i] = score
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := scores[indexes[i]], scores[indexes[j]]
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return !math.IsNaN(a) && math.IsNaN(b)
+		}
+		if descending {
+			return a > b
+		}
+		return a < b
+	})
+	if n > len(indexes) {
+		n = len(indexes)
+	}
+	if n < 0 {
+		n = 0
+	}
+	selected := make([]Series, n)
+	for i := 0; i < n; i++ {
+		selected[i] = series[indexes[i]]
+	}
+	return selected, nil
+}
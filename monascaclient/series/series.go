@@ -0,0 +1,182 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package series transforms decoded measurements and statistics on the client:
+// resampling, gap filling, rates, moving averages, alignment onto a common
+// time grid, aggregation across series and top-N selection. Transforms return
+// new series and leave their input unchanged. Missing values are NaN.
+package series
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"math"
+	"sort"
+	"time"
+)
+
+type Point struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// Series is a time ordered list of points of one metric.
+type Series struct {
+	Name       string
+	Dimensions map[string]string
+	Points     []Point
+}
+
+// Aggregation combines values, ignoring NaN. It is NaN if there are none,
+// except for Count.
+type Aggregation string
+
+const (
+	Avg   Aggregation = "avg"
+	Sum   Aggregation = "sum"
+	Min   Aggregation = "min"
+	Max   Aggregation = "max"
+	Count Aggregation = "count"
+	First Aggregation = "first"
+	Last  Aggregation = "last"
+)
+
+// FromMeasurements converts a GetMeasurements response.
+func FromMeasurements(elements []models.MeasurementElement) ([]Series, error) {
+	series := make([]Series, 0, len(elements))
+	for _, element := range elements {
+		points, err := columnPoints(element.Columns, element.Measurements, "value")
+		if err != nil {
+			return nil, fmt.Errorf("Measurements of %s: %v", element.Name, err)
+		}
+		series = append(series, Series{Name: element.Name, Dimensions: element.Dimensions, Points: points})
+	}
+	return series, nil
+}
+
+// FromStatistics converts a GetStatistics response using the given statistic
+// column, such as "avg".
+func FromStatistics(elements []models.StatisticElement, statistic string) ([]Series, error) {
+	series := make([]Series, 0, len(elements))
+	for _, element := range elements {
+		points, err := columnPoints(element.Columns, element.Statistics, statistic)
+		if err != nil {
+			return nil, fmt.Errorf("Statistics of %s: %v", element.Name, err)
+		}
+		series = append(series, Series{Name: element.Name, Dimensions: element.Dimensions, Points: points})
+	}
+	return series, nil
+}
+
+func columnPoints(columns []string, rows [][]interface{}, valueColumn string) ([]Point, error) {
+	timestampIndex, valueIndex := -1, -1
+	for i, column := range columns {
+		switch column {
+		case "timestamp":
+			timestampIndex = i
+		case valueColumn:
+			valueIndex = i
+		}
+	}
+	if timestampIndex < 0 || valueIndex < 0 {
+		return nil, fmt.Errorf("missing timestamp or %s column in %v", valueColumn, columns)
+	}
+
+	points := make([]Point, 0, len(rows))
+	for _, row := range rows {
+		if len(row) <= timestampIndex || len(row) <= valueIndex {
+			return nil, fmt.Errorf("short row %v", row)
+		}
+		timestampString, ok := row[timestampIndex].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid timestamp %v", row[timestampIndex])
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, timestampString)
+		if err != nil {
+			return nil, err
+		}
+		value := math.NaN()
+		if row[valueIndex] != nil {
+			if value, ok = row[valueIndex].(float64); !ok {
+				return nil, fmt.Errorf("invalid value %v", row[valueIndex])
+			}
+		}
+		points = append(points, Point{Timestamp: timestamp, Value: value})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Timestamp.Before(points[j].Timestamp) })
+	return points, nil
+}
+
+// Apply aggregates the values.
+func (a Aggregation) Apply(values []float64) (float64, error) {
+	result := math.NaN()
+	count := 0
+	for _, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		count++
+		switch a {
+		case Avg, Sum:
+			if count == 1 {
+				result = 0
+			}
+			result += value
+		case Min:
+			if count == 1 || value < result {
+				result = value
+			}
+		case Max:
+			if count == 1 || value > result {
+				result = value
+			}
+		case First:
+			if count == 1 {
+				result = value
+			}
+		case Last:
+			result = value
+		case Count:
+		default:
+			return 0, fmt.Errorf("Unknown aggregation %s", a)
+		}
+	}
+	switch a {
+	case Count:
+		return float64(count), nil
+	case Avg:
+		if count > 0 {
+			result /= float64(count)
+		}
+	case Sum, Min, Max, First, Last:
+	default:
+		return 0, fmt.Errorf("Unknown aggregation %s", a)
+	}
+	return result, nil
+}
+
+func (s Series) withPoints(points []Point) Series {
+	return Series{Name: s.Name, Dimensions: copyDimensions(s.Dimensions), Points: points}
+}
+
+func copyDimensions(dimensions map[string]string) map[string]string {
+	if dimensions == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(dimensions))
+	for key, value := range dimensions {
+		copied[key] = value
+	}
+	return copied
+}
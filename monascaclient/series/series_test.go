@@ -0,0 +1,157 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package series
+
+import (
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)
+
+func minutes(values ...float64) Series {
+	points := make([]Point, len(values))
+	for i, value := range values {
+		points[i] = Point{Timestamp: start.Add(time.Duration(i) * time.Minute), Value: value}
+	}
+	return Series{Name: "cpu", Dimensions: map[string]string{"hostname": "a"}, Points: points}
+}
+
+func checkValues(t *testing.T, description string, s Series, expected ...float64) {
+	if len(s.Points) != len(expected) {
+		t.Errorf("Expected %s to have %d points but was %v", description, len(expected), s.Points)
+		return
+	}
+	for i, value := range expected {
+		actual := s.Points[i].Value
+		if math.IsNaN(value) != math.IsNaN(actual) || (!math.IsNaN(value) && math.Abs(actual-value) > 1e-9) {
+			t.Errorf("Expected %s point %d to be %v but was %v", description, i, value, actual)
+		}
+	}
+}
+
+func TestFromMeasurements(t *testing.T) {
+	elements := []models.MeasurementElement{{
+		Name:    "cpu",
+		Columns: []string{"timestamp", "value", "value_meta"},
+		Measurements: [][]interface{}{
+			{"2017-02-27T06:01:00Z", 2.0, nil},
+			{"2017-02-27T06:00:00.5Z", 1.0, nil},
+		},
+	}}
+	series, err := FromMeasurements(elements)
+	if err != nil {
+		t.Fatalf("Error %s converting measurements", err)
+	}
+	checkValues(t, "measurements", series[0], 1, 2)
+	if _, err = FromStatistics([]models.StatisticElement{{Columns: []string{"timestamp"}}}, "avg"); err == nil {
+		t.Errorf("Expected error for missing column")
+	}
+}
+
+func TestResampleAndFill(t *testing.T) {
+	s := minutes(1, 2, 3, 4, 5, 6)
+	s.Points = append(s.Points[:2], s.Points[4:]...)
+
+	resampled, err := Resample(s, 2*time.Minute, Avg)
+	if err != nil {
+		t.Fatalf("Error %s resampling", err)
+	}
+	checkValues(t, "resampled", resampled, 1.5, 5.5)
+
+	filled, _ := FillGaps(s, time.Minute, FillLinear)
+	checkValues(t, "linear fill", filled, 1, 2, 3, 4, 5, 6)
+	filled, _ = FillGaps(s, time.Minute, FillPrevious)
+	checkValues(t, "previous fill", filled, 1, 2, 2, 2, 5, 6)
+	filled, _ = FillGaps(s, time.Minute, FillNaN)
+	checkValues(t, "NaN fill", filled, 1, 2, math.NaN(), math.NaN(), 5, 6)
+	if len(s.Points) != 4 {
+		t.Errorf("Expected input to be unchanged but was %v", s.Points)
+	}
+}
+
+func TestResampleAlignsToEpoch(t *testing.T) {
+	interval := 7 * time.Minute
+	for _, at := range []time.Time{start.Add(3 * time.Minute), time.Date(1969, 12, 31, 23, 58, 30, 500, time.UTC)} {
+		s := Series{Points: []Point{{Timestamp: at, Value: 1}}}
+		resampled, err := Resample(s, interval, Avg)
+		if err != nil {
+			t.Fatalf("Error %s resampling", err)
+		}
+		bucket := resampled.Points[0].Timestamp
+		if bucket.UnixNano()%int64(interval) != 0 || bucket.After(at) || at.Sub(bucket) >= interval {
+			t.Errorf("Expected %v to fall in an epoch aligned bucket but was %v", at, bucket)
+		}
+	}
+}
+
+func TestRateAndMovingAverage(t *testing.T) {
+	checkValues(t, "rate", Rate(minutes(0, 60, 180, 30)), 1, 2, 0.5)
+	checkValues(t, "derivative", Derivative(minutes(0, 60, 180, 30)), 1, 2, -2.5)
+
+	average, err := MovingAverage(minutes(1, 2, 3, 4), 2*time.Minute)
+	if err != nil {
+		t.Fatalf("Error %s averaging", err)
+	}
+	checkValues(t, "moving average", average, 1, 1.5, 2.5, 3.5)
+}
+
+func TestAlignAndAggregate(t *testing.T) {
+	a := minutes(1, 2, 3)
+	b := minutes(10, 20)
+	b.Dimensions = map[string]string{"hostname": "b"}
+	b.Points[1].Timestamp = start.Add(2*time.Minute + 10*time.Second)
+
+	aligned, err := Align([]Series{a, b}, start, start.Add(3*time.Minute), time.Minute, Avg, FillNaN)
+	if err != nil {
+		t.Fatalf("Error %s aligning", err)
+	}
+	checkValues(t, "aligned a", aligned[0], 1, 2, 3)
+	checkValues(t, "aligned b", aligned[1], 10, math.NaN(), 20)
+
+	summed, err := Aggregate(aligned, nil, Sum)
+	if err != nil {
+		t.Fatalf("Error %s aggregating", err)
+	}
+	if len(summed) != 1 || summed[0].Name != "cpu" {
+		t.Fatalf("Expected one cpu series but was %+v", summed)
+	}
+	checkValues(t, "sum", summed[0], 11, 2, 23)
+
+	byHost, _ := Aggregate(aligned, []string{"hostname"}, Max)
+	if len(byHost) != 2 || byHost[1].Dimensions["hostname"] != "b" {
+		t.Errorf("Expected a series per hostname but was %+v", byHost)
+	}
+}
+
+func TestTopN(t *testing.T) {
+	series := []Series{minutes(1, 2), minutes(5, 6), minutes(math.NaN()), minutes(3)}
+	top, err := TopN(series, 2, Max)
+	if err != nil {
+		t.Fatalf("Error %s ranking", err)
+	}
+	if len(top) != 2 || top[0].Points[1].Value != 6 || top[1].Points[0].Value != 3 {
+		t.Errorf("Expected the two highest series but was %+v", top)
+	}
+	bottom, _ := BottomN(series, 10, Last)
+	if len(bottom) != 4 || bottom[0].Points[1].Value != 2 || !math.IsNaN(bottom[3].Points[0].Value) {
+		t.Errorf("Expected series without values last but was %+v", bottom)
+	}
+	if _, err = TopN(series, 1, Aggregation("median")); err == nil {
+		t.Errorf("Expected error for unknown aggregation")
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package series
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// FillPolicy decides the value of a missing grid point.
+type FillPolicy int
+
+const (
+	// FillNaN leaves missing points NaN.
+	FillNaN FillPolicy = iota
+	FillZero
+	// FillPrevious repeats the last known value.
+	FillPrevious
+	// FillLinear interpolates between the surrounding known values and
+	// leaves points before the first or after the last one NaN.
+	FillLinear
+)
+
+// Resample aggregates the points into buckets of interval, aligned to the
+// Unix epoch, each timestamped with its start. Empty buckets are omitted.
+func Resample(s Series, interval time.Duration, aggregation Aggregation) (Series, error) {
+	if interval <= 0 {
+		return Series{}, fmt.Errorf("Interval must be positive")
+	}
+	var points []Point
+	var values []float64
+	var bucket time.Time
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		value, err := aggregation.Apply(values)
+		if err != nil {
+			return err
+		}
+		points = append(points, Point{Timestamp: bucket, Value: value})
+		values = values[:0]
+		return nil
+	}
+	for _, point := range s.Points {
+		start := truncate(point.Timestamp, interval)
+		if len(values) == 0 || !start.Equal(bucket) {
+			if err := flush(); err != nil {
+				return Series{}, err
+			}
+			bucket = start
+		}
+		values = append(values, point.Value)
+	}
+	if err := flush(); err != nil {
+		return Series{}, err
+	}
+	return s.withPoints(points), nil
+}
+
+// truncate rounds t down to a multiple of interval since the Unix epoch, also
+// before 1970. time.Truncate counts from the zero time instead, which only
+// agrees with the epoch for intervals that divide a day evenly.
+func truncate(t time.Time, interval time.Duration) time.Time {
+	offset := t.UnixNano() % int64(interval)
+	if offset < 0 {
+		offset += int64(interval)
+	}
+	return time.Unix(0, t.UnixNano()-offset).In(t.Location())
+}
+
+// FillGaps adds the points missing from a series on a grid of interval, such
+// as one returned by Resample, between its first and last point. Points off
+// the grid are kept as they are.
+func FillGaps(s Series, interval time.Duration, policy FillPolicy) (Series, error) {
+	if interval <= 0 {
+		return Series{}, fmt.Errorf("Interval must be positive")
+	}
+	if len(s.Points) == 0 {
+		return s.withPoints(nil), nil
+	}
+	var points []Point
+	for i, point := range s.Points {
+		if i > 0 {
+			previous := s.Points[i-1]
+			for at := truncate(previous.Timestamp, interval).Add(interval); at.Before(point.Timestamp); at = at.Add(interval) {
+				points = append(points, Point{Timestamp: at, Value: fill(policy, previous, point, at)})
+			}
+		}
+		points = append(points, point)
+	}
+	return s.withPoints(points), nil
+}
+
+func fill(policy FillPolicy, previous Point, next Point, at time.Time) float64 {
+	switch policy {
+	case FillZero:
+		return 0
+	case FillPrevious:
+		return previous.Value
+	case FillLinear:
+		span := next.Timestamp.Sub(previous.Timestamp).Seconds()
+		fraction := at.Sub(previous.Timestamp).Seconds() / span
+		return previous.Value + fraction*(next.Value-previous.Value)
+	}
+	return math.NaN()
+}
+
+// Derivative returns the change per second between consecutive points,
+// timestamped with the later point.
+func Derivative(s Series) Series {
+	return s.withPoints(perSecond(s.Points, false))
+}
+
+// Rate returns the per second increase of a counter. A decrease is taken as a
+// counter reset, the increase since then being the new value.
+func Rate(s Series) Series {
+	return s.withPoints(perSecond(s.Points, true))
+}
+
+func perSecond(points []Point, counter bool) []Point {
+	var rates []Point
+	for i := 1; i < len(points); i++ {
+		seconds := points[i].Timestamp.Sub(points[i-1].Timestamp).Seconds()
+		if seconds <= 0 {
+			continue
+		}
+		delta := points[i].Value - points[i-1].Value
+		if counter && delta < 0 {
+			delta = points[i].Value
+		}
+		rates = append(rates, Point{Timestamp: points[i].Timestamp, Value: delta / seconds})
+	}
+	return rates
+}
+
+// MovingAverage replaces every point by the average of the points in the
+// window ending with it.
+func MovingAverage(s Series, window time.Duration) (Series, error) {
+	if window <= 0 {
+		return Series{}, fmt.Errorf("Window must be positive")
+	}
+	points := make([]Point, len(s.Points))
+	start := 0
+	sum := 0.0
+	count := 0
+	for i, point := range s.Points {
+		if !math.IsNaN(point.Value) {
+			sum += point.Value
+			count++
+		}
+		for !s.Points[start].Timestamp.After(point.Timestamp.Add(-window)) {
+			if !math.IsNaN(s.Points[start].Value) {
+				sum -= s.Points[start].Value
+				count--
+			}
+			start++
+		}
+		average := math.NaN()
+		if count > 0 {
+			average = sum / float64(count)
+		}
+		points[i] = Point{Timestamp: point.Timestamp, Value: average}
+	}
+	return s.withPoints(points), nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package statsd
+
+import (
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var defaultPercentiles = []float64{50, 90, 95, 99}
+
+type series struct {
+	name       string
+	dimensions map[string]string
+}
+
+type timerValues struct {
+	values []float64
+	count  float64
+}
+
+// Aggregator accumulates parsed metrics between flushes.
+//
+// Counters publish <name> with the sampled count and <name>.rate per second.
+// Timers and histograms publish <name>.count, .rate, .sum, .min, .max, .mean
+// and one .pNN metric per percentile. Gauges publish their last value and
+// remember it for relative updates, sets publish the number of distinct
+// members. Tags become dimensions; tags without a value are dropped.
+type Aggregator struct {
+	percentiles []float64
+	dimensions  map[string]string
+
+	lock     sync.Mutex
+	series   map[string]series
+	counters map[string]float64
+	timers   map[string]*timerValues
+	gauges   map[string]float64
+	updated  map[string]bool
+	sets     map[string]map[string]bool
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		percentiles: defaultPercentiles,
+		series:      map[string]series{},
+		counters:    map[string]float64{},
+		timers:      map[string]*timerValues{},
+		gauges:      map[string]float64{},
+		updated:     map[string]bool{},
+		sets:        map[string]map[string]bool{},
+	}
+}
+
+func (a *Aggregator) SetPercentiles(percentiles []float64) {
+	a.percentiles = percentiles
+}
+
+// SetDimensions sets dimensions added to every published metric. Tags of the
+// same name take precedence.
+func (a *Aggregator) SetDimensions(dimensions map[string]string) {
+	a.dimensions = dimensions
+}
+
+func (a *Aggregator) seriesKey(metric *Metric) string {
+	tagKeys := make([]string, 0, len(metric.Tags))
+	for key := range metric.Tags {
+		tagKeys = append(tagKeys, key)
+	}
+	sort.Strings(tagKeys)
+	key := metric.Name
+	for _, tagKey := range tagKeys {
+		key += "," + tagKey + "=" + metric.Tags[tagKey]
+	}
+
+	if _, found := a.series[key]; !found {
+		dimensions := map[string]string{}
+		for name, value := range a.dimensions {
+			dimensions[name] = value
+		}
+		for name, value := range metric.Tags {
+			dimensions[name] = value
+		}
+		a.series[key] = series{
+			name:       monascaclient.SanitizeMetricString(metric.Name, monascaclient.MaxMetricNameLength),
+			dimensions: monascaclient.SanitizeDimensions(dimensions),
+		}
+	}
+	return key
+}
+
+func (a *Aggregator) Add(metric Metric) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	key := a.seriesKey(&metric)
+
+	switch metric.Type {
+	case Counter:
+		a.counters[key] += metric.Value / metric.SampleRate
+	case Timer, Histogram:
+		timer, found := a.timers[key]
+		if !found {
+			timer = &timerValues{}
+			a.timers[key] = timer
+		}
+		timer.values = append(timer.values, metric.Value)
+		timer.count += 1 / metric.SampleRate
+	case Gauge:
+		if metric.Relative {
+			a.gauges[key] += metric.Value
+		} else {
+			a.gauges[key] = metric.Value
+		}
+		a.updated[key] = true
+	case Set:
+		members, found := a.sets[key]
+		if !found {
+			members = map[string]bool{}
+			a.sets[key] = members
+		}
+		members[metric.SetValue] = true
+	}
+}
+
+// Flush returns the aggregated metrics of the interval that ended at
+// timestamp and resets counters, timers and sets.
+func (a *Aggregator) Flush(interval time.Duration, timestamp time.Time) []models.MetricRequestBody {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	milliseconds := timestamp.UnixNano() / int64(time.Millisecond)
+	seconds := interval.Seconds()
+	metrics := []models.MetricRequestBody{}
+	add := func(key string, suffix string, value float64) {
+		s := a.series[key]
+		name := s.name + suffix
+		metric := models.MetricRequestBody{Name: &name, Value: &value, Timestamp: &milliseconds}
+		if len(s.dimensions) > 0 {
+			dimensions := s.dimensions
+			metric.Dimensions = &dimensions
+		}
+		metrics = append(metrics, metric)
+	}
+
+	for _, key := range sortedKeys(a.counters) {
+		add(key, "", a.counters[key])
+		if seconds > 0 {
+			add(key, ".rate", a.counters[key]/seconds)
+		}
+	}
+
+	timerKeys := make([]string, 0, len(a.timers))
+	for key := range a.timers {
+		timerKeys = append(timerKeys, key)
+	}
+	sort.Strings(timerKeys)
+	for _, key := range timerKeys {
+		timer := a.timers[key]
+		values := timer.values
+		sort.Float64s(values)
+		sum := 0.0
+		for _, value := range values {
+			sum += value
+		}
+		add(key, ".count", timer.count)
+		if seconds > 0 {
+			add(key, ".rate", timer.count/seconds)
+		}
+		add(key, ".sum", sum)
+		add(key, ".min", values[0])
+		add(key, ".max", values[len(values)-1])
+		add(key, ".mean", sum/float64(len(values)))
+		for _, percentile := range a.percentiles {
+			add(key, ".p"+strings.Replace(strconv.FormatFloat(percentile, 'f', -1, 64), ".", "_", -1), Percentile(values, percentile))
+		}
+	}
+
+	for _, key := range sortedKeys(a.gauges) {
+		if a.updated[key] {
+			add(key, "", a.gauges[key])
+		}
+	}
+
+	setKeys := make([]string, 0, len(a.sets))
+	for key := range a.sets {
+		setKeys = append(setKeys, key)
+	}
+	sort.Strings(setKeys)
+	for _, key := range setKeys {
+		add(key, "", float64(len(a.sets[key])))
+	}
+
+	a.counters = map[string]float64{}
+	a.timers = map[string]*timerValues{}
+	a.updated = map[string]bool{}
+	a.sets = map[string]map[string]bool{}
+	// Only gauges outlive a flush
+	for key := range a.series {
+		if _, found := a.gauges[key]; !found {
+			delete(a.series, key)
+		}
+	}
+	return metrics
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Percentile returns the nearest-rank percentile of sorted values.
+func Percentile(sorted []float64, percentile float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(percentile / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
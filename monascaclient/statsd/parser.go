@@ -0,0 +1,125 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type MetricType string
+
+const (
+	Counter   MetricType = "c"
+	Gauge     MetricType = "g"
+	Timer     MetricType = "ms"
+	Histogram MetricType = "h"
+	Set       MetricType = "s"
+)
+
+// Metric is a single parsed StatsD line. Set members are kept in SetValue;
+// Relative marks signed gauge values, which StatsD applies as deltas.
+type Metric struct {
+	Name       string
+	Type       MetricType
+	Value      float64
+	SetValue   string
+	SampleRate float64
+	Relative   bool
+	Tags       map[string]string
+}
+
+// ParseLine parses name:value|type[|@rate][|#tag:value,...]. DogStatsD tags
+// without a value are kept with an empty value.
+func ParseLine(line string) (Metric, error) {
+	metric := Metric{SampleRate: 1}
+	colon := strings.IndexByte(line, ':')
+	if colon <= 0 {
+		return metric, fmt.Errorf("Invalid StatsD line %q: missing name", line)
+	}
+	metric.Name = line[:colon]
+
+	fields := strings.Split(line[colon+1:], "|")
+	if len(fields) < 2 {
+		return metric, fmt.Errorf("Invalid StatsD line %q: missing type", line)
+	}
+	metric.Type = MetricType(fields[1])
+	rawValue := fields[0]
+
+	switch metric.Type {
+	case Set:
+		if rawValue == "" {
+			return metric, fmt.Errorf("Invalid StatsD line %q: empty set value", line)
+		}
+		metric.SetValue = rawValue
+	case Counter, Gauge, Timer, Histogram:
+		value, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			return metric, fmt.Errorf("Invalid StatsD line %q: %v", line, err)
+		}
+		metric.Value = value
+		metric.Relative = metric.Type == Gauge && (strings.HasPrefix(rawValue, "+") || strings.HasPrefix(rawValue, "-"))
+	default:
+		return metric, fmt.Errorf("Invalid StatsD line %q: unknown type %q", line, fields[1])
+	}
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return metric, fmt.Errorf("Invalid StatsD line %q: bad sample rate", line)
+			}
+			metric.SampleRate = rate
+		case strings.HasPrefix(field, "#"):
+			metric.Tags = parseTags(field[1:])
+		}
+	}
+	return metric, nil
+}
+
+func parseTags(field string) map[string]string {
+	tags := map[string]string{}
+	for _, tag := range strings.Split(field, ",") {
+		if tag == "" {
+			continue
+		}
+		if colon := strings.IndexByte(tag, ':'); colon >= 0 {
+			tags[tag[:colon]] = tag[colon+1:]
+		} else {
+			tags[tag] = ""
+		}
+	}
+	return tags
+}
+
+// ParsePacket parses every non empty line of a packet. Lines that fail to
+// parse are reported in errs and skipped.
+func ParsePacket(packet []byte) (metrics []Metric, errs []error) {
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		metric, err := ParseLine(line)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, errs
+}
@@ -0,0 +1,128 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package statsd listens for StatsD and DogStatsD metrics and publishes their
+// per interval aggregates to Monasca.
+package statsd
+
+import (
+	"context"
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/relay"
+	"net"
+	"time"
+)
+
+const defaultFlushInterval = 10 * time.Second
+
+// Server reads StatsD lines from UDP packets and TCP connections, aggregates
+// them and publishes the aggregates every flush interval.
+type Server struct {
+	aggregator    *Aggregator
+	batcher       *relay.Batcher
+	server        *relay.Server
+	flushInterval time.Duration
+	errorHandler  func(error)
+}
+
+func NewServer(sender relay.MetricSender) *Server {
+	s := &Server{
+		aggregator:    NewAggregator(),
+		batcher:       relay.NewBatcher(sender),
+		flushInterval: defaultFlushInterval,
+		errorHandler:  func(error) {},
+	}
+	s.server = relay.NewServer(s.handle)
+	s.server.SetErrorHandler(func(err error) {
+		s.errorHandler(err)
+	})
+	return s
+}
+
+func (s *Server) Aggregator() *Aggregator {
+	return s.aggregator
+}
+
+func (s *Server) SetFlushInterval(flushInterval time.Duration) {
+	s.flushInterval = flushInterval
+}
+
+func (s *Server) SetBatchSize(batchSize int) {
+	s.batcher.SetBatchSize(batchSize)
+}
+
+// SetTenantID publishes the metrics on behalf of another tenant.
+func (s *Server) SetTenantID(tenantID *string) {
+	s.batcher.SetTenantID(tenantID)
+}
+
+// SetErrorHandler receives parse and publish errors, which are otherwise
+// dropped.
+func (s *Server) SetErrorHandler(errorHandler func(error)) {
+	s.errorHandler = errorHandler
+}
+
+// ListenAndServe listens on the given addresses, either of which may be empty,
+// and serves until ctx is done. A final flush is published on shutdown.
+func (s *Server) ListenAndServe(ctx context.Context, udpAddress string, tcpAddress string) error {
+	return s.server.ListenAndServe(ctx, tcpAddress, udpAddress, s.RunFlusher)
+}
+
+// ServeUDP reads packets until ctx is done, then closes the connection.
+func (s *Server) ServeUDP(ctx context.Context, conn net.PacketConn) {
+	s.server.ServeUDP(ctx, conn)
+}
+
+// ServeTCP accepts newline delimited streams until ctx is done.
+func (s *Server) ServeTCP(ctx context.Context, listener net.Listener) {
+	s.server.ServeTCP(ctx, listener)
+}
+
+func (s *Server) handle(packet []byte) {
+	metrics, errs := ParsePacket(packet)
+	for _, err := range errs {
+		s.errorHandler(err)
+	}
+	for _, metric := range metrics {
+		s.aggregator.Add(metric)
+	}
+}
+
+// RunFlusher publishes the aggregates every flush interval until ctx is done.
+func (s *Server) RunFlusher(ctx context.Context) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			s.Flush(time.Since(last))
+			return
+		case now := <-ticker.C:
+			s.Flush(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// Flush publishes the current aggregates covering the given interval.
+func (s *Server) Flush(interval time.Duration) error {
+	metrics := s.aggregator.Flush(interval, time.Now())
+	err := s.batcher.Send(metrics)
+	if err != nil {
+		err = fmt.Errorf("Failed to publish StatsD aggregates: %v", err)
+		s.errorHandler(err)
+	}
+	return err
+}
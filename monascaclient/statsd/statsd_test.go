@@ -0,0 +1,189 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package statsd
+
+import (
+	"context"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSender struct {
+	lock    sync.Mutex
+	metrics []models.MetricRequestBody
+}
+
+func (r *recordingSender) CreateMetrics(tenantID *string, metricRequestBodies []models.MetricRequestBody) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics = append(r.metrics, metricRequestBodies...)
+	return nil
+}
+
+func (r *recordingSender) values() map[string]float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	values := map[string]float64{}
+	for _, metric := range r.metrics {
+		values[*metric.Name] = *metric.Value
+	}
+	return values
+}
+
+func TestParseLine(t *testing.T) {
+	metric, err := ParseLine("page.views:2|c|@0.5|#env:prod,canary")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if metric.Name != "page.views" || metric.Type != Counter || metric.Value != 2 || metric.SampleRate != 0.5 {
+		t.Errorf("Expected page.views counter 2 @0.5 but was %+v", metric)
+	}
+	if metric.Tags["env"] != "prod" {
+		t.Errorf("Expected tag env=prod but was %v", metric.Tags)
+	}
+	if value, found := metric.Tags["canary"]; !found || value != "" {
+		t.Errorf("Expected valueless tag canary but was %v", metric.Tags)
+	}
+
+	metric, err = ParseLine("users:bob|s")
+	if err != nil || metric.SetValue != "bob" {
+		t.Errorf("Expected set member bob but was %+v, %v", metric, err)
+	}
+	metric, err = ParseLine("temp:-3|g")
+	if err != nil || !metric.Relative || metric.Value != -3 {
+		t.Errorf("Expected relative gauge -3 but was %+v, %v", metric, err)
+	}
+
+	for _, line := range []string{"novalue", "name:1", "name:x|c", "name:1|q", "name:1|c|@2"} {
+		if _, err := ParseLine(line); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	metrics, errs := ParsePacket([]byte("a:1|c\n\nbad\nb:2|g\n"))
+	if len(metrics) != 2 || len(errs) != 1 {
+		t.Errorf("Expected 2 metrics and 1 error but was %d and %d", len(metrics), len(errs))
+	}
+}
+
+func TestAggregatorFlush(t *testing.T) {
+	aggregator := NewAggregator()
+	aggregator.SetPercentiles([]float64{50, 99.9})
+	aggregator.SetDimensions(map[string]string{"service": "web"})
+	for _, line := range []string{
+		"hits:1|c", "hits:1|c|@0.1",
+		"latency:10|ms", "latency:30|ms", "latency:20|ms",
+		"temp:5|g", "temp:+2|g",
+		"users:a|s", "users:b|s", "users:a|s",
+	} {
+		metric, err := ParseLine(line)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		aggregator.Add(metric)
+	}
+
+	metrics := aggregator.Flush(10*time.Second, time.Unix(100, 0))
+	values := map[string]float64{}
+	for _, metric := range metrics {
+		values[*metric.Name] = *metric.Value
+		if (*metric.Dimensions)["service"] != "web" {
+			t.Errorf("Expected dimension service=web on %s", *metric.Name)
+		}
+		if *metric.Timestamp != 100000 {
+			t.Errorf("Expected timestamp 100000 but was %d", *metric.Timestamp)
+		}
+	}
+	expected := map[string]float64{
+		"hits": 11, "hits.rate": 1.1,
+		"latency.count": 3, "latency.sum": 60, "latency.min": 10, "latency.max": 30,
+		"latency.mean": 20, "latency.p50": 20, "latency.p99_9": 30,
+		"temp": 7, "users": 2,
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("Expected %s to be %v but was %v", name, value, values[name])
+		}
+	}
+
+	metrics = aggregator.Flush(10*time.Second, time.Unix(110, 0))
+	if len(metrics) != 0 {
+		t.Errorf("Expected no metrics without updates but was %d", len(metrics))
+	}
+	metric, _ := ParseLine("temp:+1|g")
+	aggregator.Add(metric)
+	metrics = aggregator.Flush(10*time.Second, time.Unix(120, 0))
+	if len(metrics) != 1 || *metrics[0].Value != 8 {
+		t.Errorf("Expected gauge to keep its value across flushes")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if Percentile(values, 90) != 9 || Percentile(values, 0) != 1 || Percentile(values, 100) != 10 {
+		t.Errorf("Unexpected nearest rank percentiles")
+	}
+	if Percentile(nil, 50) != 0 {
+		t.Errorf("Expected 0 for no values")
+	}
+}
+
+func TestServer(t *testing.T) {
+	sender := &recordingSender{}
+	server := NewServer(sender)
+
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on UDP: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on TCP: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.ServeUDP(ctx, packetConn)
+	go server.ServeTCP(ctx, listener)
+
+	udp, err := net.Dial("udp", packetConn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	defer udp.Close()
+	udp.Write([]byte("udp.hits:3|c\n"))
+
+	tcp, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	tcp.Write([]byte("tcp.hits:4|c\n"))
+	tcp.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		server.Flush(time.Second)
+		values := sender.values()
+		if values["udp.hits"] == 3 && values["tcp.hits"] == 4 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected udp.hits and tcp.hits to be published but was %v", sender.values())
+}
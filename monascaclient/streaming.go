@@ -0,0 +1,322 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"io"
+	"sync"
+)
+
+func SetMaxResponseSize(maxResponseSize int64) {
+	monClient.SetMaxResponseSize(maxResponseSize)
+}
+
+// SetMaxResponseSize fails calls whose response body is larger than
+// maxResponseSize bytes, zero or less means no limit.
+func (c *Client) SetMaxResponseSize(maxResponseSize int64) {
+	c.maxResponseSize = maxResponseSize
+}
+
+func (c *Client) limitResponse(body io.Reader) io.Reader {
+	if c.maxResponseSize <= 0 {
+		return body
+	}
+	return &limitedReader{reader: body, remaining: c.maxResponseSize, limit: c.maxResponseSize}
+}
+
+// limitedReader fails, rather than ends, once more than limit bytes are read.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, fmt.Errorf("Response exceeds the maximum size of %d bytes", r.limit)
+	}
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, fmt.Errorf("Response exceeds the maximum size of %d bytes", r.limit)
+	}
+	return n, err
+}
+
+func StreamMeasurements(measurementQuery *models.MeasurementQuery, handle func(series *models.MeasurementElement, point []interface{}) error) error {
+	return monClient.StreamMeasurements(measurementQuery, handle)
+}
+
+// StreamMeasurements follows the next links of a measurement query and calls
+// handle with every point as it is decoded, so that neither a page nor its
+// body is held in memory. The series passed along has no measurements of its
+// own; series without points are skipped and a series continued on the next
+// page is passed again. An error returned by handle stops the stream.
+func (c *Client) StreamMeasurements(measurementQuery *models.MeasurementQuery, handle func(series *models.MeasurementElement, point []interface{}) error) error {
+	return followPages(func(offset string) ([]models.Link, int, error) {
+		var links []models.Link
+		var elements int
+		err := c.callMonascaStream(metricsBasePath+"/measurements", measurementQuery, offset, func(body io.Reader) error {
+			var err error
+			links, elements, err = decodeMeasurementStream(body, handle)
+			return err
+		})
+		return links, elements, err
+	})
+}
+
+func (c *Client) callMonascaStream(basePath string, queryStruct interface{}, offset string, stream func(io.Reader) error) error {
+	urlValues, err := convertStructToQueryParameters(queryStruct)
+	if err != nil {
+		return err
+	}
+	if offset != "" {
+		urlValues.Set("offset", offset)
+	}
+	monascaURL, err := c.createMonascaAPIURL(basePath, urlValues)
+	if err != nil {
+		return err
+	}
+
+	statusCode, body, err := c.roundTripStream(monascaURL, "GET", nil, stream)
+	if err != nil {
+		return err
+	}
+	if statusCode != 200 {
+		return fmt.Errorf("Error: %d %s", statusCode, body)
+	}
+	return nil
+}
+
+// decodeMeasurementStream decodes a measurements page token by token,
+// returning its links and number of series. Points of a series are buffered
+// only when they precede its id, name, dimensions or columns.
+func decodeMeasurementStream(reader io.Reader, handle func(*models.MeasurementElement, []interface{}) error) ([]models.Link, int, error) {
+	decoder := json.NewDecoder(reader)
+	var links []models.Link
+	elements := 0
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, 0, err
+	}
+	for decoder.More() {
+		key, err := decodeKey(decoder)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch key {
+		case "links":
+			err = decoder.Decode(&links)
+		case "elements":
+			if err = expectDelim(decoder, '['); err != nil {
+				break
+			}
+			for err == nil && decoder.More() {
+				err = decodeMeasurementElement(decoder, handle)
+				elements++
+			}
+			if err == nil {
+				err = expectDelim(decoder, ']')
+			}
+		default:
+			var ignored json.RawMessage
+			err = decoder.Decode(&ignored)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return nil, 0, err
+	}
+	return links, elements, nil
+}
+
+func decodeMeasurementElement(decoder *json.Decoder, handle func(*models.MeasurementElement, []interface{}) error) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+	series := &models.MeasurementElement{}
+	header := 0
+	var pending [][]interface{}
+	for decoder.More() {
+		key, err := decodeKey(decoder)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "id":
+			err = decoder.Decode(&series.ID)
+			header++
+		case "name":
+			err = decoder.Decode(&series.Name)
+			header++
+		case "dimensions":
+			err = decoder.Decode(&series.Dimensions)
+			header++
+		case "columns":
+			err = decoder.Decode(&series.Columns)
+			header++
+		case "measurements":
+			if err = expectDelim(decoder, '['); err != nil {
+				return err
+			}
+			complete := header == 4
+			for decoder.More() {
+				var point []interface{}
+				if err = decoder.Decode(&point); err != nil {
+					return err
+				}
+				if !complete {
+					pending = append(pending, point)
+				} else if err = handle(series, point); err != nil {
+					return err
+				}
+			}
+			err = expectDelim(decoder, ']')
+		default:
+			var ignored json.RawMessage
+			err = decoder.Decode(&ignored)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return err
+	}
+	for _, point := range pending {
+		if err := handle(series, point); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeKey(decoder *json.Decoder) (string, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := token.(string)
+	if !ok {
+		return "", fmt.Errorf("Expected object key but was %v", token)
+	}
+	return key, nil
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("Expected %s but was %v", delim, token)
+	}
+	return nil
+}
+
+// MeasurementIterator pulls streamed measurements one point at a time:
+//
+//	iterator := client.IterateMeasurements(query)
+//	defer iterator.Close()
+//	for iterator.Next() {
+//		series, point := iterator.Series(), iterator.Point()
+//	}
+//	err := iterator.Err()
+type MeasurementIterator struct {
+	points    chan streamedPoint
+	closed    chan struct{}
+	closeOnce sync.Once
+	current   streamedPoint
+	err       error
+	streamErr error
+}
+
+type streamedPoint struct {
+	series *models.MeasurementElement
+	point  []interface{}
+}
+
+func IterateMeasurements(measurementQuery *models.MeasurementQuery) *MeasurementIterator {
+	return monClient.IterateMeasurements(measurementQuery)
+}
+
+// IterateMeasurements streams the measurements of a query like
+// StreamMeasurements. The iterator must be closed if it is abandoned before
+// Next returns false.
+func (c *Client) IterateMeasurements(measurementQuery *models.MeasurementQuery) *MeasurementIterator {
+	iterator := &MeasurementIterator{
+		points: make(chan streamedPoint),
+		closed: make(chan struct{}),
+	}
+	go func() {
+		iterator.streamErr = c.StreamMeasurements(measurementQuery, func(series *models.MeasurementElement, point []interface{}) error {
+			select {
+			case iterator.points <- streamedPoint{series: series, point: point}:
+				return nil
+			case <-iterator.closed:
+				return errIteratorClosed
+			}
+		})
+		close(iterator.points)
+	}()
+	return iterator
+}
+
+var errIteratorClosed = fmt.Errorf("Iterator closed")
+
+// Next advances to the next point and reports whether there is one.
+func (i *MeasurementIterator) Next() bool {
+	streamed, ok := <-i.points
+	if !ok {
+		if i.streamErr != errIteratorClosed {
+			i.err = i.streamErr
+		}
+		return false
+	}
+	i.current = streamed
+	return true
+}
+
+// Series returns the series of the current point.
+func (i *MeasurementIterator) Series() *models.MeasurementElement {
+	return i.current.series
+}
+
+// Point returns the current point, with values in the order of the series'
+// columns.
+func (i *MeasurementIterator) Point() []interface{} {
+	return i.current.point
+}
+
+// Err returns the error that ended the iteration, if any.
+func (i *MeasurementIterator) Err() error {
+	return i.err
+}
+
+// Close stops the stream and waits for its response to be released.
+func (i *MeasurementIterator) Close() {
+	i.closeOnce.Do(func() {
+		close(i.closed)
+	})
+	for range i.points {
+	}
+}
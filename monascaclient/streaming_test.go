@@ -0,0 +1,109 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func measurementPages(serverURL *string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") == "" {
+			fmt.Fprintf(w, `{"links": [{"rel": "next", "href": "%s/v2.0/metrics/measurements?offset=2"}], "elements": [
+				{"id": "1", "name": "cpu", "dimensions": {"hostname": "a"}, "columns": ["timestamp", "value", "value_meta"],
+				 "measurements": [["2017-02-27T06:00:00Z", 1, {}], ["2017-02-27T06:01:00Z", 2, {}]]},
+				{"measurements": [["2017-02-27T06:00:00Z", 3, {}]], "id": "2", "name": "cpu",
+				 "columns": ["timestamp", "value", "value_meta"], "dimensions": {"hostname": "b"}, "extra": [1, 2]}]}`, *serverURL)
+			return
+		}
+		w.Write([]byte(`{"links": [], "elements": [{"id": "2", "name": "cpu", "dimensions": {"hostname": "b"},
+			"columns": ["timestamp", "value", "value_meta"], "measurements": [["2017-02-27T06:01:00Z", 4, {}]]}]}`))
+	}
+}
+
+func TestStreamMeasurements(t *testing.T) {
+	var serverURL string
+	server := httptest.NewServer(measurementPages(&serverURL))
+	defer server.Close()
+	serverURL = server.URL
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	var points []string
+	err := client.StreamMeasurements(&models.MeasurementQuery{}, func(series *models.MeasurementElement, point []interface{}) error {
+		points = append(points, fmt.Sprintf("%s=%v", series.Dimensions["hostname"], point[1]))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error %s streaming measurements", err)
+	}
+	expected := "a=1 a=2 b=3 b=4"
+	if strings.Join(points, " ") != expected {
+		t.Errorf("Expected %s but was %v", expected, points)
+	}
+}
+
+func TestIterateMeasurements(t *testing.T) {
+	var serverURL string
+	server := httptest.NewServer(measurementPages(&serverURL))
+	defer server.Close()
+	serverURL = server.URL
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	iterator := client.IterateMeasurements(&models.MeasurementQuery{})
+	count := 0
+	for iterator.Next() {
+		count++
+		if iterator.Series().ID == "" || len(iterator.Point()) != 3 {
+			t.Errorf("Expected a complete point but was %v of %+v", iterator.Point(), iterator.Series())
+		}
+	}
+	if iterator.Err() != nil || count != 4 {
+		t.Errorf("Expected 4 points but was %d, %v", count, iterator.Err())
+	}
+
+	iterator = client.IterateMeasurements(&models.MeasurementQuery{})
+	iterator.Next()
+	iterator.Close()
+	if iterator.Next() || iterator.Err() != nil {
+		t.Errorf("Expected a closed iterator to end without error but was %v", iterator.Err())
+	}
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	var serverURL string
+	server := httptest.NewServer(measurementPages(&serverURL))
+	defer server.Close()
+	serverURL = server.URL
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	client.SetMaxResponseSize(100)
+	if _, err := client.GetMeasurements(&models.MeasurementQuery{}); err == nil || !strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("Expected response size error but was %v", err)
+	}
+	err := client.StreamMeasurements(&models.MeasurementQuery{}, func(*models.MeasurementElement, []interface{}) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("Expected response size error but was %v", err)
+	}
+}
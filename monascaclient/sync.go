@@ -0,0 +1,584 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+const defaultSeverity = "LOW"
+
+// DesiredState is the declarative description of the notification methods and
+// alarm definitions a tenant should have. Alarm actions reference notification
+// methods by name; they are resolved to IDs when a plan is applied.
+type DesiredState struct {
+	NotificationMethods []DesiredNotificationMethod `yaml:"notification_methods" json:"notification_methods"`
+	AlarmDefinitions    []DesiredAlarmDefinition    `yaml:"alarm_definitions" json:"alarm_definitions"`
+}
+
+type DesiredNotificationMethod struct {
+	Name    string `yaml:"name" json:"name"`
+	Type    string `yaml:"type" json:"type"`
+	Address string `yaml:"address" json:"address"`
+	Period  int    `yaml:"period" json:"period"`
+}
+
+type DesiredAlarmDefinition struct {
+	Name                string   `yaml:"name" json:"name"`
+	Description         string   `yaml:"description" json:"description"`
+	Expression          string   `yaml:"expression" json:"expression"`
+	Severity            string   `yaml:"severity" json:"severity"`
+	MatchBy             []string `yaml:"match_by" json:"match_by"`
+	AlarmActions        []string `yaml:"alarm_actions" json:"alarm_actions"`
+	OkActions           []string `yaml:"ok_actions" json:"ok_actions"`
+	UndeterminedActions []string `yaml:"undetermined_actions" json:"undetermined_actions"`
+	ActionsEnabled      *bool    `yaml:"actions_enabled" json:"actions_enabled"`
+}
+
+// SyncOptions controls how a plan is computed and applied.
+type SyncOptions struct {
+	// DryRun computes the plan without applying it.
+	DryRun bool
+	// Prune deletes resources that exist in Monasca but not in the desired
+	// state. When false they are reported as retained.
+	Prune bool
+	// Protected lists names that are never deleted, even when pruning.
+	Protected []string
+}
+
+type SyncAction string
+
+const (
+	SyncCreate SyncAction = "create"
+	SyncUpdate SyncAction = "update"
+	SyncPatch  SyncAction = "patch"
+	SyncDelete SyncAction = "delete"
+	// SyncReplace deletes an alarm definition and creates it again, which is
+	// the only way to change match_by. Alarms of the old definition are lost.
+	SyncReplace SyncAction = "replace"
+)
+
+type SyncResourceKind string
+
+const (
+	SyncNotificationMethod SyncResourceKind = "notification method"
+	SyncAlarmDefinition    SyncResourceKind = "alarm definition"
+)
+
+type FieldDiff struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// SyncChange is a single step of a SyncPlan.
+type SyncChange struct {
+	Action SyncAction
+	Kind   SyncResourceKind
+	Name   string
+	// ID of the existing resource, empty for creates.
+	ID     string
+	Fields []FieldDiff
+
+	notificationMethod *DesiredNotificationMethod
+	alarmDefinition    *DesiredAlarmDefinition
+}
+
+// SyncPlan is the ordered list of changes needed to reconcile Monasca with a
+// DesiredState. Retained holds deletions that were suppressed because pruning
+// was disabled or the resource is protected.
+type SyncPlan struct {
+	Changes  []SyncChange
+	Retained []SyncChange
+
+	notificationIDs map[string]string
+}
+
+func LoadDesiredState(reader io.Reader) (*DesiredState, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	state := new(DesiredState)
+	err = yaml.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse desired state: %v", err)
+	}
+	err = state.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func LoadDesiredStateFile(path string) (*DesiredState, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadDesiredState(file)
+}
+
+// Validate checks that every resource is named, names are unique and that
+// alarm actions only reference notification methods of the desired state.
+func (s *DesiredState) Validate() error {
+	notificationNames := map[string]bool{}
+	for _, notification := range s.NotificationMethods {
+		if notification.Name == "" {
+			return fmt.Errorf("Notification method without a name")
+		}
+		if notificationNames[notification.Name] {
+			return fmt.Errorf("Duplicate notification method %q", notification.Name)
+		}
+		if notification.Type == "" || notification.Address == "" {
+			return fmt.Errorf("Notification method %q needs a type and an address", notification.Name)
+		}
+		notificationNames[notification.Name] = true
+	}
+
+	definitionNames := map[string]bool{}
+	for _, definition := range s.AlarmDefinitions {
+		if definition.Name == "" {
+			return fmt.Errorf("Alarm definition without a name")
+		}
+		if definitionNames[definition.Name] {
+			return fmt.Errorf("Duplicate alarm definition %q", definition.Name)
+		}
+		if definition.Expression == "" {
+			return fmt.Errorf("Alarm definition %q has no expression", definition.Name)
+		}
+		definitionNames[definition.Name] = true
+
+		for _, actions := range [][]string{definition.AlarmActions, definition.OkActions, definition.UndeterminedActions} {
+			for _, action := range actions {
+				if !notificationNames[action] {
+					return fmt.Errorf("Alarm definition %q references unknown notification method %q", definition.Name, action)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func PlanSync(state *DesiredState, options *SyncOptions) (*SyncPlan, error) {
+	return monClient.PlanSync(state, options)
+}
+
+func ApplySync(plan *SyncPlan) error {
+	return monClient.ApplySync(plan)
+}
+
+func Sync(state *DesiredState, options *SyncOptions) (*SyncPlan, error) {
+	return monClient.Sync(state, options)
+}
+
+// PlanSync fetches the current notification methods and alarm definitions and
+// computes the changes needed to reach the desired state, matching resources
+// by name.
+func (c *Client) PlanSync(state *DesiredState, options *SyncOptions) (*SyncPlan, error) {
+	if options == nil {
+		options = &SyncOptions{}
+	}
+	err := state.Validate()
+	if err != nil {
+		return nil, err
+	}
+	notifications, err := c.getAllNotificationMethods(nil)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := c.getAllAlarmDefinitions(nil)
+	if err != nil {
+		return nil, err
+	}
+	return planSync(state, notifications, definitions, options), nil
+}
+
+// Sync plans and, unless DryRun is set, applies the changes. The plan is
+// returned in both cases.
+func (c *Client) Sync(state *DesiredState, options *SyncOptions) (*SyncPlan, error) {
+	plan, err := c.PlanSync(state, options)
+	if err != nil {
+		return nil, err
+	}
+	if options != nil && options.DryRun {
+		return plan, nil
+	}
+	return plan, c.ApplySync(plan)
+}
+
+// ApplySync executes a plan. Notification methods are created and updated
+// first so alarm definitions can reference them, deletions run last. Replaced
+// definitions are deleted just before they are created again.
+func (c *Client) ApplySync(plan *SyncPlan) error {
+	notificationIDs := map[string]string{}
+	for name, id := range plan.notificationIDs {
+		notificationIDs[name] = id
+	}
+
+	for _, change := range plan.Changes {
+		if change.Kind != SyncNotificationMethod || change.Action == SyncDelete {
+			continue
+		}
+		body := notificationRequestBody(change.notificationMethod)
+		var notification *models.NotificationElement
+		var err error
+		if change.Action == SyncCreate {
+			notification, err = c.CreateNotificationMethod(body)
+		} else {
+			notification, err = c.UpdateNotificationMethod(change.ID, body)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to %s %s %q: %v", change.Action, change.Kind, change.Name, err)
+		}
+		notificationIDs[change.Name] = notification.ID
+	}
+
+	for _, change := range plan.Changes {
+		if change.Kind != SyncAlarmDefinition || change.Action == SyncDelete {
+			continue
+		}
+		var err error
+		if change.Action == SyncCreate || change.Action == SyncReplace {
+			var body *models.AlarmDefinitionRequestBody
+			body, err = alarmDefinitionRequestBody(change.alarmDefinition, notificationIDs)
+			if err == nil && change.Action == SyncReplace {
+				err = c.DeleteAlarmDefinition(change.ID)
+			}
+			if err == nil {
+				_, err = c.CreateAlarmDefinition(body)
+			}
+		} else {
+			var body *models.AlarmDefinitionRequestBody
+			body, err = alarmDefinitionPatchBody(change.alarmDefinition, change.Fields, notificationIDs)
+			if err == nil {
+				_, err = c.PatchAlarmDefinition(change.ID, body)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to %s %s %q: %v", change.Action, change.Kind, change.Name, err)
+		}
+	}
+
+	for _, kind := range []SyncResourceKind{SyncAlarmDefinition, SyncNotificationMethod} {
+		for _, change := range plan.Changes {
+			if change.Kind != kind || change.Action != SyncDelete {
+				continue
+			}
+			var err error
+			if kind == SyncAlarmDefinition {
+				err = c.DeleteAlarmDefinition(change.ID)
+			} else {
+				err = c.DeleteNotificationMethod(change.ID)
+			}
+			if err != nil {
+				return fmt.Errorf("Failed to delete %s %q: %v", change.Kind, change.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// HasChanges reports whether applying the plan would modify anything.
+func (p *SyncPlan) HasChanges() bool {
+	return len(p.Changes) > 0
+}
+
+// WriteDiff writes a human readable summary of the plan.
+func (p *SyncPlan) WriteDiff(writer io.Writer) error {
+	symbols := map[SyncAction]string{SyncCreate: "+", SyncUpdate: "~", SyncPatch: "~", SyncDelete: "-", SyncReplace: "-/+"}
+	var out strings.Builder
+	if !p.HasChanges() {
+		out.WriteString("No changes.\n")
+	}
+	for _, change := range p.Changes {
+		fmt.Fprintf(&out, "%s %s %q (%s)\n", symbols[change.Action], change.Kind, change.Name, change.Action)
+		for _, field := range change.Fields {
+			fmt.Fprintf(&out, "    %s: %s => %s\n", field.Field, field.Old, field.New)
+		}
+	}
+	for _, change := range p.Retained {
+		fmt.Fprintf(&out, "  %s %q (retained)\n", change.Kind, change.Name)
+	}
+	_, err := io.WriteString(writer, out.String())
+	return err
+}
+
+func (p *SyncPlan) String() string {
+	var out strings.Builder
+	p.WriteDiff(&out)
+	return out.String()
+}
+
+func planSync(state *DesiredState, notifications []models.NotificationElement, definitions []models.AlarmDefinitionElement, options *SyncOptions) *SyncPlan {
+	plan := &SyncPlan{notificationIDs: map[string]string{}}
+	protected := map[string]bool{}
+	for _, name := range options.Protected {
+		protected[name] = true
+	}
+
+	notificationNames := map[string]string{}
+	currentNotifications := map[string]models.NotificationElement{}
+	for _, notification := range notifications {
+		notificationNames[notification.ID] = notification.Name
+		plan.notificationIDs[notification.Name] = notification.ID
+		currentNotifications[notification.Name] = notification
+	}
+
+	wantedNotifications := map[string]bool{}
+	for i := range state.NotificationMethods {
+		desired := &state.NotificationMethods[i]
+		wantedNotifications[desired.Name] = true
+		current, found := currentNotifications[desired.Name]
+		if !found {
+			plan.Changes = append(plan.Changes, SyncChange{
+				Action:             SyncCreate,
+				Kind:               SyncNotificationMethod,
+				Name:               desired.Name,
+				Fields:             notificationFields(nil, desired),
+				notificationMethod: desired,
+			})
+			continue
+		}
+		fields := notificationFields(&current.Notification, desired)
+		if len(fields) > 0 {
+			plan.Changes = append(plan.Changes, SyncChange{
+				Action:             SyncUpdate,
+				Kind:               SyncNotificationMethod,
+				Name:               desired.Name,
+				ID:                 current.ID,
+				Fields:             fields,
+				notificationMethod: desired,
+			})
+		}
+	}
+
+	currentDefinitions := map[string]models.AlarmDefinitionElement{}
+	for _, definition := range definitions {
+		currentDefinitions[definition.Name] = definition
+	}
+
+	wantedDefinitions := map[string]bool{}
+	for i := range state.AlarmDefinitions {
+		desired := &state.AlarmDefinitions[i]
+		wantedDefinitions[desired.Name] = true
+		current, found := currentDefinitions[desired.Name]
+		if !found {
+			plan.Changes = append(plan.Changes, SyncChange{
+				Action:          SyncCreate,
+				Kind:            SyncAlarmDefinition,
+				Name:            desired.Name,
+				Fields:          alarmDefinitionFields(nil, desired, notificationNames),
+				alarmDefinition: desired,
+			})
+			continue
+		}
+		fields := alarmDefinitionFields(&current, desired, notificationNames)
+		if len(fields) > 0 {
+			action := SyncPatch
+			for _, field := range fields {
+				if field.Field == "match_by" {
+					action = SyncReplace
+				}
+			}
+			plan.Changes = append(plan.Changes, SyncChange{
+				Action:          action,
+				Kind:            SyncAlarmDefinition,
+				Name:            desired.Name,
+				ID:              current.ID,
+				Fields:          fields,
+				alarmDefinition: desired,
+			})
+		}
+	}
+
+	addDeletion := func(change SyncChange) {
+		if options.Prune && !protected[change.Name] {
+			plan.Changes = append(plan.Changes, change)
+		} else {
+			plan.Retained = append(plan.Retained, change)
+		}
+	}
+	for _, definition := range definitions {
+		if !wantedDefinitions[definition.Name] {
+			addDeletion(SyncChange{Action: SyncDelete, Kind: SyncAlarmDefinition, Name: definition.Name, ID: definition.ID})
+		}
+	}
+	for _, notification := range notifications {
+		if !wantedNotifications[notification.Name] {
+			addDeletion(SyncChange{Action: SyncDelete, Kind: SyncNotificationMethod, Name: notification.Name, ID: notification.ID})
+		}
+	}
+
+	return plan
+}
+
+func notificationFields(current *models.Notification, desired *DesiredNotificationMethod) []FieldDiff {
+	if current == nil {
+		current = &models.Notification{}
+	}
+	fields := []FieldDiff{}
+	fields = appendFieldDiff(fields, "type", strings.ToUpper(current.Type), strings.ToUpper(desired.Type))
+	fields = appendFieldDiff(fields, "address", current.Address, desired.Address)
+	fields = appendFieldDiff(fields, "period", fmt.Sprint(current.Period), fmt.Sprint(desired.Period))
+	return fields
+}
+
+// alarmDefinitionFields compares a desired definition with the current one.
+// Current actions are IDs and are translated to notification names so both
+// sides can be compared.
+func alarmDefinitionFields(current *models.AlarmDefinitionElement, desired *DesiredAlarmDefinition, notificationNames map[string]string) []FieldDiff {
+	if current == nil {
+		current = &models.AlarmDefinitionElement{ActionsEnabled: true}
+	}
+	actionNames := func(ids []string) []string {
+		names := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if name, found := notificationNames[id]; found {
+				names = append(names, name)
+			} else {
+				names = append(names, id)
+			}
+		}
+		return names
+	}
+	severity := desired.Severity
+	if severity == "" {
+		severity = defaultSeverity
+	}
+	currentSeverity := current.Severity
+	if currentSeverity == "" && current.ID != "" {
+		currentSeverity = defaultSeverity
+	}
+
+	fields := []FieldDiff{}
+	fields = appendFieldDiff(fields, "description", current.Description, desired.Description)
+	fields = appendFieldDiff(fields, "expression", current.Expression, desired.Expression)
+	fields = appendFieldDiff(fields, "severity", currentSeverity, strings.ToUpper(severity))
+	fields = appendFieldDiff(fields, "match_by", formatList(current.MatchBy), formatList(desired.MatchBy))
+	fields = appendFieldDiff(fields, "alarm_actions", formatList(actionNames(current.AlarmActions)), formatList(desired.AlarmActions))
+	fields = appendFieldDiff(fields, "ok_actions", formatList(actionNames(current.OkActions)), formatList(desired.OkActions))
+	fields = appendFieldDiff(fields, "undetermined_actions", formatList(actionNames(current.UndeterminedActions)), formatList(desired.UndeterminedActions))
+	if desired.ActionsEnabled != nil {
+		fields = appendFieldDiff(fields, "actions_enabled", fmt.Sprint(current.ActionsEnabled), fmt.Sprint(*desired.ActionsEnabled))
+	}
+	return fields
+}
+
+func appendFieldDiff(fields []FieldDiff, field string, old string, updated string) []FieldDiff {
+	if old == updated {
+		return fields
+	}
+	return append(fields, FieldDiff{Field: field, Old: fmt.Sprintf("%q", old), New: fmt.Sprintf("%q", updated)})
+}
+
+// formatList renders a list independent of its order, actions and match_by
+// are sets as far as Monasca is concerned.
+func formatList(values []string) string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return "[" + strings.Join(sorted, ", ") + "]"
+}
+
+func notificationRequestBody(desired *DesiredNotificationMethod) *models.NotificationRequestBody {
+	return &models.NotificationRequestBody{
+		Name:    &desired.Name,
+		Type:    &desired.Type,
+		Address: &desired.Address,
+		Period:  &desired.Period,
+	}
+}
+
+func resolveNotificationNames(names []string, notificationIDs map[string]string) (*[]string, error) {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id, found := notificationIDs[name]
+		if !found {
+			return nil, fmt.Errorf("Unknown notification method %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return &ids, nil
+}
+
+func alarmDefinitionRequestBody(desired *DesiredAlarmDefinition, notificationIDs map[string]string) (*models.AlarmDefinitionRequestBody, error) {
+	body := &models.AlarmDefinitionRequestBody{
+		Name:           &desired.Name,
+		Description:    &desired.Description,
+		Expression:     &desired.Expression,
+		ActionsEnabled: desired.ActionsEnabled,
+	}
+	if desired.Severity != "" {
+		severity := strings.ToUpper(desired.Severity)
+		body.Severity = &severity
+	}
+	if len(desired.MatchBy) > 0 {
+		body.MatchBy = &desired.MatchBy
+	}
+	var err error
+	body.AlarmActions, err = resolveNotificationNames(desired.AlarmActions, notificationIDs)
+	if err != nil {
+		return nil, err
+	}
+	body.OkActions, err = resolveNotificationNames(desired.OkActions, notificationIDs)
+	if err != nil {
+		return nil, err
+	}
+	body.UndeterminedActions, err = resolveNotificationNames(desired.UndeterminedActions, notificationIDs)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// alarmDefinitionPatchBody only carries the fields that differ.
+func alarmDefinitionPatchBody(desired *DesiredAlarmDefinition, fields []FieldDiff, notificationIDs map[string]string) (*models.AlarmDefinitionRequestBody, error) {
+	full, err := alarmDefinitionRequestBody(desired, notificationIDs)
+	if err != nil {
+		return nil, err
+	}
+	body := new(models.AlarmDefinitionRequestBody)
+	for _, field := range fields {
+		switch field.Field {
+		case "description":
+			body.Description = full.Description
+		case "expression":
+			body.Expression = full.Expression
+		case "severity":
+			severity := strings.ToUpper(desired.Severity)
+			if severity == "" {
+				severity = defaultSeverity
+			}
+			body.Severity = &severity
+		case "alarm_actions":
+			body.AlarmActions = full.AlarmActions
+		case "ok_actions":
+			body.OkActions = full.OkActions
+		case "undetermined_actions":
+			body.UndeterminedActions = full.UndeterminedActions
+		case "actions_enabled":
+			body.ActionsEnabled = full.ActionsEnabled
+		}
+	}
+	return body, nil
+}
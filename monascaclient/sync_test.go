@@ -0,0 +1,211 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"encoding/json"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testDesiredState = `
+notification_methods:
+  - name: ops
+    type: email
+    address: ops@example.com
+  - name: pager
+    type: WEBHOOK
+    address: http://pager.example.com
+alarm_definitions:
+  - name: cpu
+    expression: avg(cpu.idle_perc) < 10
+    severity: high
+    match_by: [hostname]
+    alarm_actions: [ops, pager]
+  - name: disk
+    expression: max(disk.space_used_perc) > 90
+    alarm_actions: [ops]
+`
+
+func TestLoadDesiredStateRejectsUnknownNotification(t *testing.T) {
+	_, err := LoadDesiredState(strings.NewReader(`
+alarm_definitions:
+  - name: cpu
+    expression: avg(cpu.idle_perc) < 10
+    alarm_actions: [missing]
+`))
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected unknown notification error but was %v", err)
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	state, err := LoadDesiredState(strings.NewReader(testDesiredState))
+	if err != nil {
+		t.Fatalf("Error %s loading desired state", err)
+	}
+	notifications := []models.NotificationElement{
+		{ResponseElement: models.ResponseElement{ID: "n1"}, Notification: models.Notification{Name: "ops", Type: "EMAIL", Address: "ops@example.com"}},
+		{ResponseElement: models.ResponseElement{ID: "n2"}, Notification: models.Notification{Name: "old", Type: "EMAIL", Address: "old@example.com"}},
+	}
+	definitions := []models.AlarmDefinitionElement{
+		{
+			AlarmDefinition: models.AlarmDefinition{Name: "cpu", Expression: "avg(cpu.idle_perc) < 10", Severity: "HIGH", MatchBy: []string{"hostname"}, AlarmActions: []string{"n1"}},
+			ActionsEnabled:  true,
+			ResponseElement: models.ResponseElement{ID: "d1"},
+		},
+		{
+			AlarmDefinition: models.AlarmDefinition{Name: "disk", Expression: "max(disk.space_used_perc) > 90", Severity: "LOW", MatchBy: []string{"hostname"}, AlarmActions: []string{"n1"}},
+			ActionsEnabled:  true,
+			ResponseElement: models.ResponseElement{ID: "d3"},
+		},
+		{
+			AlarmDefinition: models.AlarmDefinition{Name: "legacy", Expression: "avg(x) > 1", Severity: "LOW"},
+			ActionsEnabled:  true,
+			ResponseElement: models.ResponseElement{ID: "d2"},
+		},
+	}
+
+	plan := planSync(state, notifications, definitions, &SyncOptions{Prune: true, Protected: []string{"legacy"}})
+
+	expected := []string{
+		"create notification method pager",
+		"patch alarm definition cpu",
+		"replace alarm definition disk",
+		"delete notification method old",
+	}
+	if len(plan.Changes) != len(expected) {
+		t.Fatalf("Expected %d changes but was %d:\n%s", len(expected), len(plan.Changes), plan)
+	}
+	for i, change := range plan.Changes {
+		description := string(change.Action) + " " + string(change.Kind) + " " + change.Name
+		if description != expected[i] {
+			t.Errorf("Expected change '%s' but was '%s'", expected[i], description)
+		}
+	}
+	if len(plan.Changes[1].Fields) != 1 || plan.Changes[1].Fields[0].Field != "alarm_actions" {
+		t.Errorf("Expected only alarm_actions to differ but was %v", plan.Changes[1].Fields)
+	}
+	if len(plan.Changes[2].Fields) != 1 || plan.Changes[2].Fields[0].Field != "match_by" || plan.Changes[2].ID != "d3" {
+		t.Errorf("Expected a match_by change to replace d3 but was %v", plan.Changes[2])
+	}
+	if len(plan.Retained) != 1 || plan.Retained[0].Name != "legacy" {
+		t.Errorf("Expected protected definition to be retained but was %v", plan.Retained)
+	}
+}
+
+func TestApplySyncResolvesNotificationNames(t *testing.T) {
+	requests := []string{}
+	var createdDefinition models.AlarmDefinitionRequestBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method == "GET":
+			w.Write([]byte(`{"links": [], "elements": []}`))
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "notification-methods"):
+			var notification models.NotificationRequestBody
+			json.Unmarshal(body, &notification)
+			w.WriteHeader(201)
+			w.Write([]byte(`{"id": "id-` + *notification.Name + `", "name": "` + *notification.Name + `"}`))
+		case r.Method == "POST":
+			json.Unmarshal(body, &createdDefinition)
+			w.WriteHeader(201)
+			w.Write([]byte(`{"id": "d1"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	state, err := LoadDesiredState(strings.NewReader(`
+notification_methods:
+  - name: ops
+    type: EMAIL
+    address: ops@example.com
+alarm_definitions:
+  - name: cpu
+    expression: avg(cpu.idle_perc) < 10
+    alarm_actions: [ops]
+`))
+	if err != nil {
+		t.Fatalf("Error %s loading desired state", err)
+	}
+
+	plan, err := client.Sync(state, &SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Error %s planning sync", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("Expected dry run to only read current state but made requests %v", requests)
+	}
+
+	err = client.ApplySync(plan)
+	if err != nil {
+		t.Fatalf("Error %s applying sync", err)
+	}
+	if createdDefinition.AlarmActions == nil || len(*createdDefinition.AlarmActions) != 1 || (*createdDefinition.AlarmActions)[0] != "id-ops" {
+		t.Errorf("Expected alarm actions to reference the created notification but was %v", createdDefinition.AlarmActions)
+	}
+}
+
+func TestApplySyncReplacesDefinitionOnMatchByChange(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "GET":
+			if strings.HasSuffix(r.URL.Path, "alarm-definitions") {
+				w.Write([]byte(`{"links": [], "elements": [{"id": "d1", "name": "cpu", "expression": "avg(cpu.idle_perc) < 10",
+					"severity": "LOW", "match_by": ["hostname"], "actions_enabled": true}]}`))
+				return
+			}
+			w.Write([]byte(`{"links": [], "elements": []}`))
+		case "POST":
+			w.WriteHeader(201)
+			w.Write([]byte(`{"id": "d2"}`))
+		case "DELETE":
+			w.WriteHeader(204)
+		default:
+			w.WriteHeader(500)
+		}
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	state, err := LoadDesiredState(strings.NewReader(`
+alarm_definitions:
+  - name: cpu
+    expression: avg(cpu.idle_perc) < 10
+    match_by: [hostname, device]
+`))
+	if err != nil {
+		t.Fatalf("Error %s loading desired state", err)
+	}
+
+	_, err = client.Sync(state, &SyncOptions{})
+	if err != nil {
+		t.Fatalf("Error %s syncing", err)
+	}
+	expected := "DELETE /v2.0/alarm-definitions/d1,POST /v2.0/alarm-definitions"
+	if strings.Join(requests[2:], ",") != expected {
+		t.Errorf("Expected %s but was %v", expected, requests)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// tenantOperations are the operations the API lets a user with the delegate
+// role make on behalf of another tenant through the tenant_id parameter.
+var tenantOperations = map[string]bool{
+	OperationGetMetrics:         true,
+	OperationCreateMetrics:      true,
+	OperationGetMetricNames:     true,
+	OperationGetDimensionNames:  true,
+	OperationGetDimensionValues: true,
+	OperationGetMeasurements:    true,
+	OperationGetStatistics:      true,
+}
+
+// ForTenant returns a view of the client acting on behalf of tenantID.
+//
+// With a keystone configuration the view authenticates with a token scoped
+// to the tenant's project, so every operation acts on that tenant; the token
+// is kept apart from the original client's. Without one, tenant_id is added
+// to the metric operations, which requires the delegate role, and alarm,
+// alarm definition and notification method operations fail, as the API has
+// no way to delegate them.
+func (c *Client) ForTenant(tenantID string) *Client {
+	clone := *c
+	clone.tenantID = tenantID
+	if c.keystoneConfig != nil {
+		config := *c.keystoneConfig
+		config.TenantID = tenantID
+		config.TenantName = ""
+		config.Scope = nil
+		clone.keystoneConfig = &config
+
+		clone.headers = http.Header{}
+		for name, values := range c.headers {
+			clone.headers[name] = append([]string(nil), values...)
+		}
+		clone.headers.Del("X-Auth-Token")
+	}
+	return &clone
+}
+
+// TenantID returns the tenant set by ForTenant, "" for the client's own.
+func (c *Client) TenantID() string {
+	return c.tenantID
+}
+
+// applyTenant binds a request to the tenant of a ForTenant view.
+func (c *Client) applyTenant(method string, requestURL *url.URL) error {
+	if c.tenantID == "" || c.keystoneConfig != nil {
+		return nil
+	}
+	name := operationName(method, requestURL)
+	if !tenantOperations[name] {
+		return fmt.Errorf("%s cannot act on behalf of tenant %s without a keystone project-scoped token", name, c.tenantID)
+	}
+	query := requestURL.Query()
+	if existing := query.Get("tenant_id"); existing != "" && existing != c.tenantID {
+		return fmt.Errorf("%s for tenant %s conflicts with client tenant %s", name, existing, c.tenantID)
+	}
+	query.Set("tenant_id", c.tenantID)
+	requestURL.RawQuery = query.Encode()
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"github.com/gophercloud/gophercloud"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForTenant(t *testing.T) {
+	var tenants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants = append(tenants, r.URL.Query().Get("tenant_id"))
+		if r.Method == "POST" {
+			w.WriteHeader(204)
+			return
+		}
+		w.Write([]byte(`{"links": [], "elements": []}`))
+	}))
+	defer server.Close()
+
+	client := New()
+	client.SetBaseURL(server.URL)
+	tenant := client.ForTenant("tenant-a")
+	if _, err := tenant.GetMetricNames(nil); err != nil {
+		t.Fatalf("Error %s getting metric names", err)
+	}
+	if err := tenant.CreateMetric(nil, &models.MetricRequestBody{}); err != nil {
+		t.Fatalf("Error %s creating metric", err)
+	}
+	if _, err := client.GetMetricNames(nil); err != nil {
+		t.Fatalf("Error %s getting metric names", err)
+	}
+	expected := []string{"tenant-a", "tenant-a", ""}
+	if len(tenants) != len(expected) || tenants[0] != expected[0] || tenants[1] != expected[1] || tenants[2] != expected[2] {
+		t.Errorf("Expected tenants %q but was %q", expected, tenants)
+	}
+
+	if _, err := tenant.GetAlarms(nil); err == nil {
+		t.Errorf("Expected alarms to be unsupported without keystone")
+	}
+	other := "tenant-b"
+	if err := tenant.CreateMetric(&other, &models.MetricRequestBody{}); err == nil {
+		t.Errorf("Expected conflicting tenant to be rejected")
+	}
+	if len(tenants) != 3 {
+		t.Errorf("Expected rejected calls not to be sent but was %d calls", len(tenants))
+	}
+}
+
+func TestForTenantKeystone(t *testing.T) {
+	client := New()
+	client.SetHeaders(http.Header{"X-Auth-Token": []string{"admin"}, "X-Extra": []string{"1"}})
+	client.SetKeystoneConfig(&gophercloud.AuthOptions{TenantName: "admin", IdentityEndpoint: "http://keystone"})
+	tenant := client.ForTenant("tenant-a")
+
+	if tenant.keystoneConfig.TenantID != "tenant-a" || tenant.keystoneConfig.TenantName != "" {
+		t.Errorf("Expected config scoped to tenant-a but was %+v", tenant.keystoneConfig)
+	}
+	if client.keystoneConfig.TenantName != "admin" {
+		t.Errorf("Expected original config to be unchanged but was %+v", client.keystoneConfig)
+	}
+	if tenant.headers.Get("X-Auth-Token") != "" || tenant.headers.Get("X-Extra") != "1" {
+		t.Errorf("Expected headers without the admin token but was %v", tenant.headers)
+	}
+	if client.headers.Get("X-Auth-Token") != "admin" {
+		t.Errorf("Expected original token to be kept but was %v", client.headers)
+	}
+	request, _ := http.NewRequest("GET", "http://localhost/v2.0/alarms", nil)
+	if err := tenant.applyTenant("GET", request.URL); err != nil || request.URL.RawQuery != "" {
+		t.Errorf("Expected scoped token to cover alarms but was %v, '%s'", err, request.URL.RawQuery)
+	}
+}
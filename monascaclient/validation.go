@@ -0,0 +1,123 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits enforced by the Monasca API on posted metrics.
+const (
+	MaxMetricNameLength     = 255
+	MaxDimensionKeyLength   = 255
+	MaxDimensionValueLength = 255
+	MaxValueMetaCount       = 16
+	MaxValueMetaNameLength  = 255
+	MaxValueMetaValueLength = 2048
+)
+
+const invalidMetricChars = "<>={}(),\"\\|;&"
+
+// ValidateMetric checks a metric against the limits of the Monasca API so
+// that invalid metrics can be rejected before a whole batch fails.
+func ValidateMetric(metric *models.MetricRequestBody) error {
+	if metric.Name == nil || *metric.Name == "" {
+		return fmt.Errorf("Metric has no name")
+	}
+	if err := validateMetricString("Metric name", *metric.Name, MaxMetricNameLength); err != nil {
+		return err
+	}
+	if metric.Value == nil || math.IsNaN(*metric.Value) || math.IsInf(*metric.Value, 0) {
+		return fmt.Errorf("Metric %s has no finite value", *metric.Name)
+	}
+	if metric.Dimensions != nil {
+		for key, value := range *metric.Dimensions {
+			if strings.HasPrefix(key, "_") {
+				return fmt.Errorf("Dimension key %q of metric %s starts with an underscore", key, *metric.Name)
+			}
+			if err := validateMetricString("Dimension key", key, MaxDimensionKeyLength); err != nil {
+				return err
+			}
+			if err := validateMetricString("Dimension value", value, MaxDimensionValueLength); err != nil {
+				return err
+			}
+		}
+	}
+	if metric.ValueMeta != nil {
+		if len(*metric.ValueMeta) > MaxValueMetaCount {
+			return fmt.Errorf("Metric %s has more than %d value meta entries", *metric.Name, MaxValueMetaCount)
+		}
+		for name, value := range *metric.ValueMeta {
+			if name == "" || utf8.RuneCountInString(name) > MaxValueMetaNameLength {
+				return fmt.Errorf("Invalid value meta name %q", name)
+			}
+			if utf8.RuneCountInString(value) > MaxValueMetaValueLength {
+				return fmt.Errorf("Value meta %q is longer than %d characters", name, MaxValueMetaValueLength)
+			}
+		}
+	}
+	return nil
+}
+
+func validateMetricString(field string, value string, maxLength int) error {
+	if value == "" {
+		return fmt.Errorf("%s is empty", field)
+	}
+	if utf8.RuneCountInString(value) > maxLength {
+		return fmt.Errorf("%s %q is longer than %d characters", field, value, maxLength)
+	}
+	if strings.ContainsAny(value, invalidMetricChars) {
+		return fmt.Errorf("%s %q contains one of %s", field, value, invalidMetricChars)
+	}
+	return nil
+}
+
+// SanitizeMetricString replaces characters the API rejects with underscores
+// and truncates the result to maxLength characters.
+func SanitizeMetricString(value string, maxLength int) string {
+	var out strings.Builder
+	length := 0
+	for _, char := range value {
+		if length == maxLength {
+			break
+		}
+		if strings.ContainsRune(invalidMetricChars, char) || char == utf8.RuneError {
+			char = '_'
+		}
+		out.WriteRune(char)
+		length++
+	}
+	return out.String()
+}
+
+// SanitizeDimensions returns dimensions the API accepts: invalid characters
+// are replaced, keys lose leading underscores, keys and values are truncated
+// and pairs left empty are dropped.
+func SanitizeDimensions(dimensions map[string]string) map[string]string {
+	sanitized := make(map[string]string, len(dimensions))
+	for key, value := range dimensions {
+		key = SanitizeMetricString(strings.TrimLeft(key, "_"), MaxDimensionKeyLength)
+		value = SanitizeMetricString(value, MaxDimensionValueLength)
+		if key == "" || value == "" {
+			continue
+		}
+		sanitized[key] = value
+	}
+	return sanitized
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package monascaclient
+
+import (
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidateMetric(t *testing.T) {
+	name := "cpu.idle_perc"
+	value := 1.0
+	nan := math.NaN()
+	longValue := strings.Repeat("a", MaxDimensionValueLength+1)
+	tests := []struct {
+		metric models.MetricRequestBody
+		valid  bool
+	}{
+		{models.MetricRequestBody{Name: &name, Value: &value, Dimensions: &map[string]string{"hostname": "web1"}}, true},
+		{models.MetricRequestBody{Value: &value}, false},
+		{models.MetricRequestBody{Name: &name, Value: &nan}, false},
+		{models.MetricRequestBody{Name: &name, Value: &value, Dimensions: &map[string]string{"_hidden": "x"}}, false},
+		{models.MetricRequestBody{Name: &name, Value: &value, Dimensions: &map[string]string{"path": "a{b}"}}, false},
+		{models.MetricRequestBody{Name: &name, Value: &value, Dimensions: &map[string]string{"long": longValue}}, false},
+	}
+	for i, test := range tests {
+		err := ValidateMetric(&test.metric)
+		if (err == nil) != test.valid {
+			t.Errorf("Test %d expected valid=%t but got error %v", i, test.valid, err)
+		}
+	}
+}
+
+func TestSanitizeDimensions(t *testing.T) {
+	sanitized := SanitizeDimensions(map[string]string{"__name": "a|b", "empty": "", "long": strings.Repeat("x", 300)})
+	if len(sanitized) != 2 || sanitized["name"] != "a_b" || len(sanitized["long"]) != MaxDimensionValueLength {
+		t.Errorf("Unexpected sanitized dimensions %v", sanitized)
+	}
+}
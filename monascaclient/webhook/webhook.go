@@ -0,0 +1,215 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+// Package webhook receives the alarm transitions POSTed by Monasca WEBHOOK
+// notification methods.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultMaxBodySize = 1 << 20
+
+var validStates = map[string]bool{"OK": true, "ALARM": true, "UNDETERMINED": true}
+
+// Timestamp accepts the epoch seconds sent by monasca-notification, epoch
+// milliseconds and RFC 3339 strings.
+type Timestamp struct {
+	time.Time
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		return nil
+	}
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		// Anything past year 5138 in seconds is taken as milliseconds
+		if number > 1e11 {
+			number /= 1000
+		}
+		seconds := int64(number)
+		t.Time = time.Unix(seconds, int64((number-float64(seconds))*1e9)).UTC()
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return fmt.Errorf("Invalid timestamp %s", data)
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Event is a decoded webhook notification. Alarm and AlarmDefinition are only
+// set when the handler has an Enricher; EnrichError holds any failure to fetch
+// them.
+type Event struct {
+	AlarmID           string          `json:"alarm_id"`
+	AlarmDefinitionID string          `json:"alarm_definition_id"`
+	AlarmName         string          `json:"alarm_name"`
+	AlarmDescription  string          `json:"alarm_description"`
+	State             string          `json:"state"`
+	OldState          string          `json:"old_state"`
+	Severity          string          `json:"severity"`
+	LifecycleState    string          `json:"lifecycle_state"`
+	Link              string          `json:"link"`
+	Message           string          `json:"message"`
+	TenantID          string          `json:"tenant_id"`
+	Metrics           []models.Metric `json:"metrics"`
+	AlarmTimestamp    Timestamp       `json:"alarm_timestamp"`
+
+	Alarm           *models.Alarm                  `json:"-"`
+	AlarmDefinition *models.AlarmDefinitionElement `json:"-"`
+	EnrichError     error                          `json:"-"`
+}
+
+// Validate checks the fields every Monasca notification carries.
+func (e *Event) Validate() error {
+	if e.AlarmID == "" {
+		return fmt.Errorf("Missing alarm_id")
+	}
+	if e.AlarmDefinitionID == "" {
+		return fmt.Errorf("Missing alarm_definition_id")
+	}
+	if !validStates[e.State] {
+		return fmt.Errorf("Invalid state %q", e.State)
+	}
+	if e.OldState != "" && !validStates[e.OldState] {
+		return fmt.Errorf("Invalid old_state %q", e.OldState)
+	}
+	return nil
+}
+
+// Decode reads and validates a notification payload.
+func Decode(reader io.Reader) (*Event, error) {
+	event := new(Event)
+	err := json.NewDecoder(reader).Decode(event)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid notification payload: %v", err)
+	}
+	err = event.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// HandlerFunc processes an event. Returning an error answers the webhook with
+// a 500 so monasca-notification can retry it.
+type HandlerFunc func(ctx context.Context, event *Event) error
+
+// Enricher fetches the current alarm and alarm definition of an event; a
+// *monascaclient.Client satisfies it. A client's lookups are bound to the
+// webhook request, so they stop when the sender disconnects or times out;
+// other Enrichers are called without the request context and cannot be
+// cancelled.
+type Enricher interface {
+	GetAlarm(alarmID string) (*models.Alarm, error)
+	GetAlarmDefinition(alarmDefinitionID string) (*models.AlarmDefinitionElement, error)
+}
+
+// Handler is an http.Handler decoding webhook notifications and dispatching
+// them to the registered HandlerFuncs in order.
+type Handler struct {
+	handlers    []HandlerFunc
+	enricher    Enricher
+	maxBodySize int64
+}
+
+func NewHandler(handlers ...HandlerFunc) *Handler {
+	return &Handler{
+		handlers:    handlers,
+		maxBodySize: defaultMaxBodySize,
+	}
+}
+
+func (h *Handler) Handle(handler HandlerFunc) {
+	h.handlers = append(h.handlers, handler)
+}
+
+func (h *Handler) SetEnricher(enricher Enricher) {
+	h.enricher = enricher
+}
+
+func (h *Handler) SetMaxBodySize(maxBodySize int64) {
+	h.maxBodySize = maxBodySize
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, h.maxBodySize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > h.maxBodySize {
+		http.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	event, err := Decode(bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.enricher != nil {
+		h.enrich(r.Context(), event)
+	}
+
+	for _, handler := range h.handlers {
+		err = handler(r.Context(), event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) enrich(ctx context.Context, event *Event) {
+	enricher := h.enricher
+	if client, ok := enricher.(*monascaclient.Client); ok {
+		enricher = client.WithContext(ctx)
+	}
+	alarm, err := enricher.GetAlarm(event.AlarmID)
+	if err != nil {
+		event.EnrichError = err
+		return
+	}
+	event.Alarm = alarm
+
+	definition, err := enricher.GetAlarmDefinition(event.AlarmDefinitionID)
+	if err != nil {
+		event.EnrichError = err
+		return
+	}
+	event.AlarmDefinition = definition
+}
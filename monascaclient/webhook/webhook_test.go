@@ -0,0 +1,150 @@
+// Copyright 2017 Hewlett Packard Enterprise Development LP
+//
+//    Licensed under the Apache License, Version 2.0 (the "License"); you may
+//    not use this file except in compliance with the License. You may obtain
+//    a copy of the License at
+//
+//         http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+//    WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+//    License for the specific language governing permissions and limitations
+//    under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"github.com/monasca/golang-monascaclient/monascaclient"
+	"github.com/monasca/golang-monascaclient/monascaclient/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPayload = `{
+	"alarm_id": "a1",
+	"alarm_definition_id": "d1",
+	"alarm_name": "cpu",
+	"state": "ALARM",
+	"old_state": "OK",
+	"severity": "HIGH",
+	"tenant_id": "t1",
+	"alarm_timestamp": 1488175200,
+	"metrics": [{"name": "cpu.idle_perc", "dimensions": {"hostname": "web1"}}]
+}`
+
+var _ Enricher = monascaclient.New()
+
+type testEnricher struct{}
+
+func (testEnricher) GetAlarm(alarmID string) (*models.Alarm, error) {
+	alarm := &models.Alarm{State: "ALARM"}
+	alarm.ID = alarmID
+	return alarm, nil
+}
+
+func (testEnricher) GetAlarmDefinition(alarmDefinitionID string) (*models.AlarmDefinitionElement, error) {
+	return nil, errors.New("not found")
+}
+
+func TestHandlerDispatchesEvent(t *testing.T) {
+	var received *Event
+	handler := NewHandler(func(ctx context.Context, event *Event) error {
+		received = event
+		return nil
+	})
+	handler.SetEnricher(testEnricher{})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/", strings.NewReader(testPayload)))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 but was %d: %s", recorder.Code, recorder.Body)
+	}
+	if received == nil || received.AlarmID != "a1" || received.OldState != "OK" || received.Severity != "HIGH" {
+		t.Fatalf("Unexpected event %+v", received)
+	}
+	if !received.AlarmTimestamp.Equal(time.Date(2017, 2, 27, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected timestamp %v", received.AlarmTimestamp)
+	}
+	if len(received.Metrics) != 1 || received.Metrics[0].Dimensions["hostname"] != "web1" {
+		t.Errorf("Unexpected metrics %+v", received.Metrics)
+	}
+	if received.Alarm == nil || received.Alarm.ID != "a1" || received.EnrichError == nil {
+		t.Errorf("Expected alarm enrichment and definition error but was %+v %v", received.Alarm, received.EnrichError)
+	}
+}
+
+func TestHandlerEnrichmentFollowsRequestContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := monascaclient.New()
+	client.SetBaseURL(server.URL)
+	var received *Event
+	handler := NewHandler(func(ctx context.Context, event *Event) error {
+		received = event
+		return nil
+	})
+	handler.SetEnricher(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	request := httptest.NewRequest("POST", "/", strings.NewReader(testPayload)).WithContext(ctx)
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected enrichment to stop with the request but took %v", time.Since(start))
+	}
+	if received == nil || received.EnrichError == nil {
+		t.Errorf("Expected an enrichment error but was %+v", received)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	failing := NewHandler(func(ctx context.Context, event *Event) error {
+		return errors.New("failed")
+	})
+	failing.SetMaxBodySize(int64(len(testPayload)))
+
+	tests := []struct {
+		method   string
+		body     string
+		expected int
+	}{
+		{"GET", "", http.StatusMethodNotAllowed},
+		{"POST", "{", http.StatusBadRequest},
+		{"POST", `{"alarm_id": "a1", "alarm_definition_id": "d1", "state": "BROKEN"}`, http.StatusBadRequest},
+		{"POST", testPayload + " ", http.StatusRequestEntityTooLarge},
+		{"POST", testPayload, http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		failing.ServeHTTP(recorder, httptest.NewRequest(test.method, "/", strings.NewReader(test.body)))
+		if recorder.Code != test.expected {
+			t.Errorf("Expected status %d for %s '%s' but was %d", test.expected, test.method, test.body, recorder.Code)
+		}
+	}
+}
+
+func TestTimestampMilliseconds(t *testing.T) {
+	event, err := Decode(strings.NewReader(`{"alarm_id": "a1", "alarm_definition_id": "d1", "state": "OK", "alarm_timestamp": 1488175200500}`))
+	if err != nil {
+		t.Fatalf("Error %s decoding", err)
+	}
+	if !event.AlarmTimestamp.Equal(time.Date(2017, 2, 27, 6, 0, 0, 500000000, time.UTC)) {
+		t.Errorf("Unexpected timestamp %v", event.AlarmTimestamp)
+	}
+}